@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/crypto"
+	"jwt-auth/internal/infrastructure/database"
+	emailinfra "jwt-auth/internal/infrastructure/email"
+	"jwt-auth/internal/infrastructure/fallback"
+	"jwt-auth/internal/infrastructure/jwt"
+	"jwt-auth/internal/infrastructure/memory"
+	redisinfra "jwt-auth/internal/infrastructure/redis"
+	infrarepos "jwt-auth/internal/infrastructure/repositories"
+	"jwt-auth/internal/infrastructure/totp"
+	"jwt-auth/internal/infrastructure/webauthn"
+	"jwt-auth/internal/interfaces/config"
+	"jwt-auth/migrations"
+	"log"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// app holds the wiring shared by the server and the admin commands
+type app struct {
+	cfg          *config.Config
+	db           *database.DB
+	redisClient  *redis.Client
+	userRepo     repositories.UserRepository
+	keyring      *jwt.Keyring
+	jwtManager   services.JWTManager
+	authService  services.AuthService
+	outboxWorker *emailinfra.OutboxWorker
+}
+
+// openDatabase connects to Postgres and loads the embedded migrations
+func openDatabase(cfg *config.Config) (*database.DB, *database.Migrator, error) {
+	db, err := database.NewPostgresDB(
+		cfg.Database.Host,
+		cfg.Database.Port,
+		cfg.Database.User,
+		cfg.Database.Password,
+		cfg.Database.DBName,
+		cfg.Database.SSLMode,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return db, migrator, nil
+}
+
+// newApp connects to Postgres and Redis and builds the services. With
+// migrate set, pending migrations are applied first; either way it refuses
+// a schema that isn't up to date.
+func newApp(ctx context.Context, cfg *config.Config, migrate bool) (_ *app, err error) {
+	// TOTP secrets and signing keys are encrypted at rest
+	secretBox, err := newSecretBox(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize encryption at rest: %w", err)
+	}
+
+	// Initialize database
+	db, migrator, err := openDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Don't leak the connections when the wiring fails
+	var redisClient *redis.Client
+	defer func() {
+		if err != nil {
+			if redisClient != nil {
+				redisClient.Close()
+			}
+			db.Close()
+		}
+	}()
+
+	// Bring the schema up to date, or make sure someone else did
+	if migrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
+	if err := migrator.Check(ctx); err != nil {
+		return nil, fmt.Errorf("database schema is not ready: %w", err)
+	}
+
+	// Initialize Redis
+	redisClient = redisinfra.NewRedisClient(&redisinfra.RedisConfig{
+		Host:     cfg.Redis.Host,
+		Port:     cfg.Redis.Port,
+		Password: cfg.Redis.Password,
+	})
+	// Fail fast instead of waiting on every call while Redis is down
+	redisClient.AddHook(redisinfra.NewCircuitBreaker(cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown))
+
+	// Initialize token blacklist service, with REDIS_BLACKLIST_MODE deciding
+	// what happens while Redis is unavailable
+	tokenBlacklist, err := fallback.NewTokenBlacklist(
+		redisinfra.NewTokenBlacklistService(redisClient),
+		memory.NewTokenBlacklist(cfg.Redis.FallbackSize),
+		cfg.Redis.BlacklistMode,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_BLACKLIST_MODE: %w", err)
+	}
+
+	// Initialize cooldown service (for resend limits)
+	cooldownService := redisinfra.NewCooldownService(redisClient)
+
+	// Initialize challenge store (for WebAuthn ceremonies)
+	challengeStore := redisinfra.NewChallengeStore(redisClient)
+
+	// Initialize one-time code store (for emailed codes)
+	oneTimeCodeStore := redisinfra.NewOneTimeCodeStore(redisClient, cfg.Auth.EmailOTPMaxAttempts, cfg.Auth.EmailOTPLockout)
+
+	// Initialize passkey verification
+	webAuthnService, err := webauthn.NewWebAuthnService(&webauthn.Config{
+		RPID:    cfg.WebAuthn.RPID,
+		RPName:  cfg.WebAuthn.RPName,
+		Origins: cfg.WebAuthn.Origins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize WebAuthn: %w", err)
+	}
+
+	// Initialize email transport (SMTP when configured, stdout otherwise)
+	var emailTransport services.EmailService
+	if cfg.SMTP.Host != "" {
+		emailTransport, err = emailinfra.NewSMTPEmailService(&emailinfra.SMTPConfig{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.User,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			TLSMode:  cfg.SMTP.TLSMode,
+		})
+	} else {
+		emailTransport, err = emailinfra.NewEmailService()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize email service: %w", err)
+	}
+
+	// Initialize repositories
+	userRepo := infrarepos.NewUserRepository(db)
+	signingKeyRepo := infrarepos.NewSigningKeyRepository(db, secretBox)
+	refreshTokenRepo := infrarepos.NewRefreshTokenRepository(db)
+	securityEventRepo := infrarepos.NewSecurityEventRepository(db)
+	oneTimeTokenRepo := infrarepos.NewOneTimeTokenRepository(db)
+	emailOutboxRepo := infrarepos.NewEmailOutboxRepository(db)
+	totpRepo := infrarepos.NewTOTPRepository(db, secretBox)
+	recoveryCodeRepo := infrarepos.NewRecoveryCodeRepository(db)
+	webAuthnCredentialRepo := infrarepos.NewWebAuthnCredentialRepository(db)
+	roleRepo := infrarepos.NewRoleRepository(db)
+	oauthClientRepo := infrarepos.NewOAuthClientRepository(db)
+
+	// Emails are queued in the outbox and delivered in the background by the server
+	emailService, err := emailinfra.NewOutboxEmailService(emailOutboxRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize email outbox: %w", err)
+	}
+	outboxWorker := emailinfra.NewOutboxWorker(emailOutboxRepo, emailTransport, &emailinfra.OutboxWorkerConfig{
+		PollInterval: cfg.Outbox.PollInterval,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		BaseBackoff:  cfg.Outbox.BaseBackoff,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+	})
+
+	// Initialize JWT keyring and manager
+	jwtConfig := &jwt.JWTConfig{
+		Algorithm:          cfg.JWT.Algorithm,
+		SecretKey:          cfg.JWT.SecretKey,
+		PrivateKeyPath:     cfg.JWT.PrivateKeyPath,
+		KeyID:              cfg.JWT.KeyID,
+		AccessTokenExpiry:  cfg.JWT.AccessTokenExpiry,
+		RefreshTokenExpiry: cfg.JWT.RefreshTokenExpiry,
+	}
+	keyring, err := jwt.NewKeyring(ctx, jwtConfig, signingKeyRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize signing keyring: %w", err)
+	}
+	jwtManager := jwt.NewJWTManagerWithKeyring(jwtConfig, keyring)
+
+	// Initialize services
+	// Ensure infrastructure implementations are passed as domain interfaces
+	authService := appservices.NewAuthService(appservices.AuthDeps{
+		UserRepo:               userRepo,
+		RefreshTokenRepo:       refreshTokenRepo,
+		SecurityEventRepo:      securityEventRepo,
+		OneTimeTokenRepo:       oneTimeTokenRepo,
+		TOTPRepo:               totpRepo,
+		RecoveryCodeRepo:       recoveryCodeRepo,
+		WebAuthnCredentialRepo: webAuthnCredentialRepo,
+		RoleRepo:               roleRepo,
+		OAuthClientRepo:        oauthClientRepo,
+		TxManager:              db,
+		JWTManager:             jwtManager,
+		TOTPService:            totp.NewTOTPService(cfg.Auth.MFAIssuer),
+		WebAuthnService:        webAuthnService,
+		EmailService:           emailService,
+		TokenBlacklist:         tokenBlacklist,
+		CooldownService:        cooldownService,
+		ChallengeStore:         challengeStore,
+		OneTimeCodeStore:       oneTimeCodeStore,
+		DeviceGrantStore:       redisinfra.NewDeviceGrantStore(redisClient),
+		Config: &appservices.AuthConfig{
+			PasswordResetURL:          cfg.Auth.PasswordResetURL,
+			PasswordResetExpiry:       cfg.Auth.PasswordResetExpiry,
+			EmailVerificationURL:      cfg.Auth.EmailVerificationURL,
+			EmailVerificationSecret:   cfg.Auth.EmailVerificationSecret,
+			EmailVerificationExpiry:   cfg.Auth.EmailVerificationExpiry,
+			EmailVerificationCooldown: cfg.Auth.EmailVerificationCooldown,
+			RequireEmailVerification:  cfg.Auth.RequireEmailVerification,
+			MFAChallengeExpiry:        cfg.Auth.MFAChallengeExpiry,
+			PasskeyTimeout:            cfg.WebAuthn.Timeout,
+			MagicLinkURL:              cfg.Auth.MagicLinkURL,
+			MagicLinkExpiry:           cfg.Auth.MagicLinkExpiry,
+			EmailOTPExpiry:            cfg.Auth.EmailOTPExpiry,
+			LockoutThreshold:          cfg.Auth.LockoutThreshold,
+			LockoutBaseDuration:       cfg.Auth.LockoutBaseDuration,
+			LockoutMaxDuration:        cfg.Auth.LockoutMaxDuration,
+			AccountUnlockURL:          cfg.Auth.AccountUnlockURL,
+			AccountUnlockExpiry:       cfg.Auth.AccountUnlockExpiry,
+			DefaultRole:               cfg.Auth.DefaultRole,
+			Scopes:                    cfg.Auth.Scopes,
+			OAuthCodeExpiry:           cfg.Auth.OAuthCodeExpiry,
+			DeviceCodeExpiry:          cfg.Auth.DeviceCodeExpiry,
+			DevicePollInterval:        cfg.Auth.DevicePollInterval,
+			Issuer:                    cfg.Auth.Issuer,
+		},
+	})
+
+	return &app{
+		cfg:          cfg,
+		db:           db,
+		redisClient:  redisClient,
+		userRepo:     userRepo,
+		keyring:      keyring,
+		jwtManager:   jwtManager,
+		authService:  authService,
+		outboxWorker: outboxWorker,
+	}, nil
+}
+
+// newSecretBox uses ENCRYPTION_KEY. It is never derived from another
+// setting: the stored secrets must stay readable when JWT_SECRET is rotated,
+// and a default would be public.
+func newSecretBox(cfg *config.Config) (*crypto.SecretBox, error) {
+	if cfg.Auth.EncryptionKey == "" {
+		return nil, fmt.Errorf("ENCRYPTION_KEY is not set; signing keys and TOTP secrets can't be stored or read without it")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.Auth.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY must be base64: %w", err)
+	}
+	return crypto.NewSecretBox(key)
+}
+
+func (a *app) Close() {
+	a.redisClient.Close()
+	a.db.Close()
+}
+
+// findUser looks a user up by numeric id or by email
+func (a *app) findUser(ctx context.Context, ref string) (*entities.User, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return a.userRepo.GetByID(ctx, id)
+	}
+	return a.userRepo.GetByEmail(ctx, ref)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/interfaces/config"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const clientsUsage = `Usage: jwt-auth clients <command> [flags]
+
+Commands:
+  create --name NAME --redirect-uri URI[,URI...] [--scope "SCOPE ..."]
+         [--post-logout-redirect-uri URI[,URI...]]
+         [--auth-method METHOD [--public-key FILE]]
+                       Register an OAuth client; the scope defaults to
+                       profile, other scopes must be named. METHOD is none
+                       (a public client, the default), client_secret_basic or
+                       client_secret_post (a secret is printed once) or
+                       private_key_jwt (verified with the PEM public key).
+                       Confidential clients need no redirect URI to get
+                       tokens with the client_credentials grant.
+  list                 List the registered clients
+  delete CLIENT_ID     Remove a client; its tokens can no longer be refreshed`
+
+func runClients(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return &usageError{clientsUsage}
+	}
+
+	fs := flag.NewFlagSet("clients "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, clientsUsage) }
+	name := fs.String("name", "", "name shown to users on the consent page")
+	redirectURIs := fs.String("redirect-uri", "", "comma-separated redirect URIs")
+	postLogoutRedirectURIs := fs.String("post-logout-redirect-uri", "", "comma-separated URIs to return to after logout")
+	authMethod := fs.String("auth-method", entities.ClientAuthNone, "how the client authenticates at the token endpoint")
+	publicKey := fs.String("public-key", "", "PEM public key file of a private_key_jwt client")
+	scope := fs.String("scope", "profile", "space-separated scopes the client may ask for")
+	fs.Parse(args[1:])
+
+	// Check the command line before connecting to anything
+	switch args[0] {
+	case "create", "list":
+		if fs.NArg() != 0 {
+			return &usageError{clientsUsage}
+		}
+	case "delete":
+		if fs.NArg() != 1 {
+			return &usageError{clientsUsage}
+		}
+	default:
+		return &usageError{clientsUsage}
+	}
+
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch args[0] {
+	case "create":
+		client := &entities.OAuthClient{
+			Name:                   *name,
+			RedirectURIs:           splitURIs(*redirectURIs),
+			PostLogoutRedirectURIs: splitURIs(*postLogoutRedirectURIs),
+			Scopes:                 strings.Fields(*scope),
+			AuthMethod:             *authMethod,
+		}
+		if *publicKey != "" {
+			data, err := os.ReadFile(*publicKey)
+			if err != nil {
+				return err
+			}
+			client.PublicKey = string(data)
+		}
+		secret, err := a.authService.RegisterOAuthClient(ctx, client)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Registered client %s (%s)\n", client.ID, client.Name)
+		if secret != "" {
+			fmt.Printf("Client secret: %s\n", secret)
+			fmt.Println("Store it now; it can't be shown again.")
+		}
+
+	case "list":
+		clients, err := a.authService.ListOAuthClients(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CLIENT_ID\tNAME\tAUTH\tSCOPE\tREDIRECT_URIS\tCREATED")
+		for _, client := range clients {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", client.ID, client.Name, client.AuthMethod, strings.Join(client.Scopes, " "),
+				strings.Join(client.RedirectURIs, ","), formatTime(&client.CreatedAt))
+		}
+		return w.Flush()
+
+	case "delete":
+		if err := a.authService.DeleteOAuthClient(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted client %s\n", fs.Arg(0))
+	}
+	return nil
+}
+
+// splitURIs splits a comma-separated list of URIs
+func splitURIs(list string) []string {
+	uris := []string{}
+	for _, uri := range strings.Split(list, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
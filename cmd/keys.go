@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/interfaces/config"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const keysUsage = `Usage: jwt-auth keys <command> [flags]
+
+Commands:
+  list                      List the signing keys
+  rotate [--algorithm ALG]  Stage a new key; it is published in the JWKS but
+                            does not sign until promoted
+  promote KID               Make a staged key the signing key
+  retire KID                Retire an inactive key whose tokens have expired`
+
+func runKeys(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return &usageError{keysUsage}
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, keysUsage) }
+	algorithm := fs.String("algorithm", cfg.JWT.Algorithm, "algorithm of the new key: HS256, RS256, ES256 or EdDSA")
+	fs.Parse(args[1:])
+
+	// Check the command line before connecting to anything
+	switch args[0] {
+	case "list", "rotate":
+		if fs.NArg() != 0 {
+			return &usageError{keysUsage}
+		}
+	case "promote", "retire":
+		if fs.NArg() != 1 {
+			return &usageError{keysUsage}
+		}
+	default:
+		return &usageError{keysUsage}
+	}
+
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch args[0] {
+	case "list":
+		keys, err := a.keyring.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tACTIVATED\tDEACTIVATED")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, key.Status,
+				formatTime(&key.CreatedAt), formatTime(key.ActivatedAt), formatTime(key.DeactivatedAt))
+		}
+		return w.Flush()
+
+	case "rotate":
+		key, err := a.keyring.StageKey(ctx, *algorithm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Staged %s key %s\n", key.Algorithm, key.ID)
+		fmt.Printf("Once verifiers have refreshed their JWKS, run: keys promote %s\n", key.ID)
+
+	case "promote":
+		if err := a.keyring.PromoteKey(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Printf("Key %s now signs new tokens\n", fs.Arg(0))
+
+	case "retire":
+		if err := a.keyring.RetireKey(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Printf("Retired key %s\n", fs.Arg(0))
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,87 +1,71 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	appservices "jwt-auth/internal/application/services"
-	"jwt-auth/internal/infrastructure/database"
-	emailinfra "jwt-auth/internal/infrastructure/email"
-	"jwt-auth/internal/infrastructure/jwt"
-	redisinfra "jwt-auth/internal/infrastructure/redis"
-	"jwt-auth/internal/infrastructure/repositories"
 	"jwt-auth/internal/interfaces/config"
-	"jwt-auth/internal/interfaces/http/handlers"
-	"jwt-auth/internal/interfaces/http/middleware"
-	"jwt-auth/internal/interfaces/http/routes"
-	"log"
 	"os"
 )
 
-func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
-
-	// Initialize Redis
-	redisClient := redisinfra.NewRedisClient(&redisinfra.RedisConfig{
-		Host:     os.Getenv("REDIS_HOST"),
-		Port:     os.Getenv("REDIS_PORT"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-	})
-
-	// Initialize token blacklist service
-	tokenBlacklist := redisinfra.NewTokenBlacklistService(redisClient)
-
-	// Initialize email service (for password reset)
-	emailService := emailinfra.NewEmailService()
-
-	// Initialize database
-	db, err := database.NewPostgresDB(
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.DBName,
-		cfg.Database.SSLMode,
-	)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
-
-	log.Println("Connected to database successfully")
-
-	// Initialize JWT manager
-	jwtManager := jwt.NewJWTManager()
+const usage = `Usage: jwt-auth <command> [arguments]
 
-	// Initialize repositories
-	userRepo := repositories.NewUserRepository(db)
+Commands:
+  serve      Start the HTTP server (default)
+  migrate    Apply, revert or inspect database migrations
+  user       Create, disable, reset or verify users
+  keys       List and rotate the token signing keys
+  tokens     Revoke a user's sessions
+  clients    Register, list and delete OAuth clients
 
-	// Initialize services
-	// Ensure infrastructure implementations are passed as domain interfaces
+Run "jwt-auth <command>" without arguments for the command's usage.
+Configuration is read from the environment and .env, as for the server.`
 
-	authService := appservices.NewAuthService(
-		userRepo,
-		jwtManager,
-		emailService,
-		tokenBlacklist,
-	)
-
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+// usageError is returned by a command given arguments it can't run with.
+// main prints the usage and exits with status 2.
+type usageError struct {
+	usage string
+}
 
-	// Initialize middleware
-	jwtMiddleware := middleware.NewJWTMiddleware(authService)
+func (e *usageError) Error() string {
+	return e.usage
+}
 
-	// Initialize rate limiter
-	rateLimiter := middleware.NewRateLimiter(redisClient, 5, 60) // 100 requests per 60 seconds
+func main() {
+	// Load configuration
+	cfg := config.LoadConfig()
 
-	// Setup routes
-	router := routes.SetupRoutes(authHandler, jwtMiddleware, rateLimiter)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
 
-	// Start server
-	serverAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Starting server on %s", serverAddr)
+	var err error
+	switch args[0] {
+	case "serve":
+		err = runServe(cfg, args[1:])
+	case "migrate":
+		err = runMigrate(cfg, args[1:])
+	case "user":
+		err = runUser(cfg, args[1:])
+	case "keys":
+		err = runKeys(cfg, args[1:])
+	case "tokens":
+		err = runTokens(cfg, args[1:])
+	case "clients":
+		err = runClients(cfg, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+	default:
+		err = &usageError{usage}
+	}
 
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(os.Stderr, usageErr.usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/interfaces/config"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `Usage: jwt-auth migrate <command>
+
+Commands:
+  up               Apply all pending migrations
+  down [N]         Revert the last N migrations (default 1)
+  status           List migrations and whether they are applied
+  force VERSION    Record the schema as being at VERSION without running
+                   anything, after repairing a dirty schema by hand`
+
+func runMigrate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, migrateUsage) }
+	fs.Parse(args)
+
+	// Check the command line before connecting to anything
+	steps, version := 1, int64(0)
+	switch fs.Arg(0) {
+	case "up", "status":
+		if fs.NArg() != 1 {
+			return &usageError{migrateUsage}
+		}
+	case "down":
+		if fs.NArg() > 2 {
+			return &usageError{migrateUsage}
+		}
+		if fs.NArg() == 2 {
+			var err error
+			if steps, err = strconv.Atoi(fs.Arg(1)); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", fs.Arg(1))
+			}
+		}
+	case "force":
+		if fs.NArg() != 2 {
+			return &usageError{migrateUsage}
+		}
+		var err error
+		if version, err = strconv.ParseInt(fs.Arg(1), 10, 64); err != nil {
+			return fmt.Errorf("invalid version %q", fs.Arg(1))
+		}
+	default:
+		return &usageError{migrateUsage}
+	}
+
+	db, migrator, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return nil
+
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+			}
+			if status.Dirty {
+				state = "dirty"
+			}
+			if status.Name == "" {
+				status.Name = "(unknown to this build)"
+			}
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	case "force":
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("Schema recorded at version %d\n", version)
+	}
+	return nil
+}
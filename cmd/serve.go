@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/fallback"
+	"jwt-auth/internal/infrastructure/memory"
+	redisinfra "jwt-auth/internal/infrastructure/redis"
+	"jwt-auth/internal/interfaces/config"
+	"jwt-auth/internal/interfaces/http/handlers"
+	"jwt-auth/internal/interfaces/http/middleware"
+	"jwt-auth/internal/interfaces/http/routes"
+	"log"
+)
+
+func runServe(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg, cfg.Database.AutoMigrate)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	log.Println("Connected to database successfully")
+
+	// Background work: keyring reloads and email delivery
+	go a.keyring.Watch(ctx, cfg.JWT.KeyringRefreshInterval)
+	go a.outboxWorker.Run(ctx)
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(a.authService)
+	mfaHandler := handlers.NewMFAHandler(a.authService)
+	passkeyHandler := handlers.NewPasskeyHandler(a.authService)
+	accountHandler := handlers.NewAccountHandler(a.authService)
+	jwksHandler := handlers.NewJWKSHandler(a.jwtManager)
+	emailQueueHandler := handlers.NewEmailQueueHandler(a.outboxWorker)
+	roleHandler := handlers.NewRoleHandler(a.authService)
+	oauthHandler := handlers.NewOAuthHandler(a.authService)
+
+	// Initialize middleware
+	jwtMiddleware := middleware.NewJWTMiddleware(a.authService)
+
+	// Initialize rate limiter: every client IP shares RATE_LIMIT requests
+	// per RATE_LIMIT_PERIOD on the auth and OAuth routes without a policy
+	// of their own
+	var policies []middleware.RateLimitPolicy
+	for _, route := range []string{"/api/v1/auth/*", "/oauth/*"} {
+		policies = append(policies, middleware.RateLimitPolicy{
+			Route: route,
+			Key:   middleware.RateLimitByIP,
+			RateLimit: services.RateLimit{
+				Algorithm: cfg.RateLimit.Algorithm,
+				Limit:     cfg.RateLimit.Limit,
+				Period:    cfg.RateLimit.Period,
+			},
+		})
+	}
+	for _, entry := range cfg.RateLimit.Policies {
+		policy, err := middleware.ParseRateLimitPolicy(entry, cfg.RateLimit.Algorithm)
+		if err != nil {
+			return err
+		}
+		policies = append(policies, policy)
+	}
+	// REDIS_RATE_LIMIT_MODE decides what happens while Redis is unavailable
+	rateLimitStore, err := fallback.NewRateLimitStore(
+		redisinfra.NewRateLimitStore(a.redisClient),
+		memory.NewRateLimitStore(cfg.Redis.FallbackSize),
+		cfg.Redis.RateLimitMode,
+	)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_RATE_LIMIT_MODE: %w", err)
+	}
+	rateLimiter, err := middleware.NewRateLimiter(rateLimitStore, policies, cfg.RateLimit.Allowlist)
+	if err != nil {
+		return fmt.Errorf("failed to initialize rate limiter: %w", err)
+	}
+
+	// Setup routes
+	router := routes.SetupRoutes(authHandler, mfaHandler, passkeyHandler, accountHandler, jwksHandler, emailQueueHandler, roleHandler, oauthHandler, jwtMiddleware, rateLimiter)
+
+	// Only trusted proxies may set the client IP that rate limits and the
+	// allowlist go by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Start server
+	serverAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
+	log.Printf("Starting server on %s", serverAddr)
+
+	if err := router.Run(":" + cfg.Server.Port); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
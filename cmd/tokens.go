@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/interfaces/config"
+	"os"
+)
+
+const tokensUsage = `Usage: jwt-auth tokens <command> [flags]
+
+Commands:
+  revoke --user USER   Revoke every session of a user (id or email address)`
+
+func runTokens(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return &usageError{tokensUsage}
+	}
+
+	fs := flag.NewFlagSet("tokens "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, tokensUsage) }
+	userRef := fs.String("user", "", "user id or email address")
+	fs.Parse(args[1:])
+
+	if args[0] != "revoke" || *userRef == "" || fs.NArg() != 0 {
+		return &usageError{tokensUsage}
+	}
+
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	user, err := a.findUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+	if err := a.authService.RevokeUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked every session of user %d (%s)\n", user.ID, user.Email)
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/interfaces/config"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+const userUsage = `Usage: jwt-auth user <command> [flags] [USER]
+
+USER is a user id or email address.
+
+Commands:
+  add-role --role ROLE USER
+                       Give the user a role, e.g. the first admin
+  create --email EMAIL --username NAME [--verified]
+                       Create a user; the password is read from stdin
+  disable USER         Stop the user from signing in and end their sessions
+  remove-role --role ROLE USER
+                       Take a role from the user and end their sessions
+  reset-password [--send-link] USER
+                       Set a new password read from stdin and end every
+                       session, or with --send-link email a reset link
+  unlock USER          Lift a lockout after failed logins
+  verify USER          Mark the user's email address as verified`
+
+func runUser(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return &usageError{userUsage}
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, userUsage) }
+	email := fs.String("email", "", "email address of the new user")
+	username := fs.String("username", "", "username of the new user")
+	verified := fs.Bool("verified", false, "mark the new user's email as verified")
+	role := fs.String("role", "", "role to add or remove")
+	sendLink := fs.Bool("send-link", false, "email a password reset link instead of setting the password")
+	fs.Parse(args[1:])
+
+	// Check the command line before connecting to anything
+	switch args[0] {
+	case "create":
+		if fs.NArg() != 0 {
+			return &usageError{userUsage}
+		}
+	case "add-role", "remove-role":
+		if *role == "" || fs.NArg() != 1 {
+			return &usageError{userUsage}
+		}
+	case "disable", "reset-password", "unlock", "verify":
+		if fs.NArg() != 1 {
+			return &usageError{userUsage}
+		}
+	default:
+		return &usageError{userUsage}
+	}
+
+	if args[0] == "create" {
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		req := &dto.RegisterRequest{Username: *username, Email: *email, Password: password}
+		// Apply the same rules as the registration endpoint
+		if err := binding.Validator.ValidateStruct(req); err != nil {
+			return fmt.Errorf("invalid user: %w", err)
+		}
+
+		ctx := context.Background()
+		a, err := newApp(ctx, cfg, false)
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+
+		user, err := a.authService.CreateUser(ctx, req, *verified)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created user %d (%s)\n", user.ID, user.Email)
+		return nil
+	}
+
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	user, err := a.findUser(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add-role":
+		if err := a.authService.AssignRole(ctx, user.ID, *role); err != nil {
+			return err
+		}
+		fmt.Printf("Gave user %d (%s) the role %s\n", user.ID, user.Email, *role)
+
+	case "remove-role":
+		if err := a.authService.RemoveRole(ctx, user.ID, *role); err != nil {
+			return err
+		}
+		fmt.Printf("Took the role %s from user %d (%s) and revoked their sessions\n", *role, user.ID, user.Email)
+
+	case "disable":
+		if err := a.authService.DisableUser(ctx, user.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Disabled user %d (%s) and revoked their sessions\n", user.ID, user.Email)
+
+	case "reset-password":
+		if *sendLink {
+			if err := a.authService.InitiatePasswordReset(ctx, user.Email); err != nil {
+				return err
+			}
+			fmt.Printf("Queued a password reset link for %s\n", user.Email)
+			return nil
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		if err := binding.Validator.ValidateStruct(&dto.RegisterRequest{Username: user.Username, Email: user.Email, Password: password}); err != nil {
+			return fmt.Errorf("invalid password: %w", err)
+		}
+		if err := a.authService.SetPassword(ctx, user.ID, password); err != nil {
+			return err
+		}
+		fmt.Printf("Set a new password for user %d (%s) and revoked their sessions\n", user.ID, user.Email)
+
+	case "unlock":
+		if err := a.authService.UnlockUser(ctx, user.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Unlocked user %d (%s)\n", user.ID, user.Email)
+
+	case "verify":
+		if err := a.authService.MarkEmailVerified(ctx, user.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Verified the email address of user %d (%s)\n", user.ID, user.Email)
+	}
+	return nil
+}
+
+// readPassword reads a password from the first line of stdin. Passwords are
+// never taken as arguments, where they would end up in shell history.
+func readPassword() (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -11,6 +11,9 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Scope is a space-delimited subset of the token scopes; empty asks
+	// for all of them
+	Scope string `json:"scope" binding:"max=1024"`
 }
 
 type AuthResponse struct {
@@ -18,15 +21,30 @@ type AuthResponse struct {
 	RefreshToken string         `json:"refresh_token"`
 	TokenType    string         `json:"token_type"`
 	ExpiresIn    int64          `json:"expires_in"`
+	Scope        string         `json:"scope"`
 	User         *entities.User `json:"user"`
 }
 
 type UserClaims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID      int      `json:"user_id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Scope       []string `json:"scope"`
+	// ClientID is the OAuth client the token was issued to, if any
+	ClientID string `json:"client_id,omitempty"`
+	// Principal tells whether the token speaks for a user or for the
+	// client itself. Service tokens have no user, roles or permissions.
+	Principal string `json:"principal"`
 }
 
+// Principals a token can speak for
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
@@ -36,3 +54,44 @@ type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkResponse carries the nonce that binds the emailed link to the
+// client that asked for it. Browsers also receive it as a cookie.
+type MagicLinkResponse struct {
+	Message string `json:"message"`
+	Nonce   string `json:"nonce"`
+}
+
+type RedeemMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+	// Nonce is only needed when the client didn't keep the cookie
+	Nonce string `json:"nonce"`
+}
+
+type EmailCodeRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type EmailCodeLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// StepUpRequest asks for a code that authorizes one sensitive action
+type StepUpRequest struct {
+	Action string `json:"action" binding:"required,oneof=change_password change_email add_passkey"`
+}
+
+type ChangePasswordRequest struct {
+	Code        string `json:"code" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	Code  string `json:"code" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
+}
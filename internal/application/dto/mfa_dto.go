@@ -0,0 +1,34 @@
+package dto
+
+// MFAChallengeResponse is returned by login instead of tokens when the
+// account needs a second factor
+type MFAChallengeResponse struct {
+	Error     string   `json:"error"`
+	Message   string   `json:"message"`
+	MFAToken  string   `json:"mfa_token"`
+	Methods   []string `json:"methods"`
+	ExpiresIn int64    `json:"expires_in"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is a code from the authenticator app or an unused recovery code
+	Code string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest confirms a sensitive MFA change with a current code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI is the otpauth:// URI to render as a QR code
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodesResponse holds recovery codes in clear text. They are only
+// ever shown this once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
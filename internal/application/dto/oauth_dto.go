@@ -0,0 +1,138 @@
+package dto
+
+import "time"
+
+// AuthorizationRequest is the query of the authorization endpoint
+// (RFC 6749 section 4.1.1 with PKCE, RFC 7636, and the OpenID Connect nonce)
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+}
+
+// Authentication method references (RFC 8176) reported in ID tokens
+const (
+	AMRPassword        = "pwd"
+	AMROneTimePassword = "otp"
+	AMRMultiFactor     = "mfa"
+	// RFC 8176 has no value for recovery codes; they are something the
+	// user knows
+	AMRRecoveryCode = "kba"
+)
+
+// Authentication is how the user signed in to approve a request
+type Authentication struct {
+	UserID int
+	Time   time.Time
+	// Methods are the RFC 8176 amr values, e.g. "pwd" and "otp"
+	Methods []string
+}
+
+// AuthorizationPrompt is what the user is asked to approve
+type AuthorizationPrompt struct {
+	ClientName string
+	Scope      []string
+}
+
+// OAuthTokenRequest is the form posted to the token endpoint. A client
+// secret sent with HTTP Basic authentication is moved into the form.
+type OAuthTokenRequest struct {
+	GrantType           string `form:"grant_type"`
+	ClientID            string `form:"client_id"`
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	Code                string `form:"code"`
+	RedirectURI         string `form:"redirect_uri"`
+	CodeVerifier        string `form:"code_verifier"`
+	RefreshToken        string `form:"refresh_token"`
+	Scope               string `form:"scope"`
+	DeviceCode          string `form:"device_code"`
+}
+
+// DeviceAuthorizationResponse tells a device how the user approves it and
+// how it polls for the outcome (RFC 8628 section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// OAuthTokenResponse is the token endpoint's answer (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// OAuthErrorResponse is an OAuth 2.0 error body (RFC 6749 section 5.2)
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// ConsentForm is the user's part of an approval page: their credentials
+// or second factor, and their decision
+type ConsentForm struct {
+	Email    string `form:"email"`
+	Password string `form:"password"`
+	MFAToken string `form:"mfa_token"`
+	Code     string `form:"code"`
+	// Decision is "allow" or "deny"
+	Decision string `form:"decision"`
+}
+
+// AuthorizationLoginForm is posted by the authorization page: the request
+// again and the user's consent
+type AuthorizationLoginForm struct {
+	AuthorizationRequest
+	ConsentForm
+}
+
+// DeviceVerificationForm is posted by the device verification page. The
+// user code comes alone first, then with the user's consent.
+type DeviceVerificationForm struct {
+	UserCode string `form:"user_code"`
+	ConsentForm
+}
+
+// EndSessionRequest is an OpenID Connect RP-initiated logout request
+type EndSessionRequest struct {
+	IDTokenHint           string `form:"id_token_hint"`
+	ClientID              string `form:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri"`
+	State                 string `form:"state"`
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document
+type OpenIDConfiguration struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserInfoEndpoint                           string   `json:"userinfo_endpoint"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	ACRValuesSupported                         []string `json:"acr_values_supported"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=64"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"dive,required,max=128"`
+}
+
+type GrantPermissionRequest struct {
+	Permission string `json:"permission" binding:"required,max=128"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,max=64"`
+}
@@ -4,6 +4,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -15,50 +16,244 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// AuthConfig holds the settings of the auth service
+type AuthConfig struct {
+	// PasswordResetURL is the page the reset link points to; the token is
+	// appended as the "token" query parameter
+	PasswordResetURL    string
+	PasswordResetExpiry time.Duration
+	// EmailVerificationURL is the endpoint the verification link points to;
+	// the token is appended as the last path segment
+	EmailVerificationURL      string
+	EmailVerificationSecret   string
+	EmailVerificationExpiry   time.Duration
+	EmailVerificationCooldown time.Duration
+	// RequireEmailVerification makes Login refuse unverified accounts
+	RequireEmailVerification bool
+	// MFAChallengeExpiry is how long a user has to enter their second factor
+	MFAChallengeExpiry time.Duration
+	// PasskeyTimeout is how long a WebAuthn ceremony may take
+	PasskeyTimeout time.Duration
+	// MagicLinkURL is the page the sign-in link points to; the token is
+	// appended as the "token" query parameter
+	MagicLinkURL    string
+	MagicLinkExpiry time.Duration
+	// EmailOTPExpiry is how long an emailed one-time code is valid
+	EmailOTPExpiry time.Duration
+	// LockoutThreshold wrong passwords in a row lock the account for
+	// LockoutBaseDuration, doubling with every further failure up to
+	// LockoutMaxDuration. Zero turns lockout off.
+	LockoutThreshold    int
+	LockoutBaseDuration time.Duration
+	LockoutMaxDuration  time.Duration
+	// AccountUnlockURL is the endpoint the unlock link points to; the token
+	// is appended as the last path segment
+	AccountUnlockURL    string
+	AccountUnlockExpiry time.Duration
+	// DefaultRole is assigned to every new user; empty assigns none
+	DefaultRole string
+	// Scopes are the scopes of a full user token. Clients may ask for a
+	// subset at login or refresh.
+	Scopes []string
+	// OAuthCodeExpiry is how long an authorization code can be redeemed
+	OAuthCodeExpiry time.Duration
+	// DeviceCodeExpiry is how long a device has to get its code approved,
+	// polling no more often than DevicePollInterval
+	DeviceCodeExpiry   time.Duration
+	DevicePollInterval time.Duration
+	// Issuer is the public base URL of the server: the iss of ID tokens and
+	// the root of the OpenID Connect endpoints
+	Issuer string
+}
+
 type authServiceImpl struct {
-	userRepo       repositories.UserRepository
-	jwtManager     services.JWTManager
-	emailService   services.EmailService
-	tokenBlacklist services.TokenBlacklistService
+	userRepo               repositories.UserRepository
+	refreshTokenRepo       repositories.RefreshTokenRepository
+	securityEventRepo      repositories.SecurityEventRepository
+	oneTimeTokenRepo       repositories.OneTimeTokenRepository
+	totpRepo               repositories.TOTPRepository
+	recoveryCodeRepo       repositories.RecoveryCodeRepository
+	webAuthnCredentialRepo repositories.WebAuthnCredentialRepository
+	roleRepo               repositories.RoleRepository
+	oauthClientRepo        repositories.OAuthClientRepository
+	txManager              repositories.TransactionManager
+	jwtManager             services.JWTManager
+	totpService            services.TOTPService
+	webAuthnService        services.WebAuthnService
+	emailService           services.EmailService
+	tokenBlacklist         services.TokenBlacklistService
+	cooldownService        services.CooldownService
+	challengeStore         services.ChallengeStore
+	oneTimeCodeStore       services.OneTimeCodeStore
+	deviceGrantStore       services.DeviceGrantStore
+	config                 *AuthConfig
 }
 
-func NewAuthService(userRepo repositories.UserRepository, jwtManager services.JWTManager, emailService services.EmailService, tokenBlacklist services.TokenBlacklistService) services.AuthService {
+// AuthDeps are what the auth service is built on. Every field is required
+// except TokenBlacklist.
+type AuthDeps struct {
+	UserRepo               repositories.UserRepository
+	RefreshTokenRepo       repositories.RefreshTokenRepository
+	SecurityEventRepo      repositories.SecurityEventRepository
+	OneTimeTokenRepo       repositories.OneTimeTokenRepository
+	TOTPRepo               repositories.TOTPRepository
+	RecoveryCodeRepo       repositories.RecoveryCodeRepository
+	WebAuthnCredentialRepo repositories.WebAuthnCredentialRepository
+	RoleRepo               repositories.RoleRepository
+	OAuthClientRepo        repositories.OAuthClientRepository
+	TxManager              repositories.TransactionManager
+	JWTManager             services.JWTManager
+	TOTPService            services.TOTPService
+	WebAuthnService        services.WebAuthnService
+	EmailService           services.EmailService
+	TokenBlacklist         services.TokenBlacklistService
+	CooldownService        services.CooldownService
+	ChallengeStore         services.ChallengeStore
+	OneTimeCodeStore       services.OneTimeCodeStore
+	DeviceGrantStore       services.DeviceGrantStore
+	Config                 *AuthConfig
+}
+
+func NewAuthService(deps AuthDeps) services.AuthService {
 	return &authServiceImpl{
-		userRepo:       userRepo,
-		jwtManager:     jwtManager,
-		emailService:   emailService,
-		tokenBlacklist: tokenBlacklist,
+		userRepo:               deps.UserRepo,
+		refreshTokenRepo:       deps.RefreshTokenRepo,
+		securityEventRepo:      deps.SecurityEventRepo,
+		oneTimeTokenRepo:       deps.OneTimeTokenRepo,
+		totpRepo:               deps.TOTPRepo,
+		recoveryCodeRepo:       deps.RecoveryCodeRepo,
+		webAuthnCredentialRepo: deps.WebAuthnCredentialRepo,
+		roleRepo:               deps.RoleRepo,
+		oauthClientRepo:        deps.OAuthClientRepo,
+		txManager:              deps.TxManager,
+		jwtManager:             deps.JWTManager,
+		totpService:            deps.TOTPService,
+		webAuthnService:        deps.WebAuthnService,
+		emailService:           deps.EmailService,
+		tokenBlacklist:         deps.TokenBlacklist,
+		cooldownService:        deps.CooldownService,
+		challengeStore:         deps.ChallengeStore,
+		oneTimeCodeStore:       deps.OneTimeCodeStore,
+		deviceGrantStore:       deps.DeviceGrantStore,
+		config:                 deps.Config,
 	}
 }
 
 func (s *authServiceImpl) Logout(ctx context.Context, token string) error {
-	// Blacklist the token using the injected tokenBlacklist service
-	// For demo, use 1 hour expiration
+	// Blacklist the token using the injected tokenBlacklist service.
+	// The entry only needs to live as long as the token itself.
 	if s.tokenBlacklist != nil {
-		err := s.tokenBlacklist.BlacklistToken(ctx, token, int64(time.Hour.Seconds()))
+		err := s.tokenBlacklist.BlacklistToken(ctx, token, s.remainingLifetime(token))
 		if err != nil {
 			return err
 		}
 	}
+	// End the session so its refresh token can't mint new access tokens
+	if claims, err := s.jwtManager.ValidateToken(token); err == nil {
+		if familyID, _ := claims["fid"].(string); familyID != "" {
+			return s.revokeFamily(ctx, familyID)
+		}
+	}
 	return nil
 }
 
+// remainingLifetime returns the number of seconds until the access token
+// expires, falling back to the full access token lifetime when it can't be read.
+func (s *authServiceImpl) remainingLifetime(token string) int64 {
+	ttl := int64(s.jwtManager.AccessTokenExpiry().Seconds())
+	claims, err := s.jwtManager.ValidateToken(token)
+	if err != nil {
+		return ttl
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		if remaining := int64(exp) - time.Now().Unix(); remaining > 0 {
+			return remaining
+		}
+		return 1
+	}
+	return ttl
+}
+
 func (s *authServiceImpl) InitiatePasswordReset(ctx context.Context, email string) error {
-	// TODO: Implement password reset initiation using emailService
-	return nil
+	// Never reveal whether the email belongs to an account
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := generateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	// The token and its email are stored together so neither exists without the other
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Only the most recently requested link stays valid
+		if err := s.oneTimeTokenRepo.DeleteByUser(ctx, user.ID, entities.OneTimeTokenPasswordReset); err != nil {
+			return err
+		}
+		if err := s.oneTimeTokenRepo.Create(ctx, &entities.OneTimeToken{
+			TokenHash: hashToken(token),
+			UserID:    user.ID,
+			Purpose:   entities.OneTimeTokenPasswordReset,
+			ExpiresAt: time.Now().Add(s.config.PasswordResetExpiry),
+		}); err != nil {
+			return fmt.Errorf("failed to store password reset token: %w", err)
+		}
+
+		if err := s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplatePasswordReset, map[string]interface{}{
+			"Username":  user.Username,
+			"Link":      s.config.PasswordResetURL + "?token=" + url.QueryEscape(token),
+			"ExpiresIn": s.config.PasswordResetExpiry.String(),
+		}); err != nil {
+			return fmt.Errorf("failed to send password reset email: %w", err)
+		}
+		return nil
+	})
 }
 
 func (s *authServiceImpl) ResetPassword(ctx context.Context, token, newPassword string) error {
-	// TODO: Implement password reset logic
-	return nil
-}
+	resetToken, err := s.oneTimeTokenRepo.Consume(ctx, hashToken(token), entities.OneTimeTokenPasswordReset)
+	if err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
 
-func (s *authServiceImpl) VerifyEmail(ctx context.Context, token string) error {
-	// TODO: Implement email verification logic
+	user, err := s.userRepo.GetByID(ctx, resetToken.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = string(hashedPassword)
+	// Receiving the link proves control of the address
+	user.EmailVerified = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must not stay signed in
+	if err := s.revokeAllSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, user.ID, entities.SecurityEventPasswordReset, "password reset via email link")
 	return nil
 }
 
 func (s *authServiceImpl) Register(ctx context.Context, req *dto.RegisterRequest) (*dto.AuthResponse, error) {
+	user, err := s.createUser(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, tokenSession{scope: s.config.Scopes})
+}
+
+// createUser stores a new account with the default role. Unless the address
+// is already verified, the verification email is queued with the account so
+// it can't get lost.
+func (s *authServiceImpl) createUser(ctx context.Context, req *dto.RegisterRequest, emailVerified bool) (*entities.User, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.GetByEmail(ctx, req.Email)
 	if existingUser != nil {
@@ -73,104 +268,215 @@ func (s *authServiceImpl) Register(ctx context.Context, req *dto.RegisterRequest
 
 	// Create user
 	user := &entities.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: string(hashedPassword),
+		Username:      req.Username,
+		Email:         req.Email,
+		Password:      string(hashedPassword),
+		EmailVerified: emailVerified,
 	}
 
-	if err := s.userRepo.Create(ctx, user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		if s.config.DefaultRole != "" {
+			if err := s.roleRepo.AssignRole(ctx, user.ID, s.config.DefaultRole); err != nil {
+				return err
+			}
+		}
+		if user.EmailVerified {
+			return nil
+		}
+		return s.sendVerificationEmail(ctx, user)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return user, nil
+}
 
-	// Generate tokens using domain interface
-	claims := map[string]interface{}{
-		"username": user.Username,
-		"email":    user.Email,
-	}
-	userID := fmt.Sprintf("%d", user.ID)
-	accessToken, err := s.jwtManager.GenerateToken(userID, claims)
+func (s *authServiceImpl) Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error) {
+	scope, err := grantScope(req.Scope, s.config.Scopes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
 	}
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(userID)
+
+	user, err := s.Authenticate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
 	}
-	return &dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int64(time.Hour.Seconds()), // 1 hour
-		User:         user,
-	}, nil
+	return s.issueTokens(ctx, user, tokenSession{scope: scope})
 }
 
-func (s *authServiceImpl) Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error) {
+func (s *authServiceImpl) Authenticate(ctx context.Context, req *dto.LoginRequest) (*entities.User, error) {
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, fmt.Errorf("invalid email or password")
+		return nil, errInvalidCredentials
+	}
+	if err := checkLockout(user); err != nil {
+		return nil, err
 	}
 
 	// Compare passwords
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid email or password")
+		return nil, s.recordFailedLogin(ctx, user)
+	}
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.userRepo.ResetFailedLogins(ctx, user.ID); err != nil {
+			return nil, err
+		}
 	}
 
-	// Generate tokens using domain interface
-	claims := map[string]interface{}{
-		"username": user.Username,
-		"email":    user.Email,
+	if user.DisabledAt != nil {
+		return nil, services.ErrAccountDisabled
 	}
-	userID := fmt.Sprintf("%d", user.ID)
-	accessToken, err := s.jwtManager.GenerateToken(userID, claims)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	if s.config.RequireEmailVerification && !user.EmailVerified {
+		return nil, services.ErrEmailNotVerified
 	}
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(userID)
+
+	// Accounts with a second factor get a challenge instead of tokens
+	methods, err := s.mfaMethods(ctx, user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
 	}
-	return &dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int64(time.Hour.Seconds()), // 1 hour
-		User:         user,
-	}, nil
+	if len(methods) > 0 {
+		scope, err := grantScope(req.Scope, s.config.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.startMFAChallenge(ctx, user, methods, scope)
+	}
+
+	return user, nil
+}
+
+func (s *authServiceImpl) RefreshToken(ctx context.Context, refreshToken, scope string) (*dto.AuthResponse, error) {
+	return s.refreshSession(ctx, refreshToken, scope, "")
 }
 
-func (s *authServiceImpl) RefreshToken(ctx context.Context, refreshToken string) (*dto.AuthResponse, error) {
+// refreshSession rotates the refresh token of a session. Sessions granted
+// to an OAuth client can only be refreshed by that client, and the others
+// only without one.
+func (s *authServiceImpl) refreshSession(ctx context.Context, refreshToken, scope, clientID string) (*dto.AuthResponse, error) {
 	// Validate refresh token
-	claims, err := s.jwtManager.ValidateToken(refreshToken)
+	claims, err := s.jwtManager.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid user_id in token claims")
+	if owner, _ := claims["client_id"].(string); owner != clientID {
+		return nil, fmt.Errorf("invalid refresh token: issued to another client")
+	}
+	// The session can only be narrowed, never widened
+	granted, err := grantScope(scope, s.tokenScope(claims))
+	if err != nil {
+		return nil, err
+	}
+	tokenID, _ := claims["jti"].(string)
+	record, err := s.refreshTokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token: %w", err)
+	}
+	if record.RevokedAt != nil {
+		return nil, fmt.Errorf("refresh token has been revoked")
+	}
+
+	// Refresh tokens are single-use. Seeing one again means it was copied,
+	// so nobody holding a token from this family can be trusted anymore.
+	redeemed, err := s.refreshTokenRepo.MarkRedeemed(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !redeemed {
+		if err := s.revokeFamily(ctx, record.FamilyID); err != nil {
+			return nil, err
+		}
+		s.recordSecurityEvent(ctx, record.UserID, entities.SecurityEventRefreshTokenReuse,
+			fmt.Sprintf("refresh token %s reused; family %s revoked", record.ID, record.FamilyID))
+		return nil, fmt.Errorf("refresh token reuse detected")
+	}
+
+	// Get user details so the new access token carries current profile claims
+	user, err := s.userRepo.GetByID(ctx, record.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
 	}
-	// Generate new tokens
+	if user.DisabledAt != nil {
+		return nil, services.ErrAccountDisabled
+	}
+	return s.issueTokens(ctx, user, tokenSession{familyID: record.FamilyID, scope: granted, clientID: clientID})
+}
+
+// tokenSession describes the session tokens are issued for
+type tokenSession struct {
+	// familyID is the refresh token family; empty starts a new one
+	familyID string
+	scope    []string
+	// clientID is the OAuth client the session was granted to, if any
+	clientID string
+}
+
+// issueTokens generates a new access/refresh token pair for the user
+func (s *authServiceImpl) issueTokens(ctx context.Context, user *entities.User, session tokenSession) (*dto.AuthResponse, error) {
+	familyID, scope := session.familyID, session.scope
+	var err error
+	if familyID == "" {
+		if familyID, err = generateRandomID(); err != nil {
+			return nil, err
+		}
+	}
+	refreshTokenID, err := generateRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	roles, permissions, err := s.userAccess(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate tokens using domain interface
+	claims := map[string]interface{}{
+		"username":    user.Username,
+		"email":       user.Email,
+		"fid":         familyID,
+		"roles":       roles,
+		"permissions": permissions,
+		"scope":       strings.Join(scope, " "),
+	}
+	refreshClaims := map[string]interface{}{
+		"jti":   refreshTokenID,
+		"fid":   familyID,
+		"scope": strings.Join(scope, " "),
+	}
+	if session.clientID != "" {
+		claims["client_id"] = session.clientID
+		refreshClaims["client_id"] = session.clientID
+	}
+	userID := fmt.Sprintf("%d", user.ID)
 	accessToken, err := s.jwtManager.GenerateToken(userID, claims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
-	newRefreshToken, err := s.jwtManager.GenerateRefreshToken(userID)
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(userID, refreshClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
-	// Get user details
-	userIntID := 0
-	fmt.Sscanf(userID, "%d", &userIntID)
-	user, err := s.userRepo.GetByID(ctx, userIntID)
-	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+
+	if err := s.refreshTokenRepo.Create(ctx, &entities.RefreshToken{
+		ID:        refreshTokenID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.jwtManager.RefreshTokenExpiry()),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
+
 	return &dto.AuthResponse{
 		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
+		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
-		ExpiresIn:    int64(time.Hour.Seconds()), // 1 hour
+		ExpiresIn:    int64(s.jwtManager.AccessTokenExpiry().Seconds()),
+		Scope:        strings.Join(scope, " "),
 		User:         user,
 	}, nil
 }
@@ -192,14 +498,39 @@ func (s *authServiceImpl) ValidateToken(ctx context.Context, token string) (*dto
 	if err != nil {
 		return nil, err
 	}
+	// Check if the token's session has been revoked
+	if familyID, _ := claims["fid"].(string); familyID != "" && s.tokenBlacklist != nil {
+		revoked, err := s.tokenBlacklist.IsFamilyRevoked(ctx, familyID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, fmt.Errorf("token has been revoked")
+		}
+	}
+	clientID, _ := claims["client_id"].(string)
+	// A service token's subject is the client, not a user
+	if principal, _ := claims["principal"].(string); principal == dto.PrincipalService {
+		return &dto.UserClaims{
+			Scope:     s.tokenScope(claims),
+			ClientID:  clientID,
+			Principal: dto.PrincipalService,
+		}, nil
+	}
+
 	userID, _ := claims["user_id"].(string)
 	username, _ := claims["username"].(string)
 	email, _ := claims["email"].(string)
 	userIntID := 0
 	fmt.Sscanf(userID, "%d", &userIntID)
 	return &dto.UserClaims{
-		UserID:   userIntID,
-		Username: username,
-		Email:    email,
+		UserID:      userIntID,
+		Username:    username,
+		Email:       email,
+		Roles:       stringsClaim(claims["roles"]),
+		Permissions: stringsClaim(claims["permissions"]),
+		Scope:       s.tokenScope(claims),
+		ClientID:    clientID,
+		Principal:   dto.PrincipalUser,
 	}, nil
 }
@@ -3,10 +3,11 @@ package services_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"jwt-auth/internal/application/dto"
 	appservices "jwt-auth/internal/application/services"
-	"jwt-auth/internal/infrastructure/jwt"
+	"jwt-auth/internal/domain/services"
 	redisService "jwt-auth/internal/infrastructure/redis"
 
 	"github.com/redis/go-redis/v9"
@@ -15,15 +16,22 @@ import (
 func TestAuthService_Integration(t *testing.T) {
 	// Setup dependencies
 	userRepo := newMockUserRepository()
-	jwtManager := jwt.NewJWTManager()
+	jwtManager := newTestJWTManager()
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     ":6379",                   // Use a real or mock Redis instance
 		Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
 	})
 	emailSvc := newMockEmailService()
 	tokenBlacklist := redisService.NewTokenBlacklistService(redisClient)
+	oneTimeCodeStore := redisService.NewOneTimeCodeStore(redisClient, 5, time.Minute)
 
-	authService := appservices.NewAuthService(userRepo, jwtManager, emailSvc, tokenBlacklist)
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:         userRepo,
+		JWTManager:       jwtManager,
+		EmailService:     emailSvc,
+		TokenBlacklist:   tokenBlacklist,
+		OneTimeCodeStore: oneTimeCodeStore,
+	})
 
 	t.Run("Full auth flow", func(t *testing.T) {
 		ctx := context.Background()
@@ -65,7 +73,7 @@ func TestAuthService_Integration(t *testing.T) {
 		}
 
 		// 4. Refresh Token
-		refreshResp, err := authService.RefreshToken(ctx, loginResp.RefreshToken)
+		refreshResp, err := authService.RefreshToken(ctx, loginResp.RefreshToken, "")
 		if err != nil {
 			t.Fatalf("Token refresh failed: %v", err)
 		}
@@ -82,5 +90,24 @@ func TestAuthService_Integration(t *testing.T) {
 		if _, err := authService.ValidateToken(ctx, loginResp.AccessToken); err == nil {
 			t.Error("Should not be able to use token after logout")
 		}
+
+		// 6. Sign in with an emailed code
+		if err := authService.RequestLoginCode(ctx, registerReq.Email); err != nil {
+			t.Fatalf("RequestLoginCode failed: %v", err)
+		}
+		codes := emailSvc.withTemplate(services.EmailTemplateOneTimeCode)
+		if len(codes) != 1 {
+			t.Fatalf("expected a code email, got %d", len(codes))
+		}
+		code := codes[0].Data["Code"].(string)
+		if _, err := authService.LoginWithCode(ctx, registerReq.Email, "000000"+code); err == nil {
+			t.Error("Should not be able to sign in with a wrong code")
+		}
+		if _, err := authService.LoginWithCode(ctx, registerReq.Email, code); err != nil {
+			t.Fatalf("LoginWithCode failed: %v", err)
+		}
+		if _, err := authService.LoginWithCode(ctx, registerReq.Email, code); err == nil {
+			t.Error("Should not be able to use a code twice")
+		}
 	})
 }
@@ -2,17 +2,25 @@ package services_test
 
 import (
 	"context"
+	"errors"
+	"regexp"
 	"testing"
+	"time"
 
 	"jwt-auth/internal/application/dto"
 	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
 	"jwt-auth/internal/infrastructure/jwt"
 )
 
 func TestAuthService_Register(t *testing.T) {
 	userRepo := newMockUserRepository()
-	jwtManager := jwt.NewJWTManager()
-	authService := appservices.NewAuthService(userRepo, jwtManager, nil, nil)
+	jwtManager := newTestJWTManager()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:   userRepo,
+		JWTManager: jwtManager,
+	})
 
 	t.Run("Valid registration", func(t *testing.T) {
 		req := &dto.RegisterRequest{
@@ -41,3 +49,453 @@ func TestAuthService_Register(t *testing.T) {
 		}
 	})
 }
+
+func TestAuthService_RegisterQueuesVerificationEmailWithUser(t *testing.T) {
+	txManager := newMockTransactionManager()
+	emailService := newMockEmailService()
+	emailService.err = errors.New("outbox unavailable")
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		TxManager:    txManager,
+		EmailService: emailService,
+	})
+
+	// The account must not be created without its verification email
+	_, err := authService.Register(context.Background(), &dto.RegisterRequest{
+		Username: "queued",
+		Email:    "queued@example.com",
+		Password: "password123",
+	})
+	if err == nil {
+		t.Fatal("expected registration to fail when the email can't be queued")
+	}
+	if txManager.rolledBack != 1 || txManager.committed != 0 {
+		t.Errorf("expected the registration to be rolled back, got %d commits and %d rollbacks", txManager.committed, txManager.rolledBack)
+	}
+}
+
+func TestAuthService_TokenTypes(t *testing.T) {
+	userRepo := newMockUserRepository()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo: userRepo,
+	})
+	ctx := context.Background()
+
+	resp, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "tokenuser",
+		Email:    "token@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	t.Run("Access token validates", func(t *testing.T) {
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if claims.Email != "token@example.com" || claims.UserID != resp.User.ID {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+	})
+
+	t.Run("Refresh token rejected as access token", func(t *testing.T) {
+		if _, err := authService.ValidateToken(ctx, resp.RefreshToken); err == nil {
+			t.Error("expected refresh token to be rejected as access token")
+		}
+	})
+
+	t.Run("Access token rejected as refresh token", func(t *testing.T) {
+		if _, err := authService.RefreshToken(ctx, resp.AccessToken, ""); err == nil {
+			t.Error("expected access token to be rejected as refresh token")
+		}
+	})
+
+	t.Run("Token signed with another key rejected", func(t *testing.T) {
+		other, _ := jwt.NewJWTManager(&jwt.JWTConfig{
+			SecretKey:          "another-secret",
+			AccessTokenExpiry:  time.Hour,
+			RefreshTokenExpiry: time.Hour,
+		})
+		forged, err := other.GenerateToken("1", map[string]interface{}{"email": "token@example.com"})
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, forged); err == nil {
+			t.Error("expected token signed with another key to be rejected")
+		}
+	})
+
+	t.Run("Expired token rejected", func(t *testing.T) {
+		expired, _ := jwt.NewJWTManager(&jwt.JWTConfig{
+			SecretKey:          "test-secret-key",
+			AccessTokenExpiry:  -time.Minute,
+			RefreshTokenExpiry: -time.Minute,
+		})
+		token, err := expired.GenerateToken("1", nil)
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, token); err == nil {
+			t.Error("expected expired token to be rejected")
+		}
+	})
+}
+
+func TestAuthService_RefreshTokenRotation(t *testing.T) {
+	userRepo := newMockUserRepository()
+	refreshTokenRepo := newMockRefreshTokenRepository()
+	securityEventRepo := newMockSecurityEventRepository()
+	tokenBlacklist := newMockTokenBlacklist()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		SecurityEventRepo: securityEventRepo,
+		TokenBlacklist:    tokenBlacklist,
+	})
+	ctx := context.Background()
+
+	loginResp, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "rotation",
+		Email:    "rotation@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	refreshed, err := authService.RefreshToken(ctx, loginResp.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	if refreshed.RefreshToken == loginResp.RefreshToken {
+		t.Error("expected a new refresh token")
+	}
+
+	t.Run("Redeemed token cannot be reused", func(t *testing.T) {
+		if _, err := authService.RefreshToken(ctx, loginResp.RefreshToken, ""); err == nil {
+			t.Fatal("expected reuse of a redeemed refresh token to fail")
+		}
+		if len(securityEventRepo.events) != 1 || securityEventRepo.events[0].Type != entities.SecurityEventRefreshTokenReuse {
+			t.Errorf("expected a reuse security event, got %+v", securityEventRepo.events)
+		}
+	})
+
+	t.Run("Reuse revokes the whole family", func(t *testing.T) {
+		if _, err := authService.RefreshToken(ctx, refreshed.RefreshToken, ""); err == nil {
+			t.Error("expected the latest refresh token of the family to be revoked")
+		}
+		if _, err := authService.ValidateToken(ctx, refreshed.AccessToken); err == nil {
+			t.Error("expected access tokens of the family to be revoked")
+		}
+		if _, err := authService.ValidateToken(ctx, loginResp.AccessToken); err == nil {
+			t.Error("expected access tokens of the family to be revoked")
+		}
+	})
+
+	t.Run("Other sessions are unaffected", func(t *testing.T) {
+		other, err := authService.Login(ctx, &dto.LoginRequest{Email: "rotation@example.com", Password: "password123"})
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, other.AccessToken); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := authService.RefreshToken(ctx, other.RefreshToken, ""); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Logout ends the session", func(t *testing.T) {
+		session, err := authService.Login(ctx, &dto.LoginRequest{Email: "rotation@example.com", Password: "password123"})
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if err := authService.Logout(ctx, session.AccessToken); err != nil {
+			t.Fatalf("Logout failed: %v", err)
+		}
+		if _, err := authService.RefreshToken(ctx, session.RefreshToken, ""); err == nil {
+			t.Error("expected refresh token to be revoked after logout")
+		}
+	})
+}
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func TestAuthService_PasswordReset(t *testing.T) {
+	userRepo := newMockUserRepository()
+	oneTimeTokenRepo := newMockOneTimeTokenRepository()
+	emailService := newMockEmailService()
+	tokenBlacklist := newMockTokenBlacklist()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:         userRepo,
+		OneTimeTokenRepo: oneTimeTokenRepo,
+		EmailService:     emailService,
+		TokenBlacklist:   tokenBlacklist,
+	})
+	ctx := context.Background()
+
+	session, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "forgetful",
+		Email:    "forgetful@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	// Ignore the verification email sent on registration
+	emailService.sent = nil
+
+	t.Run("Unknown email is not revealed", func(t *testing.T) {
+		if err := authService.InitiatePasswordReset(ctx, "nobody@example.com"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(emailService.sent) != 0 {
+			t.Errorf("expected no email to be sent, got %d", len(emailService.sent))
+		}
+	})
+
+	if err := authService.InitiatePasswordReset(ctx, "forgetful@example.com"); err != nil {
+		t.Fatalf("InitiatePasswordReset failed: %v", err)
+	}
+	resets := emailService.withTemplate(services.EmailTemplatePasswordReset)
+	if len(resets) != 1 || resets[0].To != "forgetful@example.com" {
+		t.Fatalf("expected a reset email, got %+v", emailService.sent)
+	}
+	match := resetTokenPattern.FindStringSubmatch(resets[0].Data["Link"].(string))
+	if match == nil {
+		t.Fatalf("reset link not found in email: %v", resets[0].Data)
+	}
+	token := match[1]
+
+	t.Run("Token is stored hashed", func(t *testing.T) {
+		if _, ok := oneTimeTokenRepo.tokens[token]; ok {
+			t.Error("reset token must not be stored in plain text")
+		}
+	})
+
+	t.Run("Reset changes the password and ends sessions", func(t *testing.T) {
+		if err := authService.ResetPassword(ctx, token, "newpassword456"); err != nil {
+			t.Fatalf("ResetPassword failed: %v", err)
+		}
+		if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "forgetful@example.com", Password: "password123"}); err == nil {
+			t.Error("old password should no longer work")
+		}
+		if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "forgetful@example.com", Password: "newpassword456"}); err != nil {
+			t.Errorf("new password should work: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, session.AccessToken); err == nil {
+			t.Error("existing access tokens should be revoked")
+		}
+		if _, err := authService.RefreshToken(ctx, session.RefreshToken, ""); err == nil {
+			t.Error("existing refresh tokens should be revoked")
+		}
+		if alerts := emailService.withTemplate(services.EmailTemplateSecurityAlert); len(alerts) != 1 {
+			t.Errorf("expected a security alert, got %d", len(alerts))
+		}
+	})
+
+	t.Run("Token is single-use", func(t *testing.T) {
+		if err := authService.ResetPassword(ctx, token, "anotherpassword"); err == nil {
+			t.Error("expected reused reset token to be rejected")
+		}
+	})
+
+	t.Run("Only the latest link is valid", func(t *testing.T) {
+		authService.InitiatePasswordReset(ctx, "forgetful@example.com")
+		authService.InitiatePasswordReset(ctx, "forgetful@example.com")
+		resets := emailService.withTemplate(services.EmailTemplatePasswordReset)
+		first := resetTokenPattern.FindStringSubmatch(resets[1].Data["Link"].(string))[1]
+		second := resetTokenPattern.FindStringSubmatch(resets[2].Data["Link"].(string))[1]
+		if err := authService.ResetPassword(ctx, first, "stalepassword"); err == nil {
+			t.Error("expected superseded reset token to be rejected")
+		}
+		if err := authService.ResetPassword(ctx, second, "freshpassword"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+var verificationLinkPattern = regexp.MustCompile(`/verify-email/([A-Za-z0-9_.-]+)`)
+
+func TestAuthService_EmailVerification(t *testing.T) {
+	userRepo := newMockUserRepository()
+	emailService := newMockEmailService()
+	config := newTestAuthConfig()
+	config.RequireEmailVerification = true
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:     userRepo,
+		EmailService: emailService,
+		Config:       config,
+	})
+	ctx := context.Background()
+
+	if _, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "unverified",
+		Email:    "unverified@example.com",
+		Password: "password123",
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if len(emailService.sent) != 1 {
+		t.Fatalf("expected a verification email on registration, got %d", len(emailService.sent))
+	}
+	match := verificationLinkPattern.FindStringSubmatch(emailService.sent[0].Data["Link"].(string))
+	if match == nil {
+		t.Fatalf("verification link not found in email: %v", emailService.sent[0].Data)
+	}
+	token := match[1]
+
+	login := &dto.LoginRequest{Email: "unverified@example.com", Password: "password123"}
+
+	t.Run("Login refused until verified", func(t *testing.T) {
+		if _, err := authService.Login(ctx, login); !errors.Is(err, services.ErrEmailNotVerified) {
+			t.Errorf("expected ErrEmailNotVerified, got %v", err)
+		}
+	})
+
+	t.Run("Tampered token rejected", func(t *testing.T) {
+		if err := authService.VerifyEmail(ctx, token[:len(token)-2]+"xx"); err == nil {
+			t.Error("expected tampered token to be rejected")
+		}
+	})
+
+	t.Run("Resend respects cooldown", func(t *testing.T) {
+		if err := authService.ResendVerificationEmail(ctx, "unverified@example.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(emailService.sent) != 2 {
+			t.Errorf("expected verification email to be resent")
+		}
+		if err := authService.ResendVerificationEmail(ctx, "unverified@example.com"); !errors.Is(err, services.ErrCooldownActive) {
+			t.Errorf("expected ErrCooldownActive, got %v", err)
+		}
+	})
+
+	t.Run("Verification allows login", func(t *testing.T) {
+		if err := authService.VerifyEmail(ctx, token); err != nil {
+			t.Fatalf("VerifyEmail failed: %v", err)
+		}
+		user, _ := userRepo.GetByEmail(ctx, "unverified@example.com")
+		if !user.EmailVerified {
+			t.Error("expected email to be marked verified")
+		}
+		if _, err := authService.Login(ctx, login); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Token bound to the address it was sent to", func(t *testing.T) {
+		user, _ := userRepo.GetByEmail(ctx, "unverified@example.com")
+		user.EmailVerified = false
+		delete(userRepo.(*mockUserRepository).users, "unverified@example.com")
+		user.Email = "changed@example.com"
+		userRepo.(*mockUserRepository).users[user.Email] = user
+		if err := authService.VerifyEmail(ctx, token); err == nil {
+			t.Error("expected token for the old address to be rejected")
+		}
+	})
+}
+
+func TestAuthService_OperatorActions(t *testing.T) {
+	userRepo := newMockUserRepository()
+	refreshTokenRepo := newMockRefreshTokenRepository()
+	securityEventRepo := newMockSecurityEventRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		SecurityEventRepo: securityEventRepo,
+		EmailService:      emailService,
+	})
+	ctx := context.Background()
+
+	t.Run("Created users are not signed in", func(t *testing.T) {
+		user, err := authService.CreateUser(ctx, &dto.RegisterRequest{
+			Username: "created",
+			Email:    "created@example.com",
+			Password: "password123",
+		}, true)
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		if !user.EmailVerified {
+			t.Error("expected the email to be verified")
+		}
+		if len(refreshTokenRepo.tokens) != 0 || len(emailService.sent) != 0 {
+			t.Errorf("expected no session or email, got %d and %d", len(refreshTokenRepo.tokens), len(emailService.sent))
+		}
+
+		unverified, err := authService.CreateUser(ctx, &dto.RegisterRequest{
+			Username: "unverified",
+			Email:    "created-unverified@example.com",
+			Password: "password123",
+		}, false)
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		if unverified.EmailVerified || len(emailService.sent) != 1 || emailService.sent[0].To != unverified.Email {
+			t.Errorf("expected only the verification email, got %+v", emailService.sent)
+		}
+	})
+
+	resp, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "operated",
+		Email:    "operated@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := resp.User.ID
+
+	t.Run("Set password ends sessions", func(t *testing.T) {
+		if err := authService.SetPassword(ctx, userID, "newpassword"); err != nil {
+			t.Fatalf("SetPassword failed: %v", err)
+		}
+		if _, err := authService.RefreshToken(ctx, resp.RefreshToken, ""); err == nil {
+			t.Error("expected existing sessions to be revoked")
+		}
+		if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "operated@example.com", Password: "newpassword"}); err != nil {
+			t.Errorf("expected the new password to work: %v", err)
+		}
+	})
+
+	t.Run("Verify email", func(t *testing.T) {
+		if err := authService.MarkEmailVerified(ctx, userID); err != nil {
+			t.Fatalf("MarkEmailVerified failed: %v", err)
+		}
+		if user, _ := userRepo.GetByID(ctx, userID); !user.EmailVerified {
+			t.Error("expected the email to be verified")
+		}
+	})
+
+	t.Run("Disabled users cannot sign in or refresh", func(t *testing.T) {
+		session, err := authService.Login(ctx, &dto.LoginRequest{Email: "operated@example.com", Password: "newpassword"})
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if err := authService.DisableUser(ctx, userID); err != nil {
+			t.Fatalf("DisableUser failed: %v", err)
+		}
+
+		_, err = authService.Login(ctx, &dto.LoginRequest{Email: "operated@example.com", Password: "newpassword"})
+		if !errors.Is(err, services.ErrAccountDisabled) {
+			t.Errorf("expected ErrAccountDisabled, got %v", err)
+		}
+		if _, err := authService.RefreshToken(ctx, session.RefreshToken, ""); err == nil {
+			t.Error("expected the session of a disabled user to be revoked")
+		}
+		if _, err := authService.ValidateToken(ctx, session.AccessToken); err == nil {
+			t.Error("expected the access token of a disabled user to be revoked")
+		}
+	})
+
+	var types []string
+	for _, event := range securityEventRepo.events {
+		types = append(types, event.Type)
+	}
+	if len(types) != 2 || types[0] != entities.SecurityEventPasswordSet || types[1] != entities.SecurityEventAccountDisabled {
+		t.Errorf("unexpected security events %v", types)
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+)
+
+// clientAssertionTypeJWT is the client_assertion_type of private_key_jwt
+// (RFC 7523 section 2.2)
+const clientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientCredentialsGrant issues a confidential client a token of its own
+// (RFC 6749 section 4.4). The token's subject is the client; there is no
+// refresh token, since the client can always ask again.
+func (s *authServiceImpl) clientCredentialsGrant(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if client.AuthMethod == entities.ClientAuthNone {
+		return nil, oauthError("unauthorized_client", "public clients can't use the client_credentials grant")
+	}
+
+	scope, err := grantScope(req.Scope, s.clientScopes(client))
+	if err != nil {
+		return nil, oauthError("invalid_scope", "%v", err)
+	}
+
+	accessToken, err := s.jwtManager.GenerateToken(client.ID, map[string]interface{}{
+		"client_id": client.ID,
+		"principal": dto.PrincipalService,
+		"scope":     strings.Join(scope, " "),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	return &dto.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.jwtManager.AccessTokenExpiry().Seconds()),
+		Scope:       strings.Join(scope, " "),
+	}, nil
+}
+
+// Client assertions are short-lived. That bounds how long each jti is
+// remembered, and how long a leaked assertion is good for.
+const (
+	maxClientAssertionLifetime = 5 * time.Minute
+	clientAssertionClockSkew   = time.Minute
+)
+
+// verifyClientAssertion authenticates a private_key_jwt client (RFC 7523
+// section 3). Each assertion is accepted once.
+func (s *authServiceImpl) verifyClientAssertion(ctx context.Context, client *entities.OAuthClient, req *dto.OAuthTokenRequest) error {
+	if req.ClientAssertionType != clientAssertionTypeJWT || req.ClientAssertion == "" {
+		return oauthError("invalid_client", "a client_assertion of type %s is required", clientAssertionTypeJWT)
+	}
+	claims, err := s.jwtManager.ValidateClientAssertion(req.ClientAssertion, client.PublicKey)
+	if err != nil {
+		return oauthError("invalid_client", "client authentication failed")
+	}
+
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if issuer != client.ID || subject != client.ID {
+		return oauthError("invalid_client", "client_assertion must be issued by the client about itself")
+	}
+	if !audienceContains(claims["aud"], s.config.Issuer+"/oauth/token") && !audienceContains(claims["aud"], s.config.Issuer) {
+		return oauthError("invalid_client", "client_assertion is not meant for this server")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return oauthError("invalid_client", "client_assertion must have a jti")
+	}
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return oauthError("invalid_client", "client_assertion must have an iat")
+	}
+	if time.Unix(int64(iat), 0).After(time.Now().Add(clientAssertionClockSkew)) {
+		return oauthError("invalid_client", "client_assertion is issued in the future")
+	}
+
+	// Remember the jti until the assertion expires, which must be soon
+	exp, _ := claims["exp"].(float64)
+	ttl := time.Until(time.Unix(int64(exp), 0))
+	if ttl > maxClientAssertionLifetime {
+		return oauthError("invalid_client", "client_assertion must expire within %s", maxClientAssertionLifetime)
+	}
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	fresh, err := s.cooldownService.StartCooldown(ctx, "client_assertion:"+client.ID+":"+hashToken(jti), ttl)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return oauthError("invalid_client", "client_assertion has already been used")
+	}
+	return nil
+}
+
+// assertionIssuer reads the issuer of a client assertion without verifying
+// it, to find the client whose key verifies it
+func assertionIssuer(assertion string) string {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	json.Unmarshal(payload, &claims)
+	return claims.Issuer
+}
+
+// audienceContains tells whether an aud claim, a string or a list of them,
+// names the audience
+func audienceContains(claim interface{}, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateClientKey checks that a private_key_jwt client registered a PEM
+// public key that can verify RS256, ES256 or EdDSA assertions
+func validateClientKey(publicKeyPEM string) error {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return fmt.Errorf("a PEM public key is required for private_key_jwt")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return fmt.Errorf("ECDSA key must use the P-256 curve")
+		}
+	case ed25519.PublicKey:
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package services_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthService_ClientCredentials(t *testing.T) {
+	authService := newTestAuthService(t, appservices.AuthDeps{})
+	ctx := context.Background()
+
+	// expectOAuthError checks err is the OAuth error code
+	expectOAuthError := func(t *testing.T, err error, code string) {
+		t.Helper()
+		var oauthErr *services.OAuthError
+		if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+			t.Errorf("expected OAuth error %s, got %v", code, err)
+		}
+	}
+
+	secretClient := &entities.OAuthClient{
+		Name:       "Nightly Reports",
+		AuthMethod: entities.ClientAuthSecretBasic,
+		Scopes:     []string{"profile", "admin"},
+	}
+	secret, err := authService.RegisterOAuthClient(ctx, secretClient)
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+	if secret == "" || secretClient.SecretHash == "" || secretClient.SecretHash == secret {
+		t.Fatal("expected a secret that is stored hashed")
+	}
+
+	t.Run("Secret clients get service tokens", func(t *testing.T) {
+		resp, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     secretClient.ID,
+			ClientSecret: secret,
+			Scope:        "admin",
+		})
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+		if resp.RefreshToken != "" {
+			t.Error("expected no refresh token for a service")
+		}
+
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		if claims.Principal != dto.PrincipalService || claims.ClientID != secretClient.ID || claims.UserID != 0 {
+			t.Errorf("expected a service token for the client, got %+v", claims)
+		}
+		if !slices.Equal(claims.Scope, []string{"admin"}) {
+			t.Errorf("expected scope [admin], got %v", claims.Scope)
+		}
+	})
+
+	t.Run("Wrong secrets are refused", func(t *testing.T) {
+		_, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     secretClient.ID,
+			ClientSecret: "not-the-secret",
+		})
+		expectOAuthError(t, err, "invalid_client")
+	})
+
+	t.Run("Scope is limited to the client's", func(t *testing.T) {
+		_, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "client_credentials",
+			ClientID:     secretClient.ID,
+			ClientSecret: secret,
+			Scope:        "account",
+		})
+		expectOAuthError(t, err, "invalid_scope")
+	})
+
+	t.Run("Public clients can't use the grant", func(t *testing.T) {
+		public := &entities.OAuthClient{
+			Name:         "Browser App",
+			RedirectURIs: []string{"https://app.example.com/callback"},
+			Scopes:       []string{"profile"},
+		}
+		if _, err := authService.RegisterOAuthClient(ctx, public); err != nil {
+			t.Fatalf("RegisterOAuthClient failed: %v", err)
+		}
+		_, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{GrantType: "client_credentials", ClientID: public.ID})
+		expectOAuthError(t, err, "unauthorized_client")
+	})
+
+	t.Run("Private key JWT", func(t *testing.T) {
+		publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			t.Fatalf("failed to marshal key: %v", err)
+		}
+		keyClient := &entities.OAuthClient{
+			Name:       "Billing Service",
+			AuthMethod: entities.ClientAuthPrivateKeyJWT,
+			PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			Scopes:     []string{"profile"},
+		}
+		if _, err := authService.RegisterOAuthClient(ctx, keyClient); err != nil {
+			t.Fatalf("RegisterOAuthClient failed: %v", err)
+		}
+
+		// assertion signs a client assertion with the claims
+		assertion := func(t *testing.T, claims gojwt.MapClaims) string {
+			t.Helper()
+			signed, err := gojwt.NewWithClaims(gojwt.SigningMethodEdDSA, claims).SignedString(privateKey)
+			if err != nil {
+				t.Fatalf("failed to sign assertion: %v", err)
+			}
+			return signed
+		}
+		claims := gojwt.MapClaims{
+			"iss": keyClient.ID,
+			"sub": keyClient.ID,
+			"aud": "https://auth.example.com/oauth/token",
+			"jti": "assertion-1",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		request := func(signed string) *dto.OAuthTokenRequest {
+			return &dto.OAuthTokenRequest{
+				GrantType:           "client_credentials",
+				ClientAssertionType: "urn:ietf:params:oauth:client-assertion-type:jwt-bearer",
+				ClientAssertion:     signed,
+			}
+		}
+
+		signed := assertion(t, claims)
+		resp, err := authService.OAuthToken(ctx, request(signed))
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+		if got, err := authService.ValidateToken(ctx, resp.AccessToken); err != nil || got.ClientID != keyClient.ID {
+			t.Errorf("expected a service token for the client, got %+v, %v", got, err)
+		}
+
+		_, err = authService.OAuthToken(ctx, request(signed))
+		expectOAuthError(t, err, "invalid_client")
+
+		claims["jti"] = "assertion-2"
+		claims["aud"] = "https://elsewhere.example.com/token"
+		_, err = authService.OAuthToken(ctx, request(assertion(t, claims)))
+		expectOAuthError(t, err, "invalid_client")
+
+		// Assertions must say when they were issued and expire soon
+		claims["aud"] = "https://auth.example.com/oauth/token"
+		claims["jti"] = "assertion-3"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		_, err = authService.OAuthToken(ctx, request(assertion(t, claims)))
+		expectOAuthError(t, err, "invalid_client")
+
+		claims["jti"] = "assertion-4"
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		delete(claims, "iat")
+		_, err = authService.OAuthToken(ctx, request(assertion(t, claims)))
+		expectOAuthError(t, err, "invalid_client")
+
+		claims["jti"] = "assertion-5"
+		claims["iat"] = time.Now().Unix()
+		if _, err := authService.OAuthToken(ctx, request(assertion(t, claims))); err != nil {
+			t.Errorf("expected a fresh assertion to be accepted: %v", err)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"slices"
+	"strings"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// grantTypeDeviceCode is the grant type of the device flow (RFC 8628
+// section 3.4)
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// User codes are typed by people, so they avoid vowels (no words) and
+// characters that are easily confused (RFC 8628 section 6.1). Eight of them
+// are about 34 bits, which the rate limit on the verification page makes
+// impractical to guess.
+const (
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+// maxUserCodeAttempts bounds the retries when a new user code is taken
+const maxUserCodeAttempts = 3
+
+func (s *authServiceImpl) DeviceAuthorization(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.DeviceAuthorizationResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := grantScope(req.Scope, s.clientScopes(client))
+	if err != nil {
+		return nil, oauthError("invalid_scope", "%v", err)
+	}
+
+	deviceCode, err := generateOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	grant, err := json.Marshal(authorizationGrant{ClientID: client.ID, Scope: scope})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxUserCodeAttempts; attempt++ {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return nil, err
+		}
+		created, err := s.deviceGrantStore.Create(ctx, hashToken(deviceCode), userCodeKey(userCode), client.ID, string(grant),
+			s.config.DevicePollInterval, s.config.DeviceCodeExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store device code: %w", err)
+		}
+		if !created {
+			continue
+		}
+
+		verificationURI := s.config.Issuer + "/oauth/device"
+		return &dto.DeviceAuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                userCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+			ExpiresIn:               int64(s.config.DeviceCodeExpiry.Seconds()),
+			Interval:                int64(s.config.DevicePollInterval.Seconds()),
+		}, nil
+	}
+	return nil, fmt.Errorf("failed to find a free user code")
+}
+
+func (s *authServiceImpl) CheckUserCode(ctx context.Context, userCode string) (*dto.AuthorizationPrompt, error) {
+	_, client, grant, err := s.pendingDeviceGrant(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.AuthorizationPrompt{ClientName: client.Name, Scope: grant.Scope}, nil
+}
+
+func (s *authServiceImpl) ApproveDeviceCode(ctx context.Context, userCode string, auth *dto.Authentication) error {
+	key, client, grant, err := s.pendingDeviceGrant(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	grant.UserID = auth.UserID
+	grant.AuthTime = auth.Time.Unix()
+	grant.AMR = auth.Methods
+	value, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+
+	decided, err := s.deviceGrantStore.Decide(ctx, key, true, string(value))
+	if err != nil {
+		return fmt.Errorf("failed to approve device code: %w", err)
+	}
+	if !decided {
+		return services.ErrInvalidUserCode
+	}
+
+	s.recordSecurityEvent(ctx, auth.UserID, entities.SecurityEventOAuthAuthorized,
+		fmt.Sprintf("%s (%s) authorized for %s on a device", client.Name, client.ID, strings.Join(grant.Scope, " ")))
+	return nil
+}
+
+func (s *authServiceImpl) DenyDeviceCode(ctx context.Context, userCode string) error {
+	decided, err := s.deviceGrantStore.Decide(ctx, userCodeKey(userCode), false, "")
+	if err != nil {
+		return fmt.Errorf("failed to deny device code: %w", err)
+	}
+	if !decided {
+		return services.ErrInvalidUserCode
+	}
+	return nil
+}
+
+// pendingDeviceGrant looks up the grant a user code stands for, and the
+// client that asked for it
+func (s *authServiceImpl) pendingDeviceGrant(ctx context.Context, userCode string) (string, *entities.OAuthClient, *authorizationGrant, error) {
+	key := userCodeKey(userCode)
+	value, found, err := s.deviceGrantStore.Pending(ctx, key)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to look up user code: %w", err)
+	}
+	if !found {
+		return "", nil, nil, services.ErrInvalidUserCode
+	}
+	var grant authorizationGrant
+	if err := json.Unmarshal([]byte(value), &grant); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to decode device grant: %w", err)
+	}
+	client, err := s.oauthClientRepo.GetByID(ctx, grant.ClientID)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %v", services.ErrInvalidUserCode, err)
+	}
+	return key, client, &grant, nil
+}
+
+// deviceCodeGrant answers a device polling for the user's decision
+// (RFC 8628 section 3.5). Approved and denied grants are answered once.
+func (s *authServiceImpl) deviceCodeGrant(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.DeviceCode == "" {
+		return nil, oauthError("invalid_request", "device_code is required")
+	}
+
+	// The store checks the client, so that another client can't use up the
+	// grant
+	state, value, err := s.deviceGrantStore.Poll(ctx, hashToken(req.DeviceCode), client.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch state {
+	case services.DeviceGrantExpired:
+		return nil, oauthError("expired_token", "device code is invalid or expired")
+	case services.DeviceGrantOtherClient:
+		return nil, oauthError("invalid_grant", "device code was issued to another client")
+	}
+	var grant authorizationGrant
+	if err := json.Unmarshal([]byte(value), &grant); err != nil {
+		return nil, fmt.Errorf("failed to decode device grant: %w", err)
+	}
+
+	switch state {
+	case services.DeviceGrantPending:
+		return nil, oauthError("authorization_pending", "the user has not yet approved the device")
+	case services.DeviceGrantSlowDown:
+		return nil, oauthError("slow_down", "polling too often, wait longer between requests")
+	case services.DeviceGrantDenied:
+		return nil, oauthError("access_denied", "the user denied the device")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, grant.UserID)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "user not found")
+	}
+	if user.DisabledAt != nil {
+		return nil, oauthError("invalid_grant", "%v", services.ErrAccountDisabled)
+	}
+
+	familyID, err := generateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.issueTokens(ctx, user, tokenSession{familyID: familyID, scope: grant.Scope, clientID: client.ID})
+	if err != nil {
+		return nil, err
+	}
+	tokens := oauthTokenResponse(resp)
+	if slices.Contains(grant.Scope, scopeOpenID) {
+		if tokens.IDToken, err = s.issueIDToken(user, &grant, familyID); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// generateUserCode returns a random user code shown as XXXX-XXXX
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range code {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate user code: %w", err)
+		}
+		code[i] = userCodeCharset[v.Int64()]
+	}
+	return string(code[:userCodeLength/2]) + "-" + string(code[userCodeLength/2:]), nil
+}
+
+// userCodeKey finds a user code however the user typed it: in any case,
+// with or without the dash and spaces
+func userCodeKey(userCode string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+	return hashToken(normalized)
+}
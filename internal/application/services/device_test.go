@@ -0,0 +1,207 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_DeviceAuthorization(t *testing.T) {
+	deviceGrantStore := newMockDeviceGrantStore()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		JWTManager:       newTestOIDCJWTManager(t),
+		DeviceGrantStore: deviceGrantStore,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "deviceuser",
+		Email:    "device@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+
+	client := &entities.OAuthClient{
+		Name:         "Example CLI",
+		RedirectURIs: []string{"http://127.0.0.1/callback"},
+		Scopes:       []string{"openid", "profile", "account"},
+	}
+	if _, err := authService.RegisterOAuthClient(ctx, client); err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+	other := &entities.OAuthClient{
+		Name:         "Other CLI",
+		RedirectURIs: []string{"http://127.0.0.1/callback"},
+		Scopes:       []string{"profile"},
+	}
+	if _, err := authService.RegisterOAuthClient(ctx, other); err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+
+	authorize := func(t *testing.T) *dto.DeviceAuthorizationResponse {
+		t.Helper()
+		resp, err := authService.DeviceAuthorization(ctx, &dto.OAuthTokenRequest{ClientID: client.ID, Scope: "openid profile"})
+		if err != nil {
+			t.Fatalf("DeviceAuthorization failed: %v", err)
+		}
+		return resp
+	}
+
+	// poll asks for tokens as if the device had waited out the interval
+	poll := func(clientID, deviceCode string) (*dto.OAuthTokenResponse, error) {
+		for _, grant := range deviceGrantStore.grants {
+			grant.lastPoll = time.Time{}
+		}
+		return authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:  "urn:ietf:params:oauth:grant-type:device_code",
+			ClientID:   clientID,
+			DeviceCode: deviceCode,
+		})
+	}
+
+	authentication := &dto.Authentication{UserID: userID, Time: time.Now(), Methods: []string{dto.AMRPassword}}
+
+	// expectOAuthError checks err is the OAuth error code
+	expectOAuthError := func(t *testing.T, err error, code string) {
+		t.Helper()
+		var oauthErr *services.OAuthError
+		if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+			t.Errorf("expected OAuth error %s, got %v", code, err)
+		}
+	}
+
+	t.Run("Codes and verification URIs", func(t *testing.T) {
+		resp := authorize(t)
+		if resp.DeviceCode == "" {
+			t.Error("expected a device code")
+		}
+		if !regexp.MustCompile(`^[B-Z]{4}-[B-Z]{4}$`).MatchString(resp.UserCode) || strings.ContainsAny(resp.UserCode, "AEIOUY") {
+			t.Errorf("expected a user code like XXXX-XXXX without vowels, got %s", resp.UserCode)
+		}
+		if resp.VerificationURI != "https://auth.example.com/oauth/device" ||
+			resp.VerificationURIComplete != resp.VerificationURI+"?user_code="+resp.UserCode {
+			t.Errorf("unexpected verification URIs %s and %s", resp.VerificationURI, resp.VerificationURIComplete)
+		}
+		if resp.ExpiresIn != 600 || resp.Interval != 5 {
+			t.Errorf("expected a 600s code polled every 5s, got %d and %d", resp.ExpiresIn, resp.Interval)
+		}
+
+		prompt, err := authService.CheckUserCode(ctx, strings.ToLower(strings.ReplaceAll(resp.UserCode, "-", "")))
+		if err != nil {
+			t.Fatalf("expected the code to be found however it is typed: %v", err)
+		}
+		if prompt.ClientName != client.Name || !slices.Equal(prompt.Scope, []string{"openid", "profile"}) {
+			t.Errorf("unexpected prompt %+v", prompt)
+		}
+	})
+
+	t.Run("Scope is limited to the client's", func(t *testing.T) {
+		_, err := authService.DeviceAuthorization(ctx, &dto.OAuthTokenRequest{ClientID: client.ID, Scope: "admin"})
+		expectOAuthError(t, err, "invalid_scope")
+		_, err = authService.DeviceAuthorization(ctx, &dto.OAuthTokenRequest{ClientID: "unknown"})
+		expectOAuthError(t, err, "invalid_client")
+	})
+
+	t.Run("Approved devices get tokens once", func(t *testing.T) {
+		resp := authorize(t)
+		_, err := poll(client.ID, resp.DeviceCode)
+		expectOAuthError(t, err, "authorization_pending")
+
+		if err := authService.ApproveDeviceCode(ctx, resp.UserCode, authentication); err != nil {
+			t.Fatalf("ApproveDeviceCode failed: %v", err)
+		}
+		if _, err := authService.CheckUserCode(ctx, resp.UserCode); !errors.Is(err, services.ErrInvalidUserCode) {
+			t.Errorf("expected an approved code to be spent, got %v", err)
+		}
+
+		tokens, err := poll(client.ID, resp.DeviceCode)
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+		if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IDToken == "" {
+			t.Errorf("expected access, refresh and ID tokens, got %+v", tokens)
+		}
+		claims, err := authService.ValidateToken(ctx, tokens.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		if claims.UserID != userID || !slices.Equal(claims.Scope, []string{"openid", "profile"}) {
+			t.Errorf("expected a token for the user with the approved scope, got %+v", claims)
+		}
+
+		_, err = poll(client.ID, resp.DeviceCode)
+		expectOAuthError(t, err, "expired_token")
+	})
+
+	t.Run("Polling too often slows the device down", func(t *testing.T) {
+		resp := authorize(t)
+		_, err := poll(client.ID, resp.DeviceCode)
+		expectOAuthError(t, err, "authorization_pending")
+		_, err = authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:  "urn:ietf:params:oauth:grant-type:device_code",
+			ClientID:   client.ID,
+			DeviceCode: resp.DeviceCode,
+		})
+		expectOAuthError(t, err, "slow_down")
+	})
+
+	t.Run("Denied devices get no tokens", func(t *testing.T) {
+		resp := authorize(t)
+		if err := authService.DenyDeviceCode(ctx, resp.UserCode); err != nil {
+			t.Fatalf("DenyDeviceCode failed: %v", err)
+		}
+		if err := authService.ApproveDeviceCode(ctx, resp.UserCode, authentication); !errors.Is(err, services.ErrInvalidUserCode) {
+			t.Errorf("expected a denied code not to be approved, got %v", err)
+		}
+		_, err := poll(client.ID, resp.DeviceCode)
+		expectOAuthError(t, err, "access_denied")
+	})
+
+	t.Run("Device codes stay with the client", func(t *testing.T) {
+		resp := authorize(t)
+		if err := authService.ApproveDeviceCode(ctx, resp.UserCode, authentication); err != nil {
+			t.Fatalf("ApproveDeviceCode failed: %v", err)
+		}
+		_, err := poll(other.ID, resp.DeviceCode)
+		expectOAuthError(t, err, "invalid_grant")
+		// Another client's poll leaves the grant for the device
+		if _, err := poll(client.ID, resp.DeviceCode); err != nil {
+			t.Errorf("expected the device to still get its tokens: %v", err)
+		}
+		_, err = poll(client.ID, "not-a-device-code")
+		expectOAuthError(t, err, "expired_token")
+		_, err = poll(client.ID, "")
+		expectOAuthError(t, err, "invalid_request")
+	})
+
+	t.Run("Unknown user codes are refused", func(t *testing.T) {
+		if _, err := authService.CheckUserCode(ctx, "BCDF-GHJK"); !errors.Is(err, services.ErrInvalidUserCode) {
+			t.Errorf("expected ErrInvalidUserCode, got %v", err)
+		}
+		if err := authService.DenyDeviceCode(ctx, "BCDF-GHJK"); !errors.Is(err, services.ErrInvalidUserCode) {
+			t.Errorf("expected ErrInvalidUserCode, got %v", err)
+		}
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		config := authService.OpenIDConfiguration()
+		if config.DeviceAuthorizationEndpoint != "https://auth.example.com/oauth/device_authorization" {
+			t.Errorf("unexpected device authorization endpoint %s", config.DeviceAuthorizationEndpoint)
+		}
+		if !slices.Contains(config.GrantTypesSupported, "urn:ietf:params:oauth:grant-type:device_code") {
+			t.Error("expected the device code grant to be advertised")
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// stepUpPurposes describe step-up actions in the email that carries the code
+var stepUpPurposes = map[string]string{
+	services.StepUpChangePassword: "change your password",
+	services.StepUpChangeEmail:    "change your email address",
+	services.StepUpAddPasskey:     "add a passkey",
+}
+
+// RequestLoginCode emails a 6-digit sign-in code. Like the other email
+// flows it succeeds silently when there is no such account.
+func (s *authServiceImpl) RequestLoginCode(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.DisabledAt != nil {
+		return nil
+	}
+	err = s.sendOneTimeCode(ctx, user, loginCodeKey(email), "sign in")
+	if errors.Is(err, services.ErrTooManyAttempts) {
+		// Don't reveal that the address has an account; verifying tells
+		// the user about the lockout
+		return nil
+	}
+	return err
+}
+
+// LoginWithCode signs in with an emailed code
+func (s *authServiceImpl) LoginWithCode(ctx context.Context, email, code string) (*dto.AuthResponse, error) {
+	// Guesses count against the address whether or not it has an account
+	if err := s.verifyOneTimeCode(ctx, loginCodeKey(email), code); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, services.ErrInvalidMFACode
+	}
+	return s.finishEmailLogin(ctx, user)
+}
+
+// RequestStepUpCode emails a code that authorizes one sensitive action
+func (s *authServiceImpl) RequestStepUpCode(ctx context.Context, userID int, action string) error {
+	purpose, ok := stepUpPurposes[action]
+	if !ok {
+		return fmt.Errorf("unknown step-up action %q", action)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.sendOneTimeCode(ctx, user, stepUpKey(userID, action), purpose)
+}
+
+// ChangePassword sets a new password after a step-up and signs out every session
+func (s *authServiceImpl) ChangePassword(ctx context.Context, userID int, code, newPassword string) error {
+	if err := s.verifyOneTimeCode(ctx, stepUpKey(userID, services.StepUpChangePassword), code); err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = string(hashedPassword)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.revokeAllSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, user.ID, entities.SecurityEventPasswordChanged, "password changed after step-up")
+	return nil
+}
+
+// ChangeEmail moves the account to a new address after a step-up. The new
+// address has to be verified again.
+func (s *authServiceImpl) ChangeEmail(ctx context.Context, userID int, code, newEmail string) error {
+	if err := s.verifyOneTimeCode(ctx, stepUpKey(userID, services.StepUpChangeEmail), code); err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if existing, _ := s.userRepo.GetByEmail(ctx, newEmail); existing != nil {
+		return fmt.Errorf("user with email %s already exists", newEmail)
+	}
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Alert the old address before the account stops pointing at it
+		s.recordSecurityEvent(ctx, user.ID, entities.SecurityEventEmailChanged,
+			fmt.Sprintf("email changed from %s to %s", user.Email, newEmail))
+
+		user.Email = newEmail
+		user.EmailVerified = false
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+		return s.sendVerificationEmail(ctx, user)
+	})
+}
+
+// sendOneTimeCode stores a new code under key and emails it to the user
+func (s *authServiceImpl) sendOneTimeCode(ctx context.Context, user *entities.User, key, purpose string) error {
+	code, err := generateNumericCode(6)
+	if err != nil {
+		return err
+	}
+	if err := s.oneTimeCodeStore.Issue(ctx, key, hashToken(code), s.config.EmailOTPExpiry); err != nil {
+		return err
+	}
+
+	if err := s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplateOneTimeCode, map[string]interface{}{
+		"Username":  user.Username,
+		"Code":      code,
+		"Purpose":   purpose,
+		"ExpiresIn": s.config.EmailOTPExpiry.String(),
+	}); err != nil {
+		return fmt.Errorf("failed to send one-time code email: %w", err)
+	}
+	return nil
+}
+
+// verifyOneTimeCode consumes the code stored under key
+func (s *authServiceImpl) verifyOneTimeCode(ctx context.Context, key, code string) error {
+	ok, err := s.oneTimeCodeStore.Verify(ctx, key, hashToken(strings.TrimSpace(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return services.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func loginCodeKey(email string) string {
+	return "login:" + strings.ToLower(email)
+}
+
+func stepUpKey(userID int, action string) string {
+	return fmt.Sprintf("stepup:%s:%d", action, userID)
+}
+
+// generateNumericCode returns a uniformly random code of n digits
+func generateNumericCode(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
@@ -0,0 +1,137 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_EmailOTP(t *testing.T) {
+	userRepo := newMockUserRepository()
+	securityEventRepo := newMockSecurityEventRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:          userRepo,
+		SecurityEventRepo: securityEventRepo,
+		EmailService:      emailService,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "otpuser",
+		Email:    "otp@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+
+	// lastCode returns the code from the latest one-time code email
+	lastCode := func(t *testing.T) string {
+		t.Helper()
+		codes := emailService.withTemplate(services.EmailTemplateOneTimeCode)
+		if len(codes) == 0 {
+			t.Fatal("expected a one-time code email")
+		}
+		code := codes[len(codes)-1].Data["Code"].(string)
+		if len(code) != 6 {
+			t.Fatalf("expected a 6-digit code, got %q", code)
+		}
+		return code
+	}
+
+	t.Run("Unknown email is not revealed", func(t *testing.T) {
+		emailService.sent = nil
+		if err := authService.RequestLoginCode(ctx, "nobody@example.com"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(emailService.sent) != 0 {
+			t.Errorf("expected no email to be sent, got %d", len(emailService.sent))
+		}
+	})
+
+	t.Run("Code signs in once", func(t *testing.T) {
+		if err := authService.RequestLoginCode(ctx, "otp@example.com"); err != nil {
+			t.Fatalf("RequestLoginCode failed: %v", err)
+		}
+		code := lastCode(t)
+		resp, err := authService.LoginWithCode(ctx, "otp@example.com", code)
+		if err != nil || resp.AccessToken == "" || resp.User.ID != userID {
+			t.Fatalf("expected a code login, got %v", err)
+		}
+		if _, err := authService.LoginWithCode(ctx, "otp@example.com", code); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a used code to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("Step-up codes are bound to the action", func(t *testing.T) {
+		if err := authService.RequestStepUpCode(ctx, userID, services.StepUpChangeEmail); err != nil {
+			t.Fatalf("RequestStepUpCode failed: %v", err)
+		}
+		code := lastCode(t)
+		if err := authService.ChangePassword(ctx, userID, code, "newpassword456"); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a code for another action to be rejected, got %v", err)
+		}
+		if err := authService.RequestStepUpCode(ctx, userID, "delete_everything"); err == nil {
+			t.Error("expected an unknown action to be rejected")
+		}
+	})
+
+	t.Run("Change password", func(t *testing.T) {
+		authService.RequestStepUpCode(ctx, userID, services.StepUpChangePassword)
+		if err := authService.ChangePassword(ctx, userID, lastCode(t), "newpassword456"); err != nil {
+			t.Fatalf("ChangePassword failed: %v", err)
+		}
+		if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "otp@example.com", Password: "newpassword456"}); err != nil {
+			t.Errorf("expected the new password to work, got %v", err)
+		}
+	})
+
+	t.Run("Change email", func(t *testing.T) {
+		emailService.sent = nil
+		authService.RequestStepUpCode(ctx, userID, services.StepUpChangeEmail)
+		if err := authService.ChangeEmail(ctx, userID, lastCode(t), "new-otp@example.com"); err != nil {
+			t.Fatalf("ChangeEmail failed: %v", err)
+		}
+		alerts := emailService.withTemplate(services.EmailTemplateSecurityAlert)
+		if len(alerts) != 1 || alerts[0].To != "otp@example.com" {
+			t.Errorf("expected the old address to be alerted, got %+v", alerts)
+		}
+		verifications := emailService.withTemplate(services.EmailTemplateVerification)
+		if len(verifications) != 1 || verifications[0].To != "new-otp@example.com" {
+			t.Errorf("expected the new address to be verified, got %+v", verifications)
+		}
+		last := securityEventRepo.events[len(securityEventRepo.events)-1]
+		if last.Type != entities.SecurityEventEmailChanged {
+			t.Errorf("expected an email change event, got %s", last.Type)
+		}
+		user, _ := userRepo.GetByID(ctx, userID)
+		if user.Email != "new-otp@example.com" || user.EmailVerified {
+			t.Errorf("expected an unverified new address, got %s (verified %v)", user.Email, user.EmailVerified)
+		}
+	})
+
+	t.Run("Wrong codes lock the address", func(t *testing.T) {
+		authService.RequestLoginCode(ctx, "new-otp@example.com")
+		code := lastCode(t)
+		for i := 0; i < 5; i++ {
+			if _, err := authService.LoginWithCode(ctx, "new-otp@example.com", "000000"); !errors.Is(err, services.ErrInvalidMFACode) {
+				t.Fatalf("expected a wrong code to be rejected, got %v", err)
+			}
+		}
+		if _, err := authService.LoginWithCode(ctx, "new-otp@example.com", code); !errors.Is(err, services.ErrTooManyAttempts) {
+			t.Errorf("expected the address to be locked, got %v", err)
+		}
+		// A new code doesn't lift the lock
+		emailService.sent = nil
+		if err := authService.RequestLoginCode(ctx, "new-otp@example.com"); err != nil || len(emailService.sent) != 0 {
+			t.Errorf("expected no code while locked, got %v and %d emails", err, len(emailService.sent))
+		}
+	})
+}
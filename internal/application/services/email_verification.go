@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+func (s *authServiceImpl) VerifyEmail(ctx context.Context, token string) error {
+	userID, email, err := s.parseVerificationToken(token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("invalid verification token")
+	}
+	// The link only verifies the address it was sent to
+	if !strings.EqualFold(user.Email, email) {
+		return fmt.Errorf("invalid verification token")
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	user.EmailVerified = true
+	return s.userRepo.Update(ctx, user)
+}
+
+func (s *authServiceImpl) ResendVerificationEmail(ctx context.Context, email string) error {
+	// The cooldown is keyed by address rather than by account so that it
+	// doesn't reveal which addresses are registered
+	started, err := s.cooldownService.StartCooldown(ctx, "verification:"+strings.ToLower(email), s.config.EmailVerificationCooldown)
+	if err != nil {
+		return err
+	}
+	if !started {
+		return services.ErrCooldownActive
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.EmailVerified {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *authServiceImpl) sendVerificationEmail(ctx context.Context, user *entities.User) error {
+	if err := s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplateVerification, map[string]interface{}{
+		"Username":  user.Username,
+		"Link":      strings.TrimRight(s.config.EmailVerificationURL, "/") + "/" + s.signVerificationToken(user),
+		"ExpiresIn": s.config.EmailVerificationExpiry.String(),
+	}); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+// Verification tokens are stateless: base64url("<user id>:<email>:<expiry>")
+// followed by an HMAC-SHA256 signature of that payload.
+func (s *authServiceImpl) signVerificationToken(user *entities.User) string {
+	expires := time.Now().Add(s.config.EmailVerificationExpiry).Unix()
+	payload := fmt.Sprintf("%d:%s:%d", user.ID, strings.ToLower(user.Email), expires)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + s.verificationSignature(encoded)
+}
+
+func (s *authServiceImpl) parseVerificationToken(token string) (int, string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.verificationSignature(encoded))) {
+		return 0, "", fmt.Errorf("invalid verification token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid verification token")
+	}
+	// The email may itself contain colons, so split from both ends
+	first := strings.Index(string(payload), ":")
+	last := strings.LastIndex(string(payload), ":")
+	if first < 0 || first == last {
+		return 0, "", fmt.Errorf("invalid verification token")
+	}
+	userID, err := strconv.Atoi(string(payload[:first]))
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid verification token")
+	}
+	expires, err := strconv.ParseInt(string(payload[last+1:]), 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid verification token")
+	}
+	if time.Now().Unix() > expires {
+		return 0, "", fmt.Errorf("verification token has expired")
+	}
+	return userID, string(payload[first+1 : last]), nil
+}
+
+func (s *authServiceImpl) verificationSignature(encoded string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.EmailVerificationSecret))
+	mac.Write([]byte("email_verification:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// errInvalidCredentials is the answer to a wrong email or password
+var errInvalidCredentials = fmt.Errorf("invalid email or password")
+
+// checkLockout refuses users whose account is locked. It runs before the
+// password is checked, so a locked account can't be used to keep guessing.
+func checkLockout(user *entities.User) error {
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		return &services.AccountLockedError{Until: *user.LockedUntil}
+	}
+	return nil
+}
+
+// recordFailedLogin counts a wrong password and locks the account once
+// there have been too many. It returns the error Login answers with.
+func (s *authServiceImpl) recordFailedLogin(ctx context.Context, user *entities.User) error {
+	if s.config.LockoutThreshold <= 0 {
+		return errInvalidCredentials
+	}
+	failures, err := s.userRepo.RecordFailedLogin(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if failures < s.config.LockoutThreshold {
+		return errInvalidCredentials
+	}
+
+	until := time.Now().Add(s.lockoutDuration(failures))
+	if err := s.userRepo.LockUntil(ctx, user.ID, until); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, user.ID, entities.SecurityEventAccountLocked,
+		fmt.Sprintf("locked until %s after %d failed logins", until.UTC().Format(time.RFC3339), failures))
+	if err := s.sendUnlockEmail(ctx, user, until); err != nil {
+		log.Printf("Failed to send unlock email to user %d: %v", user.ID, err)
+	}
+	return &services.AccountLockedError{Until: until}
+}
+
+// lockoutDuration doubles the lock for every failure past the threshold
+func (s *authServiceImpl) lockoutDuration(failures int) time.Duration {
+	duration := s.config.LockoutBaseDuration
+	for i := s.config.LockoutThreshold; i < failures && duration < s.config.LockoutMaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > s.config.LockoutMaxDuration {
+		duration = s.config.LockoutMaxDuration
+	}
+	return duration
+}
+
+// sendUnlockEmail emails a link that lifts the lock right away
+func (s *authServiceImpl) sendUnlockEmail(ctx context.Context, user *entities.User, until time.Time) error {
+	token, err := generateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Only the most recent link stays valid
+		if err := s.oneTimeTokenRepo.DeleteByUser(ctx, user.ID, entities.OneTimeTokenAccountUnlock); err != nil {
+			return err
+		}
+		if err := s.oneTimeTokenRepo.Create(ctx, &entities.OneTimeToken{
+			TokenHash: hashToken(token),
+			UserID:    user.ID,
+			Purpose:   entities.OneTimeTokenAccountUnlock,
+			ExpiresAt: time.Now().Add(s.config.AccountUnlockExpiry),
+		}); err != nil {
+			return fmt.Errorf("failed to store unlock token: %w", err)
+		}
+
+		return s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplateAccountLocked, map[string]interface{}{
+			"Username":  user.Username,
+			"Link":      strings.TrimRight(s.config.AccountUnlockURL, "/") + "/" + token,
+			"Until":     until.UTC().Format(time.RFC1123),
+			"ExpiresIn": s.config.AccountUnlockExpiry.String(),
+		})
+	})
+}
+
+func (s *authServiceImpl) UnlockAccount(ctx context.Context, token string) error {
+	unlock, err := s.oneTimeTokenRepo.Consume(ctx, hashToken(token), entities.OneTimeTokenAccountUnlock)
+	if err != nil {
+		return fmt.Errorf("invalid or expired unlock link")
+	}
+	if err := s.userRepo.ResetFailedLogins(ctx, unlock.UserID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, unlock.UserID, entities.SecurityEventAccountUnlocked, "unlocked via email link")
+	return nil
+}
+
+// UnlockUser lifts a lockout on behalf of an operator
+func (s *authServiceImpl) UnlockUser(ctx context.Context, userID int) error {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.userRepo.ResetFailedLogins(ctx, userID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventAccountUnlocked, "unlocked by an operator")
+	return nil
+}
@@ -0,0 +1,125 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_Lockout(t *testing.T) {
+	userRepo := newMockUserRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:     userRepo,
+		EmailService: emailService,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "lockuser",
+		Email:    "lock@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+	good := &dto.LoginRequest{Email: "lock@example.com", Password: "password123"}
+	bad := &dto.LoginRequest{Email: "lock@example.com", Password: "wrong"}
+
+	// lockFor fails logins until the account locks and returns how long for
+	lockFor := func(t *testing.T, failures int) time.Duration {
+		t.Helper()
+		var err error
+		for i := 0; i < failures; i++ {
+			_, err = authService.Login(ctx, bad)
+		}
+		var locked *services.AccountLockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("expected the account to be locked, got %v", err)
+		}
+		return time.Until(locked.Until).Round(time.Minute)
+	}
+
+	// expire ends the current lock without resetting the failure count
+	expire := func() {
+		user, _ := userRepo.GetByID(ctx, userID)
+		past := time.Now().Add(-time.Second)
+		user.LockedUntil = &past
+	}
+
+	t.Run("Success resets the count", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			if _, err := authService.Login(ctx, bad); errors.Is(err, services.ErrAccountLocked) {
+				t.Fatalf("locked after %d failures", i+1)
+			}
+		}
+		if _, err := authService.Login(ctx, good); err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if _, err := authService.Login(ctx, bad); errors.Is(err, services.ErrAccountLocked) {
+			t.Error("expected a successful login to reset the count")
+		}
+		authService.Login(ctx, good)
+	})
+
+	t.Run("Lock doubles with every further failure", func(t *testing.T) {
+		if d := lockFor(t, 5); d != time.Minute {
+			t.Errorf("expected a 1m lock, got %v", d)
+		}
+		// Even the right password is refused while locked
+		if _, err := authService.Login(ctx, good); !errors.Is(err, services.ErrAccountLocked) {
+			t.Errorf("expected a locked account to be refused, got %v", err)
+		}
+		expire()
+		if d := lockFor(t, 1); d != 2*time.Minute {
+			t.Errorf("expected a 2m lock, got %v", d)
+		}
+		expire()
+		if d := lockFor(t, 1); d != 4*time.Minute {
+			t.Errorf("expected a 4m lock, got %v", d)
+		}
+		for i := 0; i < 10; i++ {
+			expire()
+			lockFor(t, 1)
+		}
+		expire()
+		if d := lockFor(t, 1); d != time.Hour {
+			t.Errorf("expected the lock to be capped at 1h, got %v", d)
+		}
+	})
+
+	t.Run("Unlock link", func(t *testing.T) {
+		unlocks := emailService.withTemplate(services.EmailTemplateAccountLocked)
+		if len(unlocks) == 0 {
+			t.Fatal("expected an unlock email")
+		}
+		link := unlocks[len(unlocks)-1].Data["Link"].(string)
+		token := link[strings.LastIndex(link, "/")+1:]
+		if err := authService.UnlockAccount(ctx, token); err != nil {
+			t.Fatalf("UnlockAccount failed: %v", err)
+		}
+		if _, err := authService.Login(ctx, good); err != nil {
+			t.Errorf("expected login after unlocking, got %v", err)
+		}
+		if err := authService.UnlockAccount(ctx, token); err == nil {
+			t.Error("expected a used unlock link to be rejected")
+		}
+	})
+
+	t.Run("Operator unlock", func(t *testing.T) {
+		lockFor(t, 5)
+		if err := authService.UnlockUser(ctx, userID); err != nil {
+			t.Fatalf("UnlockUser failed: %v", err)
+		}
+		if _, err := authService.Login(ctx, good); err != nil {
+			t.Errorf("expected login after an operator unlock, got %v", err)
+		}
+	})
+}
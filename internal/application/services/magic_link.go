@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// Magic links are bound to the browser that asked for them: the requester
+// gets a random nonce, and only the hash of token and nonce together is
+// stored. A link forwarded to, or intercepted by, someone else is useless
+// without the nonce, which never leaves the requesting browser.
+
+// RequestMagicLink emails a sign-in link to the account and returns the
+// nonce that must accompany it. A nonce is returned even when no account
+// exists, so the response doesn't reveal which addresses are registered.
+func (s *authServiceImpl) RequestMagicLink(ctx context.Context, email string) (string, error) {
+	nonce, err := generateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.DisabledAt != nil {
+		return nonce, nil
+	}
+
+	token, err := generateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Only the most recently requested link stays valid
+		if err := s.oneTimeTokenRepo.DeleteByUser(ctx, user.ID, entities.OneTimeTokenMagicLink); err != nil {
+			return err
+		}
+		if err := s.oneTimeTokenRepo.Create(ctx, &entities.OneTimeToken{
+			TokenHash: magicLinkHash(token, nonce),
+			UserID:    user.ID,
+			Purpose:   entities.OneTimeTokenMagicLink,
+			ExpiresAt: time.Now().Add(s.config.MagicLinkExpiry),
+		}); err != nil {
+			return fmt.Errorf("failed to store magic link: %w", err)
+		}
+
+		if err := s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplateMagicLink, map[string]interface{}{
+			"Username":  user.Username,
+			"Link":      s.config.MagicLinkURL + "?token=" + url.QueryEscape(token),
+			"ExpiresIn": s.config.MagicLinkExpiry.String(),
+		}); err != nil {
+			return fmt.Errorf("failed to send magic link email: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// RedeemMagicLink signs in with an emailed link and the nonce returned when
+// it was requested. Each link works once.
+func (s *authServiceImpl) RedeemMagicLink(ctx context.Context, token, nonce string) (*dto.AuthResponse, error) {
+	if token == "" || nonce == "" {
+		return nil, fmt.Errorf("invalid or expired magic link")
+	}
+	link, err := s.oneTimeTokenRepo.Consume(ctx, magicLinkHash(token, nonce), entities.OneTimeTokenMagicLink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired magic link")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, link.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired magic link")
+	}
+	return s.finishEmailLogin(ctx, user)
+}
+
+// finishEmailLogin completes a login that proved control of the user's
+// email address in place of the password
+func (s *authServiceImpl) finishEmailLogin(ctx context.Context, user *entities.User) (*dto.AuthResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, services.ErrAccountDisabled
+	}
+
+	// Receiving the email proves control of the address
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	// Email replaces the password, not the second factor
+	methods, err := s.mfaMethods(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		return nil, s.startMFAChallenge(ctx, user, methods, s.config.Scopes)
+	}
+
+	return s.issueTokens(ctx, user, tokenSession{scope: s.config.Scopes})
+}
+
+// magicLinkHash is how a magic link is stored, binding it to its nonce
+func magicLinkHash(token, nonce string) string {
+	return hashToken(token + "." + nonce)
+}
@@ -0,0 +1,115 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/totp"
+)
+
+func TestAuthService_MagicLink(t *testing.T) {
+	userRepo := newMockUserRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:     userRepo,
+		EmailService: emailService,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "linkuser",
+		Email:    "link@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// requestLink returns the token from the emailed link and the nonce
+	requestLink := func(t *testing.T) (string, string) {
+		t.Helper()
+		emailService.sent = nil
+		nonce, err := authService.RequestMagicLink(ctx, "link@example.com")
+		if err != nil || nonce == "" {
+			t.Fatalf("RequestMagicLink failed: %v", err)
+		}
+		links := emailService.withTemplate(services.EmailTemplateMagicLink)
+		if len(links) != 1 {
+			t.Fatalf("expected a magic link email, got %+v", emailService.sent)
+		}
+		match := resetTokenPattern.FindStringSubmatch(links[0].Data["Link"].(string))
+		if match == nil {
+			t.Fatalf("token not found in link: %v", links[0].Data)
+		}
+		return match[1], nonce
+	}
+
+	t.Run("Unknown email is not revealed", func(t *testing.T) {
+		emailService.sent = nil
+		nonce, err := authService.RequestMagicLink(ctx, "nobody@example.com")
+		if err != nil || nonce == "" {
+			t.Errorf("expected a nonce for an unknown email, got %q, %v", nonce, err)
+		}
+		if len(emailService.sent) != 0 {
+			t.Errorf("expected no email to be sent, got %d", len(emailService.sent))
+		}
+	})
+
+	t.Run("Link signs in once", func(t *testing.T) {
+		token, nonce := requestLink(t)
+		resp, err := authService.RedeemMagicLink(ctx, token, nonce)
+		if err != nil || resp.AccessToken == "" || resp.User.ID != registered.User.ID {
+			t.Fatalf("expected a magic link login, got %v", err)
+		}
+		if !resp.User.EmailVerified {
+			t.Error("expected the link to verify the email address")
+		}
+		if _, err := authService.RedeemMagicLink(ctx, token, nonce); err == nil {
+			t.Error("expected a used link to be rejected")
+		}
+	})
+
+	t.Run("Link is bound to the requesting browser", func(t *testing.T) {
+		token, nonce := requestLink(t)
+		_, otherNonce := requestLink(t)
+		if _, err := authService.RedeemMagicLink(ctx, token, ""); err == nil {
+			t.Error("expected a link without a nonce to be rejected")
+		}
+		if _, err := authService.RedeemMagicLink(ctx, token, otherNonce); err == nil {
+			t.Error("expected a link with another nonce to be rejected")
+		}
+		// The later request superseded this link
+		if _, err := authService.RedeemMagicLink(ctx, token, nonce); err == nil {
+			t.Error("expected a superseded link to be rejected")
+		}
+	})
+
+	t.Run("Second factor is still required", func(t *testing.T) {
+		enrollment, _ := authService.EnrollTOTP(ctx, registered.User.ID)
+		code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+		if _, err := authService.ConfirmTOTP(ctx, registered.User.ID, code); err != nil {
+			t.Fatalf("ConfirmTOTP failed: %v", err)
+		}
+
+		token, nonce := requestLink(t)
+		var challenge *services.MFAChallengeError
+		if _, err := authService.RedeemMagicLink(ctx, token, nonce); !errors.As(err, &challenge) {
+			t.Fatalf("expected an MFA challenge, got %v", err)
+		}
+	})
+
+	t.Run("Disabled account", func(t *testing.T) {
+		token, nonce := requestLink(t)
+		if err := authService.DisableUser(ctx, registered.User.ID); err != nil {
+			t.Fatalf("DisableUser failed: %v", err)
+		}
+		if _, err := authService.RedeemMagicLink(ctx, token, nonce); !errors.Is(err, services.ErrAccountDisabled) {
+			t.Errorf("expected a disabled account to be refused, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// Second factors offered in an MFA challenge
+const (
+	mfaMethodTOTP         = "totp"
+	mfaMethodRecoveryCode = "recovery_code"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet is Crockford's base32, which leaves out letters
+	// that are easily confused with digits
+	recoveryCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+	// maxMFAAttempts is how many wrong codes burn an MFA challenge
+	maxMFAAttempts = 5
+)
+
+// mfaMethods returns the second factors the user has set up; none means
+// the password alone signs them in
+func (s *authServiceImpl) mfaMethods(ctx context.Context, userID int) ([]string, error) {
+	enabled, err := s.totpRepo.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+	methods := []string{mfaMethodTOTP, mfaMethodRecoveryCode}
+
+	// Passkeys count as a second factor once two-factor authentication is on
+	passkeys, err := s.hasPasskeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if passkeys {
+		methods = append(methods, mfaMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// startMFAChallenge stores a short-lived challenge for a user who has
+// passed the first factor and returns it as an MFAChallengeError. The
+// tokens issued once it is answered get scope.
+func (s *authServiceImpl) startMFAChallenge(ctx context.Context, user *entities.User, methods, scope []string) error {
+	token, err := generateOneTimeToken()
+	if err != nil {
+		return err
+	}
+	if err := s.oneTimeTokenRepo.Create(ctx, &entities.OneTimeToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Purpose:   entities.OneTimeTokenMFAChallenge,
+		ExpiresAt: time.Now().Add(s.config.MFAChallengeExpiry),
+		Scope:     scope,
+	}); err != nil {
+		return fmt.Errorf("failed to store MFA challenge: %w", err)
+	}
+
+	return &services.MFAChallengeError{
+		Token:     token,
+		Methods:   methods,
+		ExpiresIn: int64(s.config.MFAChallengeExpiry.Seconds()),
+	}
+}
+
+// VerifyMFA completes a login that was answered with an MFA challenge,
+// with the scope the login was granted
+func (s *authServiceImpl) VerifyMFA(ctx context.Context, mfaToken, code string) (*dto.AuthResponse, error) {
+	user, challenge, err := s.answerMFAChallenge(ctx, mfaToken, code)
+	if err != nil {
+		return nil, err
+	}
+	// Challenges from before scopes were stored had the full scope
+	scope := challenge.Scope
+	if len(scope) == 0 {
+		scope = s.config.Scopes
+	}
+	return s.issueTokens(ctx, user, tokenSession{scope: scope})
+}
+
+func (s *authServiceImpl) AuthenticateMFA(ctx context.Context, mfaToken, code string) (*entities.User, string, error) {
+	user, _, err := s.answerMFAChallenge(ctx, mfaToken, code)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, secondFactorMethod(code), nil
+}
+
+// answerMFAChallenge checks the second factor and consumes the challenge
+func (s *authServiceImpl) answerMFAChallenge(ctx context.Context, mfaToken, code string) (*entities.User, *entities.OneTimeToken, error) {
+	tokenHash := hashToken(mfaToken)
+	challenge, err := s.oneTimeTokenRepo.Get(ctx, tokenHash, entities.OneTimeTokenMFAChallenge)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired MFA token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired MFA token")
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, services.ErrAccountDisabled
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user.ID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		// A challenge can't be used to brute force the code
+		if err := s.oneTimeTokenRepo.RecordFailedAttempt(ctx, tokenHash, maxMFAAttempts); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, services.ErrInvalidMFACode
+	}
+
+	// The challenge is single-use, even if two requests race with valid codes
+	challenge, err = s.oneTimeTokenRepo.Consume(ctx, tokenHash, entities.OneTimeTokenMFAChallenge)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired MFA token")
+	}
+	return user, challenge, nil
+}
+
+// EnrollTOTP starts authenticator app enrollment. The secret only becomes
+// a second factor once ConfirmTOTP proves the app was set up correctly.
+func (s *authServiceImpl) EnrollTOTP(ctx context.Context, userID int) (*dto.TOTPEnrollmentResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := s.totpRepo.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	secret, err := s.totpService.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.Save(ctx, &entities.TOTPCredential{
+		UserID: user.ID,
+		Secret: secret,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &dto.TOTPEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: s.totpService.ProvisioningURI(secret, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP turns on two-factor authentication once the user enters a
+// code from their app, and returns their recovery codes
+func (s *authServiceImpl) ConfirmTOTP(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error) {
+	credential, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("no two-factor enrollment in progress")
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	step, ok := s.totpService.Validate(credential.Secret, code, time.Now())
+	if !ok {
+		return nil, services.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.totpRepo.Confirm(ctx, userID); err != nil {
+			return err
+		}
+		// The confirmation code can't also be used to sign in
+		if _, err := s.totpRepo.MarkUsed(ctx, userID, step); err != nil {
+			return err
+		}
+		return s.recoveryCodeRepo.Replace(ctx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventMFAEnabled, "authenticator app enrolled")
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off, given a current code
+func (s *authServiceImpl) DisableTOTP(ctx context.Context, userID int, code string) error {
+	if err := s.confirmSecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.totpRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		return s.recoveryCodeRepo.DeleteByUser(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventMFADisabled, "authenticator app removed")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, given a current code
+func (s *authServiceImpl) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error) {
+	if err := s.confirmSecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recoveryCodeRepo.Replace(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventRecoveryCodesRegenerated, "recovery codes regenerated")
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// confirmSecondFactor guards a change to the account with a current code.
+// Unlike a login challenge, an access token can ask again and again, so the
+// tries are counted per user and lock after too many wrong codes.
+func (s *authServiceImpl) confirmSecondFactor(ctx context.Context, userID int, code string) error {
+	key := secondFactorKey(userID)
+	if err := s.oneTimeCodeStore.Attempt(ctx, key); err != nil {
+		return err
+	}
+	ok, err := s.checkSecondFactor(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return services.ErrInvalidMFACode
+	}
+	return s.oneTimeCodeStore.Reset(ctx, key)
+}
+
+func secondFactorKey(userID int) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
+
+// checkSecondFactor accepts a code from the user's authenticator app or one
+// of their recovery codes. Either can only be used once.
+func (s *authServiceImpl) checkSecondFactor(ctx context.Context, userID int, code string) (bool, error) {
+	enabled, err := s.totpRepo.IsEnabled(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !enabled {
+		return false, fmt.Errorf("two-factor authentication is not enabled")
+	}
+
+	if !isTOTPCode(code) {
+		return s.useRecoveryCode(ctx, userID, code)
+	}
+
+	credential, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	step, ok := s.totpService.Validate(credential.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Refuse a code that was already used, e.g. one seen over a shoulder
+	return s.totpRepo.MarkUsed(ctx, userID, step)
+}
+
+func (s *authServiceImpl) useRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	used, err := s.recoveryCodeRepo.Consume(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil || !used {
+		return false, err
+	}
+
+	remaining, err := s.recoveryCodeRepo.CountUnused(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventRecoveryCodeUsed,
+		fmt.Sprintf("recovery code used; %d remaining", remaining))
+	return true, nil
+}
+
+// secondFactorMethod is the amr value of a code checkSecondFactor accepted
+func secondFactorMethod(code string) string {
+	if isTOTPCode(code) {
+		return dto.AMROneTimePassword
+	}
+	return dto.AMRRecoveryCode
+}
+
+// isTOTPCode tells authenticator app codes apart from recovery codes
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRecoveryCodes returns codes formatted for the user, and the
+// hashes that are stored
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[b[j]%byte(len(recoveryCodeAlphabet))]
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode makes codes match however they are typed
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/totp"
+)
+
+func TestAuthService_TOTPLogin(t *testing.T) {
+	userRepo := newMockUserRepository()
+	securityEventRepo := newMockSecurityEventRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:          userRepo,
+		SecurityEventRepo: securityEventRepo,
+		EmailService:      emailService,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "mfauser",
+		Email:    "mfa@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+	login := &dto.LoginRequest{Email: "mfa@example.com", Password: "password123"}
+
+	// startLogin returns the MFA token for a login that must be challenged
+	startLogin := func(t *testing.T) string {
+		t.Helper()
+		resp, err := authService.Login(ctx, login)
+		var challenge *services.MFAChallengeError
+		if !errors.As(err, &challenge) || resp != nil {
+			t.Fatalf("expected an MFA challenge, got %v, %v", resp, err)
+		}
+		if !errors.Is(err, services.ErrMFARequired) || challenge.Token == "" || challenge.ExpiresIn != 300 {
+			t.Fatalf("unexpected challenge %+v", challenge)
+		}
+		return challenge.Token
+	}
+
+	enrollment, err := authService.EnrollTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP failed: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/") || !strings.Contains(enrollment.ProvisioningURI, enrollment.Secret) {
+		t.Errorf("unexpected provisioning URI %q", enrollment.ProvisioningURI)
+	}
+
+	// An unconfirmed enrollment doesn't protect the account yet
+	if _, err := authService.Login(ctx, login); err != nil {
+		t.Fatalf("expected login without MFA before confirmation, got %v", err)
+	}
+
+	if _, err := authService.ConfirmTOTP(ctx, userID, "000000"); !errors.Is(err, services.ErrInvalidMFACode) {
+		t.Fatalf("expected a wrong confirmation code to be rejected, got %v", err)
+	}
+	now := time.Now()
+	code, _ := totp.GenerateCode(enrollment.Secret, now)
+	recovery, err := authService.ConfirmTOTP(ctx, userID, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTP failed: %v", err)
+	}
+	if len(recovery.RecoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(recovery.RecoveryCodes))
+	}
+	if len(emailService.withTemplate(services.EmailTemplateSecurityAlert)) != 1 {
+		t.Error("expected the user to be alerted that MFA was enabled")
+	}
+
+	t.Run("TOTP code", func(t *testing.T) {
+		mfaToken := startLogin(t)
+
+		if _, err := authService.VerifyMFA(ctx, mfaToken, "123456"); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a wrong code to be rejected, got %v", err)
+		}
+		// The code that confirmed the enrollment can't be replayed
+		if _, err := authService.VerifyMFA(ctx, mfaToken, code); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a used code to be rejected, got %v", err)
+		}
+
+		next, _ := totp.GenerateCode(enrollment.Secret, now.Add(30*time.Second))
+		resp, err := authService.VerifyMFA(ctx, mfaToken, next)
+		if err != nil || resp.AccessToken == "" {
+			t.Fatalf("expected tokens for a valid code, got %v", err)
+		}
+
+		// The challenge is single-use
+		if _, err := authService.VerifyMFA(ctx, mfaToken, next); err == nil {
+			t.Error("expected a used MFA token to be rejected")
+		}
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		mfaToken := startLogin(t)
+		if _, err := authService.VerifyMFA(ctx, mfaToken, strings.ToUpper(recovery.RecoveryCodes[0])); err != nil {
+			t.Fatalf("expected a recovery code to sign in, got %v", err)
+		}
+
+		mfaToken = startLogin(t)
+		if _, err := authService.VerifyMFA(ctx, mfaToken, recovery.RecoveryCodes[0]); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a used recovery code to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("Consent page login reports the method", func(t *testing.T) {
+		user, method, err := authService.AuthenticateMFA(ctx, startLogin(t), recovery.RecoveryCodes[4])
+		if err != nil || user.ID != userID {
+			t.Fatalf("AuthenticateMFA failed: %v", err)
+		}
+		if method != dto.AMRRecoveryCode {
+			t.Errorf("expected a recovery code to be reported as %q, got %q", dto.AMRRecoveryCode, method)
+		}
+	})
+
+	t.Run("Login scope is kept", func(t *testing.T) {
+		_, err := authService.Login(ctx, &dto.LoginRequest{Email: "mfa@example.com", Password: "password123", Scope: "profile"})
+		var challenge *services.MFAChallengeError
+		if !errors.As(err, &challenge) {
+			t.Fatalf("expected an MFA challenge, got %v", err)
+		}
+		resp, err := authService.VerifyMFA(ctx, challenge.Token, recovery.RecoveryCodes[3])
+		if err != nil {
+			t.Fatalf("VerifyMFA failed: %v", err)
+		}
+		if resp.Scope != "profile" {
+			t.Errorf("expected scope profile, got %q", resp.Scope)
+		}
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		if !slices.Equal(claims.Scope, []string{"profile"}) {
+			t.Errorf("expected the token to carry scope [profile], got %v", claims.Scope)
+		}
+
+		if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "mfa@example.com", Password: "password123", Scope: "unknown"}); !errors.Is(err, services.ErrInvalidScope) {
+			t.Errorf("expected an unknown scope to be refused before the challenge, got %v", err)
+		}
+	})
+
+	t.Run("Attempts are limited", func(t *testing.T) {
+		mfaToken := startLogin(t)
+		for i := 0; i < 5; i++ {
+			authService.VerifyMFA(ctx, mfaToken, "000000")
+		}
+		if _, err := authService.VerifyMFA(ctx, mfaToken, recovery.RecoveryCodes[1]); err == nil || errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected the challenge to be burned after too many attempts, got %v", err)
+		}
+	})
+
+	t.Run("Regenerate and disable", func(t *testing.T) {
+		regenerated, err := authService.RegenerateRecoveryCodes(ctx, userID, recovery.RecoveryCodes[2])
+		if err != nil {
+			t.Fatalf("RegenerateRecoveryCodes failed: %v", err)
+		}
+		if err := authService.DisableTOTP(ctx, userID, recovery.RecoveryCodes[3]); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected old recovery codes to stop working, got %v", err)
+		}
+		if err := authService.DisableTOTP(ctx, userID, regenerated.RecoveryCodes[0]); err != nil {
+			t.Fatalf("DisableTOTP failed: %v", err)
+		}
+		if resp, err := authService.Login(ctx, login); err != nil || resp.AccessToken == "" {
+			t.Errorf("expected a password-only login after disabling MFA, got %v", err)
+		}
+	})
+}
+
+func TestAuthService_SecondFactorChecksAreLimited(t *testing.T) {
+	authService := newTestAuthService(t, appservices.AuthDeps{})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "guessed",
+		Email:    "guessed@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+
+	enrollment, err := authService.EnrollTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("EnrollTOTP failed: %v", err)
+	}
+	now := time.Now()
+	code, _ := totp.GenerateCode(enrollment.Secret, now)
+	if _, err := authService.ConfirmTOTP(ctx, userID, code); err != nil {
+		t.Fatalf("ConfirmTOTP failed: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := authService.DisableTOTP(ctx, userID, "000000"); !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Fatalf("expected attempt %d to be a wrong code, got %v", i+1, err)
+		}
+	}
+	next, _ := totp.GenerateCode(enrollment.Secret, now.Add(30*time.Second))
+	if err := authService.DisableTOTP(ctx, userID, next); !errors.Is(err, services.ErrTooManyAttempts) {
+		t.Errorf("expected the 6th attempt to be refused even with the right code, got %v", err)
+	}
+	if _, err := authService.RegenerateRecoveryCodes(ctx, userID, next); !errors.Is(err, services.ErrTooManyAttempts) {
+		t.Errorf("expected recovery codes to be locked too, got %v", err)
+	}
+	if _, err := authService.Login(ctx, &dto.LoginRequest{Email: "guessed@example.com", Password: "password123"}); !errors.Is(err, services.ErrMFARequired) {
+		t.Errorf("expected two-factor authentication to stay on, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// OAuth 2.0 grant types served by the token endpoint
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+// pkceVerifierPattern is the code_verifier syntax of RFC 7636 section 4.1
+var pkceVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// maxNonceLength bounds the nonce kept with an authorization code
+const maxNonceLength = 255
+
+// authorizationGrant is what an authorization code stands for until it is
+// redeemed
+type authorizationGrant struct {
+	ClientID      string   `json:"client_id"`
+	UserID        int      `json:"user_id"`
+	RedirectURI   string   `json:"redirect_uri"`
+	Scope         []string `json:"scope"`
+	CodeChallenge string   `json:"code_challenge"`
+	// The OpenID Connect nonce and how the user signed in, for the ID token
+	Nonce    string   `json:"nonce,omitempty"`
+	AuthTime int64    `json:"auth_time"`
+	AMR      []string `json:"amr"`
+}
+
+func oauthError(code, format string, args ...interface{}) *services.OAuthError {
+	return &services.OAuthError{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+func (s *authServiceImpl) CheckAuthorizationRequest(ctx context.Context, req *dto.AuthorizationRequest) (*dto.AuthorizationPrompt, error) {
+	client, err := s.oauthClientRepo.GetByID(ctx, req.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", services.ErrInvalidClient, err)
+	}
+	// Anything else about the request is only reported back to a redirect
+	// URI the client registered
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, fmt.Errorf("%w: redirect URI is not registered for the client", services.ErrInvalidClient)
+	}
+
+	if req.ResponseType != "code" {
+		return nil, oauthError("unsupported_response_type", "only the authorization code flow is supported")
+	}
+	// PKCE is mandatory, and only with S256
+	if req.CodeChallenge == "" {
+		return nil, oauthError("invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return nil, oauthError("invalid_request", "code_challenge_method must be S256")
+	}
+	if challenge, err := base64.RawURLEncoding.DecodeString(req.CodeChallenge); err != nil || len(challenge) != sha256.Size {
+		return nil, oauthError("invalid_request", "code_challenge is not a base64url SHA-256 hash")
+	}
+	if len(req.Nonce) > maxNonceLength {
+		return nil, oauthError("invalid_request", "nonce is longer than %d characters", maxNonceLength)
+	}
+
+	scope, err := grantScope(req.Scope, s.clientScopes(client))
+	if err != nil {
+		return nil, oauthError("invalid_scope", "%v", err)
+	}
+	return &dto.AuthorizationPrompt{ClientName: client.Name, Scope: scope}, nil
+}
+
+func (s *authServiceImpl) IssueAuthorizationCode(ctx context.Context, req *dto.AuthorizationRequest, auth *dto.Authentication) (string, error) {
+	// The request comes back from the browser, so it is checked again
+	prompt, err := s.CheckAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := generateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+	grant, err := json.Marshal(authorizationGrant{
+		ClientID:      req.ClientID,
+		UserID:        auth.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         prompt.Scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      auth.Time.Unix(),
+		AMR:           auth.Methods,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.challengeStore.Put(ctx, "oauth_code:"+hashToken(code), string(grant), s.config.OAuthCodeExpiry); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	s.recordSecurityEvent(ctx, auth.UserID, entities.SecurityEventOAuthAuthorized,
+		fmt.Sprintf("%s (%s) authorized for %s", prompt.ClientName, req.ClientID, strings.Join(prompt.Scope, " ")))
+	return code, nil
+}
+
+func (s *authServiceImpl) OAuthToken(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case grantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, req)
+	case grantTypeRefreshToken:
+		return s.refreshOAuthToken(ctx, req)
+	case grantTypeClientCredentials:
+		return s.clientCredentialsGrant(ctx, req)
+	case grantTypeDeviceCode:
+		return s.deviceCodeGrant(ctx, req)
+	case "":
+		return nil, oauthError("invalid_request", "grant_type is required")
+	}
+	return nil, oauthError("unsupported_grant_type", "grant type %s is not supported", req.GrantType)
+}
+
+// exchangeAuthorizationCode redeems a code for tokens. The code is gone
+// after the first attempt, whether or not it succeeds.
+func (s *authServiceImpl) exchangeAuthorizationCode(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauthError("invalid_request", "code and code_verifier are required")
+	}
+
+	value, found, err := s.challengeStore.Take(ctx, "oauth_code:"+hashToken(req.Code))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, oauthError("invalid_grant", "authorization code is invalid, expired or already used")
+	}
+	var grant authorizationGrant
+	if err := json.Unmarshal([]byte(value), &grant); err != nil {
+		return nil, fmt.Errorf("failed to decode authorization code: %w", err)
+	}
+
+	if grant.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client")
+	}
+	if grant.RedirectURI != req.RedirectURI {
+		return nil, oauthError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(req.CodeVerifier, grant.CodeChallenge) {
+		return nil, oauthError("invalid_grant", "code_verifier does not match the code_challenge")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, grant.UserID)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "user not found")
+	}
+	if user.DisabledAt != nil {
+		return nil, oauthError("invalid_grant", "%v", services.ErrAccountDisabled)
+	}
+
+	// The ID token names the session so the client can end it at logout
+	familyID, err := generateRandomID()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.issueTokens(ctx, user, tokenSession{familyID: familyID, scope: grant.Scope, clientID: client.ID})
+	if err != nil {
+		return nil, err
+	}
+	tokens := oauthTokenResponse(resp)
+	if slices.Contains(grant.Scope, scopeOpenID) {
+		if tokens.IDToken, err = s.issueIDToken(user, &grant, familyID); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+func (s *authServiceImpl) refreshOAuthToken(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.RefreshToken == "" {
+		return nil, oauthError("invalid_request", "refresh_token is required")
+	}
+
+	resp, err := s.refreshSession(ctx, req.RefreshToken, req.Scope, client.ID)
+	if errors.Is(err, services.ErrInvalidScope) {
+		return nil, oauthError("invalid_scope", "%v", err)
+	}
+	if err != nil {
+		return nil, oauthError("invalid_grant", "%v", err)
+	}
+	return oauthTokenResponse(resp), nil
+}
+
+// authenticateClient identifies the client calling the token endpoint.
+// Confidential clients prove who they are with their secret or a signed
+// assertion; for public clients PKCE is what ties a code to the client
+// that asked for it.
+func (s *authServiceImpl) authenticateClient(ctx context.Context, req *dto.OAuthTokenRequest) (*entities.OAuthClient, error) {
+	clientID := req.ClientID
+	if clientID == "" && req.ClientAssertion != "" {
+		clientID = assertionIssuer(req.ClientAssertion)
+	}
+	if clientID == "" {
+		return nil, oauthError("invalid_client", "client_id is required")
+	}
+	client, err := s.oauthClientRepo.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, oauthError("invalid_client", "unknown client")
+	}
+
+	switch client.AuthMethod {
+	case entities.ClientAuthSecretBasic, entities.ClientAuthSecretPost:
+		// Either way of sending the secret is accepted
+		if req.ClientSecret == "" || subtle.ConstantTimeCompare([]byte(hashToken(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+			return nil, oauthError("invalid_client", "client authentication failed")
+		}
+	case entities.ClientAuthPrivateKeyJWT:
+		if err := s.verifyClientAssertion(ctx, client, req); err != nil {
+			return nil, err
+		}
+	default:
+		if req.ClientSecret != "" || req.ClientAssertion != "" {
+			return nil, oauthError("invalid_client", "the client is public and has no credentials")
+		}
+	}
+	return client, nil
+}
+
+// clientScopes are the scopes the client may still be granted
+func (s *authServiceImpl) clientScopes(client *entities.OAuthClient) []string {
+	var scopes []string
+	known := s.knownScopes()
+	for _, scope := range client.Scopes {
+		if slices.Contains(known, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// verifyPKCE checks the code_verifier against an S256 code_challenge
+func verifyPKCE(verifier, challenge string) bool {
+	if !pkceVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func oauthTokenResponse(resp *dto.AuthResponse) *dto.OAuthTokenResponse {
+	return &dto.OAuthTokenResponse{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		ExpiresIn:    resp.ExpiresIn,
+		RefreshToken: resp.RefreshToken,
+		Scope:        resp.Scope,
+	}
+}
+
+// RegisterOAuthClient validates and stores a client. An empty ID is
+// generated, and so is the secret of a client that authenticates with one.
+func (s *authServiceImpl) RegisterOAuthClient(ctx context.Context, client *entities.OAuthClient) (string, error) {
+	if strings.TrimSpace(client.Name) == "" {
+		return "", fmt.Errorf("client name is required")
+	}
+
+	var secret string
+	switch client.AuthMethod {
+	case "", entities.ClientAuthNone:
+		client.AuthMethod = entities.ClientAuthNone
+		// Public clients only get tokens through the authorization endpoint
+		if len(client.RedirectURIs) == 0 {
+			return "", fmt.Errorf("at least one redirect URI is required")
+		}
+	case entities.ClientAuthSecretBasic, entities.ClientAuthSecretPost:
+		var err error
+		if secret, err = generateOneTimeToken(); err != nil {
+			return "", err
+		}
+		client.SecretHash = hashToken(secret)
+	case entities.ClientAuthPrivateKeyJWT:
+		if err := validateClientKey(client.PublicKey); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported token endpoint auth method %s", client.AuthMethod)
+	}
+
+	for _, redirectURI := range append(slices.Clone(client.RedirectURIs), client.PostLogoutRedirectURIs...) {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return "", err
+		}
+	}
+	if len(client.Scopes) == 0 {
+		return "", fmt.Errorf("at least one scope is required")
+	}
+	known := s.knownScopes()
+	for _, scope := range client.Scopes {
+		if !slices.Contains(known, scope) {
+			return "", fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+
+	if client.ID == "" {
+		id, err := generateRandomID()
+		if err != nil {
+			return "", err
+		}
+		client.ID = id
+	}
+	if err := s.oauthClientRepo.Create(ctx, client); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (s *authServiceImpl) ListOAuthClients(ctx context.Context) ([]*entities.OAuthClient, error) {
+	return s.oauthClientRepo.List(ctx)
+}
+
+// DeleteOAuthClient removes the client. Tokens already issued to it stay
+// valid until they expire, but can't be refreshed.
+func (s *authServiceImpl) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	return s.oauthClientRepo.Delete(ctx, clientID)
+}
+
+// validateRedirectURI accepts absolute URIs without a fragment: https, http
+// on the loopback interface for native apps, and private-use schemes
+// (RFC 8252)
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("redirect URI %s is not an absolute URI", redirectURI)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("redirect URI %s must not have a fragment", redirectURI)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+		return fmt.Errorf("redirect URI %s must use https unless it is on the loopback interface", redirectURI)
+	case "javascript", "data", "file", "vbscript":
+		return fmt.Errorf("redirect URI %s has a forbidden scheme", redirectURI)
+	}
+	return nil
+}
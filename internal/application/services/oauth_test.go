@@ -0,0 +1,190 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_OAuthAuthorizationCode(t *testing.T) {
+	authService := newTestAuthService(t, appservices.AuthDeps{})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "oauthuser",
+		Email:    "oauth@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+
+	client := &entities.OAuthClient{
+		Name:         "Example App",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"profile", "account"},
+	}
+	if _, err := authService.RegisterOAuthClient(ctx, client); err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+	if client.ID == "" {
+		t.Fatal("expected the client to get an ID")
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	authorizationRequest := func() *dto.AuthorizationRequest {
+		return &dto.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            client.ID,
+			RedirectURI:         "https://app.example.com/callback",
+			Scope:               "profile",
+			State:               "xyz",
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: "S256",
+		}
+	}
+
+	// issueCode approves an authorization request for the user
+	issueCode := func(t *testing.T) string {
+		t.Helper()
+		code, err := authService.IssueAuthorizationCode(ctx, authorizationRequest(), &dto.Authentication{
+			UserID:  userID,
+			Time:    time.Now(),
+			Methods: []string{dto.AMRPassword},
+		})
+		if err != nil {
+			t.Fatalf("IssueAuthorizationCode failed: %v", err)
+		}
+		return code
+	}
+
+	exchange := func(code, codeVerifier string) (*dto.OAuthTokenResponse, error) {
+		return authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "authorization_code",
+			ClientID:     client.ID,
+			Code:         code,
+			RedirectURI:  "https://app.example.com/callback",
+			CodeVerifier: codeVerifier,
+		})
+	}
+
+	// expectOAuthError checks err is the OAuth error code
+	expectOAuthError := func(t *testing.T, err error, code string) {
+		t.Helper()
+		var oauthErr *services.OAuthError
+		if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+			t.Errorf("expected OAuth error %s, got %v", code, err)
+		}
+	}
+
+	t.Run("Redirect URIs are checked", func(t *testing.T) {
+		if _, err := authService.RegisterOAuthClient(ctx, &entities.OAuthClient{
+			Name:         "Insecure App",
+			RedirectURIs: []string{"http://app.example.com/callback"},
+			Scopes:       []string{"profile"},
+		}); err == nil {
+			t.Error("expected a plain http redirect URI to be refused")
+		}
+	})
+
+	t.Run("Untrusted requests are not redirected", func(t *testing.T) {
+		req := authorizationRequest()
+		req.ClientID = "unknown"
+		if _, err := authService.CheckAuthorizationRequest(ctx, req); !errors.Is(err, services.ErrInvalidClient) {
+			t.Errorf("expected ErrInvalidClient for an unknown client, got %v", err)
+		}
+
+		req = authorizationRequest()
+		req.RedirectURI = "https://evil.example.com/callback"
+		if _, err := authService.CheckAuthorizationRequest(ctx, req); !errors.Is(err, services.ErrInvalidClient) {
+			t.Errorf("expected ErrInvalidClient for an unregistered redirect URI, got %v", err)
+		}
+	})
+
+	t.Run("PKCE is required", func(t *testing.T) {
+		req := authorizationRequest()
+		req.CodeChallenge = ""
+		_, err := authService.CheckAuthorizationRequest(ctx, req)
+		expectOAuthError(t, err, "invalid_request")
+
+		req = authorizationRequest()
+		req.CodeChallengeMethod = "plain"
+		_, err = authService.CheckAuthorizationRequest(ctx, req)
+		expectOAuthError(t, err, "invalid_request")
+	})
+
+	t.Run("Scope is limited to the client's", func(t *testing.T) {
+		req := authorizationRequest()
+		req.Scope = "profile admin"
+		_, err := authService.CheckAuthorizationRequest(ctx, req)
+		expectOAuthError(t, err, "invalid_scope")
+	})
+
+	t.Run("Codes are exchanged once", func(t *testing.T) {
+		code := issueCode(t)
+		resp, err := exchange(code, verifier)
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+		if resp.Scope != "profile" {
+			t.Errorf("expected scope profile, got %q", resp.Scope)
+		}
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		if claims.UserID != userID || claims.ClientID != client.ID || !slices.Equal(claims.Scope, []string{"profile"}) {
+			t.Errorf("unexpected claims: user %d, client %q, scope %v", claims.UserID, claims.ClientID, claims.Scope)
+		}
+
+		_, err = exchange(code, verifier)
+		expectOAuthError(t, err, "invalid_grant")
+	})
+
+	t.Run("Wrong verifier is refused", func(t *testing.T) {
+		code := issueCode(t)
+		_, err := exchange(code, "wrong-verifier-wrong-verifier-wrong-verifier-x")
+		expectOAuthError(t, err, "invalid_grant")
+	})
+
+	t.Run("Refresh tokens stay with the client", func(t *testing.T) {
+		resp, err := exchange(issueCode(t), verifier)
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+
+		refreshed, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "refresh_token",
+			ClientID:     client.ID,
+			RefreshToken: resp.RefreshToken,
+		})
+		if err != nil {
+			t.Fatalf("OAuthToken refresh failed: %v", err)
+		}
+		if refreshed.Scope != "profile" {
+			t.Errorf("expected the refreshed scope to be profile, got %q", refreshed.Scope)
+		}
+
+		if _, err := authService.RefreshToken(ctx, refreshed.RefreshToken, ""); err == nil {
+			t.Error("expected the client's refresh token to be refused outside the token endpoint")
+		}
+	})
+
+	t.Run("Unsupported grant types are refused", func(t *testing.T) {
+		_, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{GrantType: "password", ClientID: client.ID})
+		expectOAuthError(t, err, "unsupported_grant_type")
+	})
+}
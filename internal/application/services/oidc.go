@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// OpenID Connect scopes. "profile" doubles as the scope of the profile
+// routes.
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+)
+
+var oidcScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+// Authentication context class references: a password alone, or a password
+// and a second factor
+const (
+	acrSingleFactor = "1"
+	acrMultiFactor  = "2"
+)
+
+// oidcEnabled reports whether the server acts as an OpenID Connect provider.
+// Clients verify ID tokens with the published JWKS, and an HMAC secret is
+// never published, so that takes an asymmetric signing key.
+func (s *authServiceImpl) oidcEnabled() bool {
+	return !strings.HasPrefix(s.jwtManager.SigningAlgorithm(), "HS")
+}
+
+func (s *authServiceImpl) OpenIDConfiguration() *dto.OpenIDConfiguration {
+	if !s.oidcEnabled() {
+		return nil
+	}
+	issuer := s.config.Issuer
+	return &dto.OpenIDConfiguration{
+		Issuer:                      issuer,
+		AuthorizationEndpoint:       issuer + "/oauth/authorize",
+		TokenEndpoint:               issuer + "/oauth/token",
+		UserInfoEndpoint:            issuer + "/oauth/userinfo",
+		EndSessionEndpoint:          issuer + "/oauth/logout",
+		DeviceAuthorizationEndpoint: issuer + "/oauth/device_authorization",
+		JWKSURI:                     issuer + "/.well-known/jwks.json",
+		ScopesSupported:             s.knownScopes(),
+		ResponseTypesSupported:      []string{"code"},
+		ResponseModesSupported:      []string{"query"},
+		GrantTypesSupported: []string{grantTypeAuthorizationCode, grantTypeRefreshToken, grantTypeClientCredentials,
+			grantTypeDeviceCode},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{s.jwtManager.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{entities.ClientAuthNone, entities.ClientAuthSecretBasic,
+			entities.ClientAuthSecretPost, entities.ClientAuthPrivateKeyJWT},
+		TokenEndpointAuthSigningAlgValuesSupported: []string{"RS256", "ES256", "EdDSA"},
+		CodeChallengeMethodsSupported:              []string{"S256"},
+		ClaimsSupported: []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "azp", "sid",
+			"preferred_username", "updated_at", "email", "email_verified"},
+		ACRValuesSupported: []string{acrSingleFactor, acrMultiFactor},
+	}
+}
+
+// issueIDToken signs the ID token for a redeemed authorization code.
+// Its sid is the session of the tokens issued with it.
+func (s *authServiceImpl) issueIDToken(user *entities.User, grant *authorizationGrant, familyID string) (string, error) {
+	if !s.oidcEnabled() {
+		return "", fmt.Errorf("ID tokens need an asymmetric signing key, not %s", s.jwtManager.SigningAlgorithm())
+	}
+	acr := acrSingleFactor
+	if slices.Contains(grant.AMR, dto.AMRMultiFactor) {
+		acr = acrMultiFactor
+	}
+
+	claims := standardClaims(user, grant.Scope)
+	claims["iss"] = s.config.Issuer
+	claims["aud"] = grant.ClientID
+	claims["azp"] = grant.ClientID
+	claims["auth_time"] = grant.AuthTime
+	claims["acr"] = acr
+	claims["amr"] = grant.AMR
+	claims["sid"] = familyID
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+
+	idToken, err := s.jwtManager.GenerateIDToken(fmt.Sprintf("%d", user.ID), claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ID token: %w", err)
+	}
+	return idToken, nil
+}
+
+// standardClaims maps the user to the OpenID Connect standard claims the
+// scope allows
+func standardClaims(user *entities.User, scope []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": fmt.Sprintf("%d", user.ID),
+	}
+	if slices.Contains(scope, scopeProfile) {
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scope, scopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	return claims
+}
+
+func (s *authServiceImpl) UserInfo(ctx context.Context, userID int, scope []string) (map[string]interface{}, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, services.ErrAccountDisabled
+	}
+	return standardClaims(user, scope), nil
+}
+
+// EndSession serves RP-initiated logout. There is no browser session at
+// the server, so logging out ends the tokens the ID token was issued with.
+func (s *authServiceImpl) EndSession(ctx context.Context, req *dto.EndSessionRequest) error {
+	if req.IDTokenHint == "" {
+		return oauthError("invalid_request", "id_token_hint is required")
+	}
+	claims, err := s.jwtManager.ValidateIDToken(req.IDTokenHint)
+	if err != nil {
+		return oauthError("invalid_request", "id_token_hint is not a valid ID token")
+	}
+	if issuer, _ := claims["iss"].(string); issuer != s.config.Issuer {
+		return oauthError("invalid_request", "id_token_hint was issued by another server")
+	}
+
+	clientID, _ := claims["aud"].(string)
+	if req.ClientID != "" && req.ClientID != clientID {
+		return fmt.Errorf("%w: id_token_hint was issued to another client", services.ErrInvalidClient)
+	}
+	if req.PostLogoutRedirectURI != "" {
+		client, err := s.oauthClientRepo.GetByID(ctx, clientID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", services.ErrInvalidClient, err)
+		}
+		if !slices.Contains(client.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+			return fmt.Errorf("%w: post-logout redirect URI is not registered for the client", services.ErrInvalidClient)
+		}
+	}
+
+	if familyID, _ := claims["sid"].(string); familyID != "" {
+		return s.revokeFamily(ctx, familyID)
+	}
+	return nil
+}
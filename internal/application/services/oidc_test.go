@@ -0,0 +1,218 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_OpenIDConnect(t *testing.T) {
+	jwtManager := newTestOIDCJWTManager(t)
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		JWTManager: jwtManager,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "oidcuser",
+		Email:    "oidc@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+
+	client := &entities.OAuthClient{
+		Name:                   "Example RP",
+		RedirectURIs:           []string{"https://rp.example.com/callback"},
+		PostLogoutRedirectURIs: []string{"https://rp.example.com/"},
+		Scopes:                 []string{"openid", "profile", "email"},
+	}
+	if _, err := authService.RegisterOAuthClient(ctx, client); err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	authTime := time.Now().Add(-time.Second).Truncate(time.Second)
+
+	// signIn runs the authorization code flow for the scope
+	signIn := func(t *testing.T, scope string, methods []string) *dto.OAuthTokenResponse {
+		t.Helper()
+		code, err := authService.IssueAuthorizationCode(ctx, &dto.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            client.ID,
+			RedirectURI:         "https://rp.example.com/callback",
+			Scope:               scope,
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: "S256",
+			Nonce:               "n-0S6_WzA2Mj",
+		}, &dto.Authentication{UserID: userID, Time: authTime, Methods: methods})
+		if err != nil {
+			t.Fatalf("IssueAuthorizationCode failed: %v", err)
+		}
+		resp, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "authorization_code",
+			ClientID:     client.ID,
+			Code:         code,
+			RedirectURI:  "https://rp.example.com/callback",
+			CodeVerifier: verifier,
+		})
+		if err != nil {
+			t.Fatalf("OAuthToken failed: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Discovery", func(t *testing.T) {
+		config := authService.OpenIDConfiguration()
+		if config.Issuer != "https://auth.example.com" || config.TokenEndpoint != "https://auth.example.com/oauth/token" {
+			t.Errorf("unexpected endpoints: %+v", config)
+		}
+		if !slices.Contains(config.ScopesSupported, "openid") || !slices.Equal(config.CodeChallengeMethodsSupported, []string{"S256"}) {
+			t.Errorf("unexpected capabilities: %+v", config)
+		}
+	})
+
+	t.Run("ID token", func(t *testing.T) {
+		resp := signIn(t, "openid email", []string{dto.AMRPassword, dto.AMROneTimePassword, dto.AMRMultiFactor})
+		if resp.IDToken == "" {
+			t.Fatal("expected an ID token for the openid scope")
+		}
+		claims, err := jwtManager.ValidateIDToken(resp.IDToken)
+		if err != nil {
+			t.Fatalf("ValidateIDToken failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"iss":            "https://auth.example.com",
+			"sub":            fmt.Sprintf("%d", userID),
+			"aud":            client.ID,
+			"nonce":          "n-0S6_WzA2Mj",
+			"auth_time":      float64(authTime.Unix()),
+			"acr":            "2",
+			"email":          "oidc@example.com",
+			"email_verified": false,
+		}
+		for name, value := range expected {
+			if claims[name] != value {
+				t.Errorf("expected %s to be %v, got %v", name, value, claims[name])
+			}
+		}
+		if _, ok := claims["preferred_username"]; ok {
+			t.Error("expected no profile claims without the profile scope")
+		}
+		if amr := fmt.Sprint(claims["amr"]); amr != "[pwd otp mfa]" {
+			t.Errorf("expected amr [pwd otp mfa], got %s", amr)
+		}
+	})
+
+	t.Run("No ID token without openid", func(t *testing.T) {
+		if resp := signIn(t, "profile", []string{dto.AMRPassword}); resp.IDToken != "" {
+			t.Error("expected no ID token without the openid scope")
+		}
+	})
+
+	t.Run("UserInfo follows the scope", func(t *testing.T) {
+		resp := signIn(t, "openid profile", []string{dto.AMRPassword})
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		info, err := authService.UserInfo(ctx, claims.UserID, claims.Scope)
+		if err != nil {
+			t.Fatalf("UserInfo failed: %v", err)
+		}
+		if info["sub"] != fmt.Sprintf("%d", userID) || info["preferred_username"] != "oidcuser" {
+			t.Errorf("unexpected userinfo %v", info)
+		}
+		if _, ok := info["email"]; ok {
+			t.Error("expected no email without the email scope")
+		}
+	})
+
+	t.Run("Logout ends the session", func(t *testing.T) {
+		resp := signIn(t, "openid", []string{dto.AMRPassword})
+
+		err := authService.EndSession(ctx, &dto.EndSessionRequest{
+			IDTokenHint:           resp.IDToken,
+			PostLogoutRedirectURI: "https://evil.example.com/",
+		})
+		if !errors.Is(err, services.ErrInvalidClient) {
+			t.Errorf("expected an unregistered post-logout URI to be refused, got %v", err)
+		}
+
+		if err := authService.EndSession(ctx, &dto.EndSessionRequest{
+			IDTokenHint:           resp.IDToken,
+			ClientID:              client.ID,
+			PostLogoutRedirectURI: "https://rp.example.com/",
+		}); err != nil {
+			t.Fatalf("EndSession failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, resp.AccessToken); err == nil {
+			t.Error("expected the access token to be revoked")
+		}
+		if _, err := authService.OAuthToken(ctx, &dto.OAuthTokenRequest{
+			GrantType:    "refresh_token",
+			ClientID:     client.ID,
+			RefreshToken: resp.RefreshToken,
+		}); err == nil {
+			t.Error("expected the refresh token to be revoked")
+		}
+	})
+
+	t.Run("Logout needs an ID token", func(t *testing.T) {
+		resp := signIn(t, "openid", []string{dto.AMRPassword})
+		var oauthErr *services.OAuthError
+		if err := authService.EndSession(ctx, &dto.EndSessionRequest{IDTokenHint: resp.AccessToken}); !errors.As(err, &oauthErr) {
+			t.Errorf("expected an access token to be refused as id_token_hint, got %v", err)
+		}
+	})
+}
+
+func TestAuthService_OpenIDConnectNeedsAsymmetricKey(t *testing.T) {
+	// The default HS256 manager signs with a secret clients never see
+	authService := newTestAuthService(t, appservices.AuthDeps{})
+	ctx := context.Background()
+
+	if config := authService.OpenIDConfiguration(); config != nil {
+		t.Errorf("expected no discovery document with HS256, got %+v", config)
+	}
+
+	client := &entities.OAuthClient{
+		Name:         "Example RP",
+		RedirectURIs: []string{"https://rp.example.com/callback"},
+		Scopes:       []string{"openid", "profile"},
+	}
+	if _, err := authService.RegisterOAuthClient(ctx, client); err == nil {
+		t.Error("expected a client asking for openid to be refused")
+	}
+
+	client.Scopes = []string{"profile"}
+	if _, err := authService.RegisterOAuthClient(ctx, client); err != nil {
+		t.Fatalf("RegisterOAuthClient failed: %v", err)
+	}
+	_, err := authService.IssueAuthorizationCode(ctx, &dto.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ID,
+		RedirectURI:         "https://rp.example.com/callback",
+		Scope:               "openid",
+		CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeMethod: "S256",
+	}, &dto.Authentication{UserID: 1, Time: time.Now(), Methods: []string{dto.AMRPassword}})
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_scope" {
+		t.Errorf("expected openid to be an unknown scope, got %v", err)
+	}
+}
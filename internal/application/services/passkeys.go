@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+const mfaMethodWebAuthn = "webauthn"
+
+// COSE algorithms offered for new passkeys: ES256, EdDSA and RS256
+var passkeyAlgorithms = []int64{-7, -8, -257}
+
+// Passkey ceremony types
+const (
+	passkeyRegistration = "registration"
+	passkeyLogin        = "login"
+)
+
+// passkeyCeremony is the server side of a WebAuthn ceremony, stored under
+// its challenge until the authenticator's response comes back
+type passkeyCeremony struct {
+	Type   string `json:"type"`
+	UserID int    `json:"user_id,omitempty"`
+	// MFATokenHash is set when the passkey is the second factor of a login
+	MFATokenHash string `json:"mfa_token_hash,omitempty"`
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create()
+func (s *authServiceImpl) BeginPasskeyRegistration(ctx context.Context, userID int) (*dto.PasskeyCreationOptions, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.webAuthnCredentialRepo.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.startPasskeyCeremony(ctx, &passkeyCeremony{Type: passkeyRegistration, UserID: user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	rpID, rpName := s.webAuthnService.RelyingParty()
+	params := make([]dto.PasskeyCredentialParameter, len(passkeyAlgorithms))
+	for i, alg := range passkeyAlgorithms {
+		params[i] = dto.PasskeyCredentialParameter{Type: "public-key", Alg: alg}
+	}
+	return &dto.PasskeyCreationOptions{
+		Challenge: challenge,
+		RP:        dto.PasskeyRelyingParty{ID: rpID, Name: rpName},
+		User: dto.PasskeyUser{
+			ID:          userHandle(user.ID),
+			Name:        user.Email,
+			DisplayName: user.Username,
+		},
+		PubKeyCredParams: params,
+		Timeout:          s.config.PasskeyTimeout.Milliseconds(),
+		// The same authenticator can't be registered twice
+		ExcludeCredentials: credentialDescriptors(existing),
+		AuthenticatorSelection: dto.PasskeyAuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation and stores the passkey
+func (s *authServiceImpl) FinishPasskeyRegistration(ctx context.Context, userID int, req *dto.PasskeyRegistrationRequest) (*entities.WebAuthnCredential, error) {
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	attestationObject, err := decodeBase64URL(req.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, ceremony, err := s.takePasskeyCeremony(ctx, clientDataJSON, passkeyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID != userID {
+		return nil, fmt.Errorf("passkey challenge is invalid or has expired")
+	}
+
+	credential, err := s.webAuthnService.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("passkey registration failed: %w", err)
+	}
+	if credential.ID != strings.TrimRight(req.ID, "=") {
+		return nil, fmt.Errorf("passkey registration failed: credential ID mismatch")
+	}
+	// Checked last so a failed attestation doesn't use up the code
+	if err := s.authorizeNewPasskey(ctx, userID, req.Code); err != nil {
+		return nil, err
+	}
+	credential.UserID = userID
+	credential.Name = req.Name
+	if credential.Name == "" {
+		credential.Name = "Passkey"
+	}
+	if err := s.webAuthnCredentialRepo.Create(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventPasskeyAdded, fmt.Sprintf("passkey %s registered", credential.ID))
+	return credential, nil
+}
+
+// authorizeNewPasskey makes sure an access token alone can't add a way into
+// the account: it takes the second factor when that is on, otherwise a
+// step-up code sent to the user's email
+func (s *authServiceImpl) authorizeNewPasskey(ctx context.Context, userID int, code string) error {
+	enabled, err := s.totpRepo.IsEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return s.confirmSecondFactor(ctx, userID, code)
+	}
+	return s.verifyOneTimeCode(ctx, stepUpKey(userID, services.StepUpAddPasskey), code)
+}
+
+func (s *authServiceImpl) ListPasskeys(ctx context.Context, userID int) ([]*entities.WebAuthnCredential, error) {
+	return s.webAuthnCredentialRepo.ListByUser(ctx, userID)
+}
+
+func (s *authServiceImpl) DeletePasskey(ctx context.Context, userID int, credentialID string) error {
+	if err := s.webAuthnCredentialRepo.Delete(ctx, userID, credentialID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventPasskeyRemoved, fmt.Sprintf("passkey %s removed", credentialID))
+	return nil
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get().
+// Without an MFA token the passkey alone signs the user in, and the
+// browser offers whichever passkeys it holds for this site. With one, the
+// passkey completes a password login and must belong to that user.
+func (s *authServiceImpl) BeginPasskeyLogin(ctx context.Context, mfaToken string) (*dto.PasskeyRequestOptions, error) {
+	ceremony := &passkeyCeremony{Type: passkeyLogin}
+	userVerification := "required"
+	var allowed []*entities.WebAuthnCredential
+
+	if mfaToken != "" {
+		tokenHash := hashToken(mfaToken)
+		challenge, err := s.oneTimeTokenRepo.Get(ctx, tokenHash, entities.OneTimeTokenMFAChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or expired MFA token")
+		}
+		allowed, err = s.webAuthnCredentialRepo.ListByUser(ctx, challenge.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if len(allowed) == 0 {
+			return nil, fmt.Errorf("no passkeys are registered for this account")
+		}
+		ceremony.UserID = challenge.UserID
+		ceremony.MFATokenHash = tokenHash
+		// The password was the other factor
+		userVerification = "preferred"
+	}
+
+	challenge, err := s.startPasskeyCeremony(ctx, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	rpID, _ := s.webAuthnService.RelyingParty()
+	return &dto.PasskeyRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.config.PasskeyTimeout.Milliseconds(),
+		RPID:             rpID,
+		AllowCredentials: credentialDescriptors(allowed),
+		UserVerification: userVerification,
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the authenticator's assertion and signs the user in
+func (s *authServiceImpl) FinishPasskeyLogin(ctx context.Context, req *dto.PasskeyAssertionRequest) (*dto.AuthResponse, error) {
+	clientDataJSON, err := decodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	authenticatorData, err := decodeBase64URL(req.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := decodeBase64URL(req.Response.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, ceremony, err := s.takePasskeyCeremony(ctx, clientDataJSON, passkeyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthnCredentialRepo.GetByID(ctx, strings.TrimRight(req.ID, "="))
+	if err != nil {
+		return nil, fmt.Errorf("passkey is not registered")
+	}
+	if ceremony.UserID != 0 && credential.UserID != ceremony.UserID {
+		return nil, fmt.Errorf("passkey is not registered")
+	}
+	if req.Response.UserHandle != "" && strings.TrimRight(req.Response.UserHandle, "=") != userHandle(credential.UserID) {
+		return nil, fmt.Errorf("passkey does not belong to this user")
+	}
+
+	assertion, err := s.webAuthnService.VerifyAssertion(challenge, credential, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		return nil, fmt.Errorf("passkey login failed: %w", err)
+	}
+	// On its own a passkey must prove both possession and the user
+	if ceremony.MFATokenHash == "" && !assertion.UserVerified {
+		return nil, fmt.Errorf("passkey login failed: user verification is required")
+	}
+
+	// Authenticators that count signatures must always count up; anything
+	// else means the key was copied
+	updated, err := s.webAuthnCredentialRepo.UpdateSignCount(ctx, credential.ID, assertion.SignCount)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		s.recordSecurityEvent(ctx, credential.UserID, entities.SecurityEventPasskeyCloned,
+			fmt.Sprintf("passkey %s presented sign count %d, expected more than %d", credential.ID, assertion.SignCount, credential.SignCount))
+		return nil, fmt.Errorf("passkey login failed: signature counter did not increase")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, credential.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("passkey is not registered")
+	}
+	if user.DisabledAt != nil {
+		return nil, services.ErrAccountDisabled
+	}
+
+	scope := s.config.Scopes
+	if ceremony.MFATokenHash != "" {
+		challenge, err := s.oneTimeTokenRepo.Consume(ctx, ceremony.MFATokenHash, entities.OneTimeTokenMFAChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or expired MFA token")
+		}
+		// The password login chose the scope
+		if len(challenge.Scope) > 0 {
+			scope = challenge.Scope
+		}
+	} else if s.config.RequireEmailVerification && !user.EmailVerified {
+		return nil, services.ErrEmailNotVerified
+	}
+
+	return s.issueTokens(ctx, user, tokenSession{scope: scope})
+}
+
+// startPasskeyCeremony stores the ceremony and returns its challenge
+func (s *authServiceImpl) startPasskeyCeremony(ctx context.Context, ceremony *passkeyCeremony) (string, error) {
+	challenge, err := generateOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+	state, err := json.Marshal(ceremony)
+	if err != nil {
+		return "", err
+	}
+	if err := s.challengeStore.Put(ctx, "webauthn:"+challenge, string(state), s.config.PasskeyTimeout); err != nil {
+		return "", fmt.Errorf("failed to store passkey challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// takePasskeyCeremony finds the ceremony the client signed and ends it, so
+// every challenge is answered at most once
+func (s *authServiceImpl) takePasskeyCeremony(ctx context.Context, clientDataJSON []byte, ceremonyType string) (string, *passkeyCeremony, error) {
+	challenge, err := s.webAuthnService.Challenge(clientDataJSON)
+	if err != nil {
+		return "", nil, err
+	}
+	state, found, err := s.challengeStore.Take(ctx, "webauthn:"+challenge)
+	if err != nil {
+		return "", nil, err
+	}
+	ceremony := &passkeyCeremony{}
+	if !found || json.Unmarshal([]byte(state), ceremony) != nil || ceremony.Type != ceremonyType {
+		return "", nil, fmt.Errorf("passkey challenge is invalid or has expired")
+	}
+	return challenge, ceremony, nil
+}
+
+// hasPasskeys reports whether the user can use a passkey as a second factor
+func (s *authServiceImpl) hasPasskeys(ctx context.Context, userID int) (bool, error) {
+	credentials, err := s.webAuthnCredentialRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// userHandle is the WebAuthn user ID stored in the user's passkeys
+func userHandle(userID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(userID)))
+}
+
+func credentialDescriptors(credentials []*entities.WebAuthnCredential) []dto.PasskeyCredentialDescriptor {
+	descriptors := make([]dto.PasskeyCredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = dto.PasskeyCredentialDescriptor{Type: "public-key", ID: credential.ID}
+	}
+	return descriptors
+}
+
+// decodeBase64URL accepts base64url with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url value: %w", err)
+	}
+	return decoded, nil
+}
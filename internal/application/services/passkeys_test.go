@@ -0,0 +1,229 @@
+package services_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/totp"
+	"jwt-auth/internal/infrastructure/webauthn/webauthntest"
+)
+
+var b64 = base64.RawURLEncoding
+
+func registrationRequest(attestation *webauthntest.Attestation, code string) *dto.PasskeyRegistrationRequest {
+	req := &dto.PasskeyRegistrationRequest{ID: attestation.CredentialID, Name: "Test key", Code: code}
+	req.Response.ClientDataJSON = b64.EncodeToString(attestation.ClientDataJSON)
+	req.Response.AttestationObject = b64.EncodeToString(attestation.AttestationObject)
+	return req
+}
+
+func assertionRequest(assertion *webauthntest.Assertion) *dto.PasskeyAssertionRequest {
+	req := &dto.PasskeyAssertionRequest{ID: assertion.CredentialID}
+	req.Response.ClientDataJSON = b64.EncodeToString(assertion.ClientDataJSON)
+	req.Response.AuthenticatorData = b64.EncodeToString(assertion.AuthenticatorData)
+	req.Response.Signature = b64.EncodeToString(assertion.Signature)
+	req.Response.UserHandle = b64.EncodeToString(assertion.UserHandle)
+	return req
+}
+
+func TestAuthService_Passkeys(t *testing.T) {
+	userRepo := newMockUserRepository()
+	securityEventRepo := newMockSecurityEventRepository()
+	emailService := newMockEmailService()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		UserRepo:          userRepo,
+		SecurityEventRepo: securityEventRepo,
+		EmailService:      emailService,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "passkeyuser",
+		Email:    "passkey@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+	authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	authenticator.Format = webauthntest.FormatPacked
+
+	// stepUpCode emails the user an add_passkey code and returns it
+	stepUpCode := func(t *testing.T, userID int) string {
+		t.Helper()
+		if err := authService.RequestStepUpCode(ctx, userID, services.StepUpAddPasskey); err != nil {
+			t.Fatalf("RequestStepUpCode failed: %v", err)
+		}
+		codes := emailService.withTemplate(services.EmailTemplateOneTimeCode)
+		if len(codes) == 0 {
+			t.Fatal("expected a one-time code email")
+		}
+		return codes[len(codes)-1].Data["Code"].(string)
+	}
+
+	// register adds a passkey from the authenticator to the user
+	register := func(t *testing.T, authenticator *webauthntest.Authenticator, userID int) string {
+		t.Helper()
+		options, err := authService.BeginPasskeyRegistration(ctx, userID)
+		if err != nil {
+			t.Fatalf("BeginPasskeyRegistration failed: %v", err)
+		}
+		handle, _ := b64.DecodeString(options.User.ID)
+		attestation, err := authenticator.Register(options.Challenge, handle)
+		if err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		credential, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, stepUpCode(t, userID)))
+		if err != nil {
+			t.Fatalf("FinishPasskeyRegistration failed: %v", err)
+		}
+		return credential.ID
+	}
+
+	// login answers a passkey login with the authenticator
+	login := func(authenticator *webauthntest.Authenticator, mfaToken string) (*dto.AuthResponse, error) {
+		options, err := authService.BeginPasskeyLogin(ctx, mfaToken)
+		if err != nil {
+			return nil, err
+		}
+		credentialID := ""
+		if len(options.AllowCredentials) > 0 {
+			credentialID = options.AllowCredentials[0].ID
+		}
+		assertion, err := authenticator.Assert(options.Challenge, credentialID)
+		if err != nil {
+			return nil, err
+		}
+		return authService.FinishPasskeyLogin(ctx, assertionRequest(assertion))
+	}
+
+	credentialID := register(t, authenticator, userID)
+
+	t.Run("Registration challenge is single-use", func(t *testing.T) {
+		options, _ := authService.BeginPasskeyRegistration(ctx, userID)
+		if len(options.ExcludeCredentials) != 1 || options.ExcludeCredentials[0].ID != credentialID {
+			t.Errorf("expected the existing passkey to be excluded, got %+v", options.ExcludeCredentials)
+		}
+		other := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		attestation, _ := other.Register(options.Challenge, []byte("1"))
+		if _, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, stepUpCode(t, userID))); err != nil {
+			t.Fatalf("FinishPasskeyRegistration failed: %v", err)
+		}
+		if _, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, stepUpCode(t, userID))); err == nil {
+			t.Error("expected a replayed registration to be rejected")
+		}
+		passkeys, _ := authService.ListPasskeys(ctx, userID)
+		if len(passkeys) != 2 {
+			t.Fatalf("expected 2 passkeys, got %d", len(passkeys))
+		}
+		if err := authService.DeletePasskey(ctx, userID, attestation.CredentialID); err != nil {
+			t.Errorf("DeletePasskey failed: %v", err)
+		}
+	})
+
+	t.Run("Registration needs a step-up code", func(t *testing.T) {
+		other := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		options, _ := authService.BeginPasskeyRegistration(ctx, userID)
+		attestation, _ := other.Register(options.Challenge, []byte("1"))
+		_, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, "000000"))
+		if !errors.Is(err, services.ErrInvalidMFACode) {
+			t.Errorf("expected a registration without a valid code to be refused, got %v", err)
+		}
+		passkeys, _ := authService.ListPasskeys(ctx, userID)
+		if len(passkeys) != 1 {
+			t.Errorf("expected no new passkey, got %d", len(passkeys))
+		}
+	})
+
+	t.Run("Passwordless login", func(t *testing.T) {
+		resp, err := login(authenticator, "")
+		if err != nil || resp.AccessToken == "" || resp.User.ID != userID {
+			t.Fatalf("expected a passkey login, got %v", err)
+		}
+	})
+
+	t.Run("Passwordless login requires user verification", func(t *testing.T) {
+		authenticator.UserVerified = false
+		defer func() { authenticator.UserVerified = true }()
+		if _, err := login(authenticator, ""); err == nil {
+			t.Error("expected a login without user verification to be rejected")
+		}
+	})
+
+	t.Run("Cloned authenticator", func(t *testing.T) {
+		authenticator.SetSignCount(credentialID, 0)
+		if _, err := login(authenticator, ""); err == nil {
+			t.Fatal("expected a sign count that went backwards to be rejected")
+		}
+		last := securityEventRepo.events[len(securityEventRepo.events)-1]
+		if last.Type != entities.SecurityEventPasskeyCloned {
+			t.Errorf("expected a clone event, got %s", last.Type)
+		}
+		authenticator.SetSignCount(credentialID, 100)
+	})
+
+	t.Run("Second factor", func(t *testing.T) {
+		enrollment, _ := authService.EnrollTOTP(ctx, userID)
+		code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+		recovery, err := authService.ConfirmTOTP(ctx, userID, code)
+		if err != nil {
+			t.Fatalf("ConfirmTOTP failed: %v", err)
+		}
+
+		// With two-factor authentication on, an emailed code isn't enough
+		// to add a passkey
+		other := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		creation, _ := authService.BeginPasskeyRegistration(ctx, userID)
+		attestation, _ := other.Register(creation.Challenge, []byte("1"))
+		if _, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, stepUpCode(t, userID))); err == nil {
+			t.Error("expected a step-up code to be refused once MFA is on")
+		}
+		creation, _ = authService.BeginPasskeyRegistration(ctx, userID)
+		attestation, _ = other.Register(creation.Challenge, []byte("1"))
+		added, err := authService.FinishPasskeyRegistration(ctx, userID, registrationRequest(attestation, recovery.RecoveryCodes[0]))
+		if err != nil {
+			t.Fatalf("expected the second factor to allow a new passkey, got %v", err)
+		}
+		if err := authService.DeletePasskey(ctx, userID, added.ID); err != nil {
+			t.Errorf("DeletePasskey failed: %v", err)
+		}
+
+		_, err = authService.Login(ctx, &dto.LoginRequest{Email: "passkey@example.com", Password: "password123"})
+		var challenge *services.MFAChallengeError
+		if !errors.As(err, &challenge) {
+			t.Fatalf("expected an MFA challenge, got %v", err)
+		}
+		if challenge.Methods[len(challenge.Methods)-1] != "webauthn" {
+			t.Errorf("expected passkeys to be offered, got %v", challenge.Methods)
+		}
+
+		// Someone else's passkey doesn't satisfy the challenge
+		intruder, _ := authService.Register(ctx, &dto.RegisterRequest{Username: "intruder", Email: "intruder@example.com", Password: "password123"})
+		intruderKey := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		intruderCredential := register(t, intruderKey, intruder.User.ID)
+		options, _ := authService.BeginPasskeyLogin(ctx, challenge.Token)
+		assertion, _ := intruderKey.Assert(options.Challenge, intruderCredential)
+		if _, err := authService.FinishPasskeyLogin(ctx, assertionRequest(assertion)); err == nil {
+			t.Error("expected another user's passkey to be rejected")
+		}
+
+		// Without user verification, as the password was the other factor
+		authenticator.UserVerified = false
+		defer func() { authenticator.UserVerified = true }()
+		resp, err := login(authenticator, challenge.Token)
+		if err != nil || resp.User.ID != userID {
+			t.Fatalf("expected the passkey to complete the login, got %v", err)
+		}
+		if _, err := authService.VerifyMFA(ctx, challenge.Token, "123456"); err == nil {
+			t.Error("expected the MFA token to be used up")
+		}
+	})
+}
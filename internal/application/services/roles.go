@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"jwt-auth/internal/domain/entities"
+)
+
+// userAccess returns the names of the user's roles and of every permission
+// they grant, for the access token
+func (s *authServiceImpl) userAccess(ctx context.Context, userID int) ([]string, []string, error) {
+	roles, err := s.roleRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(roles))
+	granted := map[string]bool{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+		for _, permission := range role.Permissions {
+			granted[permission] = true
+		}
+	}
+	permissions := make([]string, 0, len(granted))
+	for permission := range granted {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(names)
+	sort.Strings(permissions)
+	return names, permissions, nil
+}
+
+// stringsClaim reads a list of strings from a decoded token claim
+func stringsClaim(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func (s *authServiceImpl) ListRoles(ctx context.Context) ([]*entities.Role, error) {
+	return s.roleRepo.List(ctx)
+}
+
+func (s *authServiceImpl) ListPermissions(ctx context.Context) ([]*entities.Permission, error) {
+	return s.roleRepo.ListPermissions(ctx)
+}
+
+func (s *authServiceImpl) CreateRole(ctx context.Context, name, description string, permissions []string) (*entities.Role, error) {
+	role := &entities.Role{Name: name, Description: description, Permissions: permissions}
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.roleRepo.Create(ctx, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.roleRepo.GetByName(ctx, name)
+}
+
+// DeleteRole removes the role from everyone who has it. Their sessions end,
+// so tokens carrying the role stop working right away.
+func (s *authServiceImpl) DeleteRole(ctx context.Context, name string) error {
+	members, err := s.roleRepo.ListMembers(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepo.Delete(ctx, name); err != nil {
+		return err
+	}
+	return s.revokeMembersSessions(ctx, members, name)
+}
+
+// GrantPermission adds a permission to a role. Members get it with their
+// next token.
+func (s *authServiceImpl) GrantPermission(ctx context.Context, role, permission string) error {
+	return s.roleRepo.GrantPermission(ctx, role, permission)
+}
+
+// RevokePermission takes a permission from a role and ends the sessions of
+// the role's members, whose tokens still carry it
+func (s *authServiceImpl) RevokePermission(ctx context.Context, role, permission string) error {
+	if err := s.roleRepo.RevokePermission(ctx, role, permission); err != nil {
+		return err
+	}
+	members, err := s.roleRepo.ListMembers(ctx, role)
+	if err != nil {
+		return err
+	}
+	return s.revokeMembersSessions(ctx, members, role)
+}
+
+func (s *authServiceImpl) ListUserRoles(ctx context.Context, userID int) ([]*entities.Role, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.roleRepo.ListByUser(ctx, userID)
+}
+
+// AssignRole gives the user a role. It is in their next token.
+func (s *authServiceImpl) AssignRole(ctx context.Context, userID int, role string) error {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.roleRepo.AssignRole(ctx, userID, role); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventRoleAssigned, fmt.Sprintf("role %s assigned", role))
+	return nil
+}
+
+// RemoveRole takes a role from the user and ends their sessions, whose
+// tokens still carry it
+func (s *authServiceImpl) RemoveRole(ctx context.Context, userID int, role string) error {
+	if err := s.roleRepo.RemoveRole(ctx, userID, role); err != nil {
+		return err
+	}
+	if err := s.revokeAllSessions(ctx, userID); err != nil {
+		return err
+	}
+	s.recordSecurityEvent(ctx, userID, entities.SecurityEventRoleRemoved, fmt.Sprintf("role %s removed", role))
+	return nil
+}
+
+// revokeMembersSessions signs out the users whose role changed
+func (s *authServiceImpl) revokeMembersSessions(ctx context.Context, userIDs []int, role string) error {
+	for _, userID := range userIDs {
+		if err := s.revokeAllSessions(ctx, userID); err != nil {
+			return fmt.Errorf("failed to end sessions after changing role %s: %w", role, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+)
+
+func TestAuthService_Roles(t *testing.T) {
+	roleRepo := newMockRoleRepository()
+	authService := newTestAuthService(t, appservices.AuthDeps{
+		RoleRepo: roleRepo,
+	})
+	ctx := context.Background()
+
+	registered, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "roleuser",
+		Email:    "role@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userID := registered.User.ID
+	login := &dto.LoginRequest{Email: "role@example.com", Password: "password123"}
+
+	// claims signs in and returns the claims of the new access token
+	claims := func(t *testing.T) *dto.UserClaims {
+		t.Helper()
+		resp, err := authService.Login(ctx, login)
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		claims, err := authService.ValidateToken(ctx, resp.AccessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		return claims
+	}
+
+	t.Run("New users get the default role", func(t *testing.T) {
+		got := claims(t)
+		if !slices.Equal(got.Roles, []string{"user"}) {
+			t.Errorf("expected roles [user], got %v", got.Roles)
+		}
+		if !slices.Equal(got.Permissions, []string{"dashboard:view"}) {
+			t.Errorf("expected permissions [dashboard:view], got %v", got.Permissions)
+		}
+	})
+
+	t.Run("Assigned roles are in the next token", func(t *testing.T) {
+		if err := authService.AssignRole(ctx, userID, "admin"); err != nil {
+			t.Fatalf("AssignRole failed: %v", err)
+		}
+		got := claims(t)
+		if !slices.Equal(got.Roles, []string{"admin", "user"}) {
+			t.Errorf("expected roles [admin user], got %v", got.Roles)
+		}
+		if !slices.Equal(got.Permissions, []string{"dashboard:view", "roles:manage", "users:manage"}) {
+			t.Errorf("expected the permissions of both roles once each, got %v", got.Permissions)
+		}
+	})
+
+	t.Run("Unknown roles are refused", func(t *testing.T) {
+		if err := authService.AssignRole(ctx, userID, "superuser"); err == nil {
+			t.Error("expected an unknown role to be refused")
+		}
+	})
+
+	t.Run("Removing a role ends the user's sessions", func(t *testing.T) {
+		resp, err := authService.Login(ctx, login)
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if err := authService.RemoveRole(ctx, userID, "admin"); err != nil {
+			t.Fatalf("RemoveRole failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, resp.AccessToken); err == nil {
+			t.Error("expected a token carrying the removed role to be rejected")
+		}
+		if got := claims(t); slices.Contains(got.Roles, "admin") {
+			t.Errorf("expected the admin role to be gone, got %v", got.Roles)
+		}
+	})
+
+	t.Run("Revoking a permission ends the members' sessions", func(t *testing.T) {
+		resp, err := authService.Login(ctx, login)
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if err := authService.RevokePermission(ctx, "user", "dashboard:view"); err != nil {
+			t.Fatalf("RevokePermission failed: %v", err)
+		}
+		if _, err := authService.ValidateToken(ctx, resp.AccessToken); err == nil {
+			t.Error("expected a token carrying the revoked permission to be rejected")
+		}
+		if got := claims(t); len(got.Permissions) != 0 {
+			t.Errorf("expected no permissions, got %v", got.Permissions)
+		}
+	})
+
+	t.Run("Created roles carry their permissions", func(t *testing.T) {
+		role, err := authService.CreateRole(ctx, "editor", "Edits content", []string{"posts:write"})
+		if err != nil {
+			t.Fatalf("CreateRole failed: %v", err)
+		}
+		if !slices.Equal(role.Permissions, []string{"posts:write"}) {
+			t.Errorf("expected permissions [posts:write], got %v", role.Permissions)
+		}
+		if _, err := authService.CreateRole(ctx, "editor", "", nil); err == nil {
+			t.Error("expected a duplicate role to be refused")
+		}
+	})
+}
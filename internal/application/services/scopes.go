@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"jwt-auth/internal/domain/services"
+)
+
+// grantScope checks a space-delimited scope request against the scopes the
+// client may have. An empty request gets all of them.
+func grantScope(requested string, allowed []string) ([]string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, nil
+	}
+
+	var granted []string
+	for _, scope := range strings.Fields(requested) {
+		if !slices.Contains(allowed, scope) {
+			return nil, fmt.Errorf("%w: %s", services.ErrInvalidScope, scope)
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted, nil
+}
+
+// knownScopes are the scopes a token can carry: those of a full user token
+// and the OpenID Connect ones, which only OAuth clients ask for, when it is
+// enabled
+func (s *authServiceImpl) knownScopes() []string {
+	scopes := slices.Clone(s.config.Scopes)
+	if !s.oidcEnabled() {
+		return scopes
+	}
+	for _, scope := range oidcScopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// tokenScope reads the scope claim of a token. Tokens issued before scopes
+// existed have none and keep full access until they expire. Scopes since
+// dropped from the configuration are not honored.
+func (s *authServiceImpl) tokenScope(claims map[string]interface{}) []string {
+	claim, ok := claims["scope"].(string)
+	if !ok {
+		return s.config.Scopes
+	}
+
+	scope := []string{}
+	known := s.knownScopes()
+	for _, value := range strings.Fields(claim) {
+		if slices.Contains(known, value) {
+			scope = append(scope, value)
+		}
+	}
+	return scope
+}
@@ -0,0 +1,94 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"jwt-auth/internal/application/dto"
+	appservices "jwt-auth/internal/application/services"
+	"jwt-auth/internal/domain/services"
+)
+
+func TestAuthService_Scopes(t *testing.T) {
+	authService := newTestAuthService(t, appservices.AuthDeps{})
+	ctx := context.Background()
+
+	if _, err := authService.Register(ctx, &dto.RegisterRequest{
+		Username: "scopeuser",
+		Email:    "scope@example.com",
+		Password: "password123",
+	}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// login signs in asking for scope and returns the session
+	login := func(t *testing.T, scope string) *dto.AuthResponse {
+		t.Helper()
+		resp, err := authService.Login(ctx, &dto.LoginRequest{Email: "scope@example.com", Password: "password123", Scope: scope})
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		return resp
+	}
+
+	// scopeOf returns the scope an access token carries
+	scopeOf := func(t *testing.T, accessToken string) []string {
+		t.Helper()
+		claims, err := authService.ValidateToken(ctx, accessToken)
+		if err != nil {
+			t.Fatalf("ValidateToken failed: %v", err)
+		}
+		return claims.Scope
+	}
+
+	t.Run("Full scope by default", func(t *testing.T) {
+		resp := login(t, "")
+		if resp.Scope != "profile account admin" {
+			t.Errorf("expected the full scope, got %q", resp.Scope)
+		}
+		if got := scopeOf(t, resp.AccessToken); !slices.Equal(got, []string{"profile", "account", "admin"}) {
+			t.Errorf("expected the token to carry the full scope, got %v", got)
+		}
+	})
+
+	t.Run("Login can ask for a subset", func(t *testing.T) {
+		resp := login(t, "profile")
+		if got := scopeOf(t, resp.AccessToken); !slices.Equal(got, []string{"profile"}) {
+			t.Errorf("expected scope [profile], got %v", got)
+		}
+	})
+
+	t.Run("Unknown scopes are refused", func(t *testing.T) {
+		_, err := authService.Login(ctx, &dto.LoginRequest{Email: "scope@example.com", Password: "password123", Scope: "profile payments"})
+		if !errors.Is(err, services.ErrInvalidScope) {
+			t.Errorf("expected ErrInvalidScope, got %v", err)
+		}
+	})
+
+	t.Run("Refresh keeps or narrows the scope", func(t *testing.T) {
+		resp := login(t, "profile account")
+
+		kept, err := authService.RefreshToken(ctx, resp.RefreshToken, "")
+		if err != nil {
+			t.Fatalf("RefreshToken failed: %v", err)
+		}
+		if kept.Scope != "profile account" {
+			t.Errorf("expected an empty request to keep the scope, got %q", kept.Scope)
+		}
+
+		narrowed, err := authService.RefreshToken(ctx, kept.RefreshToken, "account")
+		if err != nil {
+			t.Fatalf("RefreshToken failed: %v", err)
+		}
+		if got := scopeOf(t, narrowed.AccessToken); !slices.Equal(got, []string{"account"}) {
+			t.Errorf("expected scope [account], got %v", got)
+		}
+
+		// The narrowed session can't get its scope back
+		if _, err := authService.RefreshToken(ctx, narrowed.RefreshToken, "account profile"); !errors.Is(err, services.ErrInvalidScope) {
+			t.Errorf("expected widening the scope to be refused, got %v", err)
+		}
+	})
+}
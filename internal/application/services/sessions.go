@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// revokeFamily ends a session: its refresh tokens can no longer be redeemed
+// and access tokens already issued to it are rejected until they expire.
+func (s *authServiceImpl) revokeFamily(ctx context.Context, familyID string) error {
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	if s.tokenBlacklist != nil {
+		ttl := int64(s.jwtManager.AccessTokenExpiry().Seconds())
+		if err := s.tokenBlacklist.RevokeFamily(ctx, familyID, ttl); err != nil {
+			return fmt.Errorf("failed to revoke access tokens: %w", err)
+		}
+	}
+	return nil
+}
+
+// revokeAllSessions ends every session of the user
+func (s *authServiceImpl) revokeAllSessions(ctx context.Context, userID int) error {
+	families, err := s.refreshTokenRepo.RevokeByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, familyID := range families {
+		if err := s.revokeFamily(ctx, familyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// securityAlerts are the security events users are told about by email
+var securityAlerts = map[string]string{
+	entities.SecurityEventRefreshTokenReuse:        "A sign-in token for your account was used twice, which can mean it was stolen. The affected session has been signed out as a precaution.",
+	entities.SecurityEventPasswordReset:            "Your password was reset and every device signed in to your account has been signed out.",
+	entities.SecurityEventPasswordSet:              "An administrator changed your password and every device signed in to your account has been signed out.",
+	entities.SecurityEventPasswordChanged:          "Your password was changed and every device signed in to your account has been signed out.",
+	entities.SecurityEventEmailChanged:             "The email address of your account was changed. This address will no longer receive messages about it.",
+	entities.SecurityEventAccountUnlocked:          "Your account was unlocked after being locked because of failed sign-in attempts.",
+	entities.SecurityEventMFAEnabled:               "Two-factor authentication was turned on for your account.",
+	entities.SecurityEventMFADisabled:              "Two-factor authentication was turned off for your account.",
+	entities.SecurityEventRecoveryCodeUsed:         "One of your recovery codes was used to sign in to your account.",
+	entities.SecurityEventPasskeyAdded:             "A passkey was added to your account.",
+	entities.SecurityEventPasskeyRemoved:           "A passkey was removed from your account.",
+	entities.SecurityEventRoleAssigned:             "A new role was assigned to your account.",
+	entities.SecurityEventPasskeyCloned:            "One of your passkeys was used in a way that suggests it was copied. The sign-in was blocked; consider removing that passkey.",
+	entities.SecurityEventRecoveryCodesRegenerated: "New recovery codes were generated for your account. The previous codes no longer work.",
+}
+
+// recordSecurityEvent stores an audit event and alerts the user when the
+// event warrants it. Failing to record must not hide the outcome of the
+// operation that triggered it, so errors are logged.
+func (s *authServiceImpl) recordSecurityEvent(ctx context.Context, userID int, eventType, details string) {
+	event := &entities.SecurityEvent{
+		UserID:  userID,
+		Type:    eventType,
+		Details: details,
+	}
+	if err := s.securityEventRepo.Create(ctx, event); err != nil {
+		log.Printf("Failed to record security event %s for user %d: %v", eventType, userID, err)
+	}
+
+	message, ok := securityAlerts[eventType]
+	if !ok {
+		return
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to send security alert %s to user %d: %v", eventType, userID, err)
+		return
+	}
+	if err := s.emailService.SendTemplate(ctx, user.Email, services.EmailTemplateSecurityAlert, map[string]interface{}{
+		"Username": user.Username,
+		"Message":  message,
+		"Time":     time.Now().UTC().Format(time.RFC1123),
+	}); err != nil {
+		log.Printf("Failed to send security alert %s to user %d: %v", eventType, userID, err)
+	}
+}
@@ -2,12 +2,182 @@ package services_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
 
+	appservices "jwt-auth/internal/application/services"
 	"jwt-auth/internal/domain/entities"
 	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/jwt"
+	"jwt-auth/internal/infrastructure/totp"
+	"jwt-auth/internal/infrastructure/webauthn"
 )
 
+// newTestAuthService builds the service on deps, with a fresh mock or test
+// implementation for every dependency a test leaves unset
+func newTestAuthService(t *testing.T, deps appservices.AuthDeps) services.AuthService {
+	t.Helper()
+	if deps.UserRepo == nil {
+		deps.UserRepo = newMockUserRepository()
+	}
+	if deps.RefreshTokenRepo == nil {
+		deps.RefreshTokenRepo = newMockRefreshTokenRepository()
+	}
+	if deps.SecurityEventRepo == nil {
+		deps.SecurityEventRepo = newMockSecurityEventRepository()
+	}
+	if deps.OneTimeTokenRepo == nil {
+		deps.OneTimeTokenRepo = newMockOneTimeTokenRepository()
+	}
+	if deps.TOTPRepo == nil {
+		deps.TOTPRepo = newMockTOTPRepository()
+	}
+	if deps.RecoveryCodeRepo == nil {
+		deps.RecoveryCodeRepo = newMockRecoveryCodeRepository()
+	}
+	if deps.WebAuthnCredentialRepo == nil {
+		deps.WebAuthnCredentialRepo = newMockWebAuthnCredentialRepository()
+	}
+	if deps.RoleRepo == nil {
+		deps.RoleRepo = newMockRoleRepository()
+	}
+	if deps.OAuthClientRepo == nil {
+		deps.OAuthClientRepo = newMockOAuthClientRepository()
+	}
+	if deps.TxManager == nil {
+		deps.TxManager = newMockTransactionManager()
+	}
+	if deps.JWTManager == nil {
+		deps.JWTManager = newTestJWTManager()
+	}
+	if deps.TOTPService == nil {
+		deps.TOTPService = newTestTOTPService()
+	}
+	if deps.WebAuthnService == nil {
+		deps.WebAuthnService = newTestWebAuthnService()
+	}
+	if deps.EmailService == nil {
+		deps.EmailService = newMockEmailService()
+	}
+	if deps.TokenBlacklist == nil {
+		deps.TokenBlacklist = newMockTokenBlacklist()
+	}
+	if deps.CooldownService == nil {
+		deps.CooldownService = newMockCooldownService()
+	}
+	if deps.ChallengeStore == nil {
+		deps.ChallengeStore = newMockChallengeStore()
+	}
+	if deps.OneTimeCodeStore == nil {
+		deps.OneTimeCodeStore = newMockOneTimeCodeStore()
+	}
+	if deps.DeviceGrantStore == nil {
+		deps.DeviceGrantStore = newMockDeviceGrantStore()
+	}
+	if deps.Config == nil {
+		deps.Config = newTestAuthConfig()
+	}
+	return appservices.NewAuthService(deps)
+}
+
+func newTestAuthConfig() *appservices.AuthConfig {
+	return &appservices.AuthConfig{
+		PasswordResetURL:          "http://localhost:3000/reset-password",
+		PasswordResetExpiry:       30 * time.Minute,
+		EmailVerificationURL:      "http://localhost:8080/api/v1/auth/verify-email",
+		EmailVerificationSecret:   "test-verification-secret",
+		EmailVerificationExpiry:   24 * time.Hour,
+		EmailVerificationCooldown: time.Minute,
+		MFAChallengeExpiry:        5 * time.Minute,
+		PasskeyTimeout:            5 * time.Minute,
+		MagicLinkURL:              "http://localhost:3000/magic-link",
+		MagicLinkExpiry:           15 * time.Minute,
+		EmailOTPExpiry:            10 * time.Minute,
+		LockoutThreshold:          5,
+		LockoutBaseDuration:       time.Minute,
+		LockoutMaxDuration:        time.Hour,
+		AccountUnlockURL:          "http://localhost:8080/api/v1/auth/unlock",
+		AccountUnlockExpiry:       24 * time.Hour,
+		DefaultRole:               "user",
+		Scopes:                    []string{"profile", "account", "admin"},
+		OAuthCodeExpiry:           time.Minute,
+		DeviceCodeExpiry:          10 * time.Minute,
+		DevicePollInterval:        5 * time.Second,
+		Issuer:                    "https://auth.example.com",
+	}
+}
+
+func newTestTOTPService() services.TOTPService {
+	return totp.NewTOTPService("JWT Auth")
+}
+
+// Relying party the software authenticator in tests talks to
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+func newTestWebAuthnService() services.WebAuthnService {
+	webAuthnService, err := webauthn.NewWebAuthnService(&webauthn.Config{
+		RPID:    testRPID,
+		RPName:  "JWT Auth",
+		Origins: []string{testOrigin},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return webAuthnService
+}
+
+func newTestJWTManager() services.JWTManager {
+	jwtManager, err := jwt.NewJWTManager(&jwt.JWTConfig{
+		SecretKey:          "test-secret-key",
+		AccessTokenExpiry:  time.Hour,
+		RefreshTokenExpiry: 24 * time.Hour,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return jwtManager
+}
+
+// newTestOIDCJWTManager signs with an ES256 key, which OpenID Connect needs
+func newTestOIDCJWTManager(t *testing.T) services.JWTManager {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	jwtManager, err := jwt.NewJWTManager(&jwt.JWTConfig{
+		Algorithm:          jwt.AlgorithmES256,
+		PrivateKeyPath:     path,
+		AccessTokenExpiry:  time.Hour,
+		RefreshTokenExpiry: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create JWT manager: %v", err)
+	}
+	return jwtManager
+}
+
 // Mock user repository
 type mockUserRepository struct {
 	users map[string]*entities.User
@@ -42,8 +212,10 @@ func (r *mockUserRepository) GetByID(ctx context.Context, id int) (*entities.Use
 }
 
 func (r *mockUserRepository) Update(ctx context.Context, user *entities.User) error {
-	if existing, ok := r.users[user.Email]; ok {
+	for email, existing := range r.users {
 		if existing.ID == user.ID {
+			// The email may have changed, so re-key the user
+			delete(r.users, email)
 			r.users[user.Email] = user
 			return nil
 		}
@@ -51,6 +223,34 @@ func (r *mockUserRepository) Update(ctx context.Context, user *entities.User) er
 	return fmt.Errorf("user not found")
 }
 
+func (r *mockUserRepository) RecordFailedLogin(ctx context.Context, id int) (int, error) {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	user.FailedLogins++
+	return user.FailedLogins, nil
+}
+
+func (r *mockUserRepository) LockUntil(ctx context.Context, id int, until time.Time) error {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	user.LockedUntil = &until
+	return nil
+}
+
+func (r *mockUserRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	user.FailedLogins = 0
+	user.LockedUntil = nil
+	return nil
+}
+
 func (r *mockUserRepository) Delete(ctx context.Context, id int) error {
 	for email, user := range r.users {
 		if user.ID == id {
@@ -62,7 +262,19 @@ func (r *mockUserRepository) Delete(ctx context.Context, id int) error {
 }
 
 // Mock email service
-type mockEmailService struct{}
+type mockEmailService struct {
+	sent []sentEmail
+	// err makes every send fail, e.g. when the outbox can't be written
+	err error
+}
+
+type sentEmail struct {
+	To       string
+	Subject  string
+	Body     string
+	Template string
+	Data     map[string]interface{}
+}
 
 func newMockEmailService() *mockEmailService {
 	return &mockEmailService{}
@@ -70,6 +282,727 @@ func newMockEmailService() *mockEmailService {
 
 // Implement the EmailService interface
 func (m *mockEmailService) SendEmail(ctx context.Context, to, subject, body string) error {
-	// No-op/mock
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentEmail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func (m *mockEmailService) SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentEmail{To: to, Template: template, Data: data})
+	return nil
+}
+
+// withTemplate returns the emails sent with the given template
+func (m *mockEmailService) withTemplate(template string) []sentEmail {
+	var emails []sentEmail
+	for _, email := range m.sent {
+		if email.Template == template {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// Mock refresh token repository
+type mockRefreshTokenRepository struct {
+	tokens map[string]*entities.RefreshToken
+}
+
+func newMockRefreshTokenRepository() *mockRefreshTokenRepository {
+	return &mockRefreshTokenRepository{
+		tokens: make(map[string]*entities.RefreshToken),
+	}
+}
+
+func (r *mockRefreshTokenRepository) Create(ctx context.Context, token *entities.RefreshToken) error {
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *mockRefreshTokenRepository) GetByID(ctx context.Context, id string) (*entities.RefreshToken, error) {
+	if token, ok := r.tokens[id]; ok {
+		copied := *token
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (r *mockRefreshTokenRepository) MarkRedeemed(ctx context.Context, id string) (bool, error) {
+	token, ok := r.tokens[id]
+	if !ok || token.RedeemedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.RedeemedAt = &now
+	return true, nil
+}
+
+func (r *mockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *mockRefreshTokenRepository) RevokeByUser(ctx context.Context, userID int) ([]string, error) {
+	now := time.Now()
+	seen := make(map[string]bool)
+	var families []string
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			if !seen[token.FamilyID] {
+				seen[token.FamilyID] = true
+				families = append(families, token.FamilyID)
+			}
+		}
+	}
+	return families, nil
+}
+
+// Mock one-time token repository
+type mockOneTimeTokenRepository struct {
+	tokens map[string]*entities.OneTimeToken
+}
+
+func newMockOneTimeTokenRepository() *mockOneTimeTokenRepository {
+	return &mockOneTimeTokenRepository{
+		tokens: make(map[string]*entities.OneTimeToken),
+	}
+}
+
+func (r *mockOneTimeTokenRepository) Create(ctx context.Context, token *entities.OneTimeToken) error {
+	token.CreatedAt = time.Now()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *mockOneTimeTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, fmt.Errorf("token is invalid or has expired")
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return token, nil
+}
+
+func (r *mockOneTimeTokenRepository) Get(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, fmt.Errorf("token is invalid or has expired")
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *mockOneTimeTokenRepository) RecordFailedAttempt(ctx context.Context, tokenHash string, maxAttempts int) error {
+	if token, ok := r.tokens[tokenHash]; ok && token.UsedAt == nil {
+		token.Attempts++
+		if token.Attempts >= maxAttempts {
+			now := time.Now()
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *mockOneTimeTokenRepository) DeleteByUser(ctx context.Context, userID int, purpose string) error {
+	for hash, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// Mock TOTP repository
+type mockTOTPRepository struct {
+	credentials map[int]*entities.TOTPCredential
+}
+
+func newMockTOTPRepository() *mockTOTPRepository {
+	return &mockTOTPRepository{
+		credentials: make(map[int]*entities.TOTPCredential),
+	}
+}
+
+func (r *mockTOTPRepository) Save(ctx context.Context, credential *entities.TOTPCredential) error {
+	credential.CreatedAt = time.Now()
+	credential.ConfirmedAt = nil
+	credential.LastUsedStep = 0
+	copied := *credential
+	r.credentials[credential.UserID] = &copied
+	return nil
+}
+
+func (r *mockTOTPRepository) GetByUserID(ctx context.Context, userID int) (*entities.TOTPCredential, error) {
+	if credential, ok := r.credentials[userID]; ok {
+		copied := *credential
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("TOTP credential not found")
+}
+
+func (r *mockTOTPRepository) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	credential, ok := r.credentials[userID]
+	return ok && credential.ConfirmedAt != nil, nil
+}
+
+func (r *mockTOTPRepository) Confirm(ctx context.Context, userID int) error {
+	credential, ok := r.credentials[userID]
+	if !ok {
+		return fmt.Errorf("TOTP credential not found")
+	}
+	now := time.Now()
+	credential.ConfirmedAt = &now
+	return nil
+}
+
+func (r *mockTOTPRepository) MarkUsed(ctx context.Context, userID int, step int64) (bool, error) {
+	credential, ok := r.credentials[userID]
+	if !ok || credential.LastUsedStep >= step {
+		return false, nil
+	}
+	credential.LastUsedStep = step
+	return true, nil
+}
+
+func (r *mockTOTPRepository) Delete(ctx context.Context, userID int) error {
+	delete(r.credentials, userID)
+	return nil
+}
+
+// Mock recovery code repository
+type mockRecoveryCodeRepository struct {
+	// codes maps user id to code hash to whether it has been used
+	codes map[int]map[string]bool
+}
+
+func newMockRecoveryCodeRepository() *mockRecoveryCodeRepository {
+	return &mockRecoveryCodeRepository{
+		codes: make(map[int]map[string]bool),
+	}
+}
+
+func (r *mockRecoveryCodeRepository) Replace(ctx context.Context, userID int, codeHashes []string) error {
+	r.codes[userID] = make(map[string]bool)
+	for _, hash := range codeHashes {
+		r.codes[userID][hash] = false
+	}
+	return nil
+}
+
+func (r *mockRecoveryCodeRepository) Consume(ctx context.Context, userID int, codeHash string) (bool, error) {
+	used, ok := r.codes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	r.codes[userID][codeHash] = true
+	return true, nil
+}
+
+func (r *mockRecoveryCodeRepository) CountUnused(ctx context.Context, userID int) (int, error) {
+	count := 0
+	for _, used := range r.codes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *mockRecoveryCodeRepository) DeleteByUser(ctx context.Context, userID int) error {
+	delete(r.codes, userID)
+	return nil
+}
+
+// Mock WebAuthn credential repository
+type mockWebAuthnCredentialRepository struct {
+	credentials map[string]*entities.WebAuthnCredential
+}
+
+func newMockWebAuthnCredentialRepository() *mockWebAuthnCredentialRepository {
+	return &mockWebAuthnCredentialRepository{
+		credentials: make(map[string]*entities.WebAuthnCredential),
+	}
+}
+
+func (r *mockWebAuthnCredentialRepository) Create(ctx context.Context, credential *entities.WebAuthnCredential) error {
+	if _, ok := r.credentials[credential.ID]; ok {
+		return fmt.Errorf("passkey is already registered")
+	}
+	credential.CreatedAt = time.Now()
+	copied := *credential
+	r.credentials[credential.ID] = &copied
+	return nil
+}
+
+func (r *mockWebAuthnCredentialRepository) GetByID(ctx context.Context, id string) (*entities.WebAuthnCredential, error) {
+	if credential, ok := r.credentials[id]; ok {
+		copied := *credential
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("passkey not found")
+}
+
+func (r *mockWebAuthnCredentialRepository) ListByUser(ctx context.Context, userID int) ([]*entities.WebAuthnCredential, error) {
+	credentials := []*entities.WebAuthnCredential{}
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			copied := *credential
+			credentials = append(credentials, &copied)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *mockWebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id string, signCount uint32) (bool, error) {
+	credential, ok := r.credentials[id]
+	if !ok || (credential.SignCount >= signCount && (signCount != 0 || credential.SignCount != 0)) {
+		return false, nil
+	}
+	now := time.Now()
+	credential.SignCount = signCount
+	credential.LastUsedAt = &now
+	return true, nil
+}
+
+func (r *mockWebAuthnCredentialRepository) Delete(ctx context.Context, userID int, id string) error {
+	credential, ok := r.credentials[id]
+	if !ok || credential.UserID != userID {
+		return fmt.Errorf("passkey not found")
+	}
+	delete(r.credentials, id)
+	return nil
+}
+
+// Mock role repository, seeded with the roles of the migration
+type mockRoleRepository struct {
+	roles map[string]*entities.Role
+	users map[int]map[string]bool
+}
+
+func newMockRoleRepository() *mockRoleRepository {
+	return &mockRoleRepository{
+		roles: map[string]*entities.Role{
+			"admin": {ID: 1, Name: "admin", Permissions: []string{"dashboard:view", "roles:manage", "users:manage"}},
+			"user":  {ID: 2, Name: "user", Permissions: []string{"dashboard:view"}},
+		},
+		users: make(map[int]map[string]bool),
+	}
+}
+
+func (r *mockRoleRepository) Create(ctx context.Context, role *entities.Role) error {
+	if _, ok := r.roles[role.Name]; ok {
+		return fmt.Errorf("role %s already exists", role.Name)
+	}
+	role.ID = len(r.roles) + 1
+	role.CreatedAt = time.Now()
+	copied := *role
+	copied.Permissions = append([]string{}, role.Permissions...)
+	r.roles[role.Name] = &copied
+	return nil
+}
+
+func (r *mockRoleRepository) GetByName(ctx context.Context, name string) (*entities.Role, error) {
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, fmt.Errorf("role %s not found", name)
+	}
+	copied := *role
+	copied.Permissions = append([]string{}, role.Permissions...)
+	return &copied, nil
+}
+
+func (r *mockRoleRepository) List(ctx context.Context) ([]*entities.Role, error) {
+	roles := []*entities.Role{}
+	for name := range r.roles {
+		role, _ := r.GetByName(ctx, name)
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *mockRoleRepository) Delete(ctx context.Context, name string) error {
+	if _, ok := r.roles[name]; !ok {
+		return fmt.Errorf("role %s not found", name)
+	}
+	delete(r.roles, name)
+	for _, roles := range r.users {
+		delete(roles, name)
+	}
+	return nil
+}
+
+func (r *mockRoleRepository) ListPermissions(ctx context.Context) ([]*entities.Permission, error) {
+	seen := map[string]bool{}
+	permissions := []*entities.Permission{}
+	for _, role := range r.roles {
+		for _, name := range role.Permissions {
+			if !seen[name] {
+				seen[name] = true
+				permissions = append(permissions, &entities.Permission{ID: len(permissions) + 1, Name: name})
+			}
+		}
+	}
+	return permissions, nil
+}
+
+func (r *mockRoleRepository) GrantPermission(ctx context.Context, role, permission string) error {
+	existing, ok := r.roles[role]
+	if !ok {
+		return fmt.Errorf("role %s not found", role)
+	}
+	for _, name := range existing.Permissions {
+		if name == permission {
+			return nil
+		}
+	}
+	existing.Permissions = append(existing.Permissions, permission)
+	return nil
+}
+
+func (r *mockRoleRepository) RevokePermission(ctx context.Context, role, permission string) error {
+	if existing, ok := r.roles[role]; ok {
+		for i, name := range existing.Permissions {
+			if name == permission {
+				existing.Permissions = append(existing.Permissions[:i], existing.Permissions[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("role %s does not have permission %s", role, permission)
+}
+
+func (r *mockRoleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	if _, ok := r.roles[role]; !ok {
+		return fmt.Errorf("role %s not found", role)
+	}
+	if r.users[userID] == nil {
+		r.users[userID] = make(map[string]bool)
+	}
+	r.users[userID][role] = true
+	return nil
+}
+
+func (r *mockRoleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	if !r.users[userID][role] {
+		return fmt.Errorf("user does not have role %s", role)
+	}
+	delete(r.users[userID], role)
+	return nil
+}
+
+func (r *mockRoleRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Role, error) {
+	roles := []*entities.Role{}
+	for name := range r.users[userID] {
+		role, _ := r.GetByName(ctx, name)
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *mockRoleRepository) ListMembers(ctx context.Context, role string) ([]int, error) {
+	var userIDs []int
+	for userID, roles := range r.users {
+		if roles[role] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+// Mock OAuth client repository
+type mockOAuthClientRepository struct {
+	clients map[string]*entities.OAuthClient
+}
+
+func newMockOAuthClientRepository() *mockOAuthClientRepository {
+	return &mockOAuthClientRepository{
+		clients: make(map[string]*entities.OAuthClient),
+	}
+}
+
+func (r *mockOAuthClientRepository) Create(ctx context.Context, client *entities.OAuthClient) error {
+	if _, ok := r.clients[client.ID]; ok {
+		return fmt.Errorf("oauth client %s already exists", client.ID)
+	}
+	client.CreatedAt = time.Now()
+	copied := *client
+	r.clients[client.ID] = &copied
+	return nil
+}
+
+func (r *mockOAuthClientRepository) GetByID(ctx context.Context, id string) (*entities.OAuthClient, error) {
+	if client, ok := r.clients[id]; ok {
+		copied := *client
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("oauth client not found")
+}
+
+func (r *mockOAuthClientRepository) List(ctx context.Context) ([]*entities.OAuthClient, error) {
+	clients := []*entities.OAuthClient{}
+	for _, client := range r.clients {
+		copied := *client
+		clients = append(clients, &copied)
+	}
+	return clients, nil
+}
+
+func (r *mockOAuthClientRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := r.clients[id]; !ok {
+		return fmt.Errorf("oauth client not found")
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+// Mock challenge store
+type mockChallengeStore struct {
+	values map[string]string
+}
+
+func newMockChallengeStore() *mockChallengeStore {
+	return &mockChallengeStore{
+		values: make(map[string]string),
+	}
+}
+
+func (m *mockChallengeStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.values[key] = value
+	return nil
+}
+
+func (m *mockChallengeStore) Take(ctx context.Context, key string) (string, bool, error) {
+	value, ok := m.values[key]
+	delete(m.values, key)
+	return value, ok, nil
+}
+
+// Mock one-time code store. Like the Redis store it locks a key after
+// maxOneTimeCodeAttempts wrong codes; locks never expire.
+const maxOneTimeCodeAttempts = 5
+
+type mockOneTimeCodeStore struct {
+	codes    map[string]string
+	attempts map[string]int
+	// tries counts Attempt calls; a key is locked past the limit
+	tries map[string]int
+}
+
+func newMockOneTimeCodeStore() *mockOneTimeCodeStore {
+	return &mockOneTimeCodeStore{
+		codes:    make(map[string]string),
+		attempts: make(map[string]int),
+		tries:    make(map[string]int),
+	}
+}
+
+func (m *mockOneTimeCodeStore) Issue(ctx context.Context, key, codeHash string, ttl time.Duration) error {
+	if m.attempts[key] >= maxOneTimeCodeAttempts {
+		return services.ErrTooManyAttempts
+	}
+	m.codes[key] = codeHash
+	return nil
+}
+
+func (m *mockOneTimeCodeStore) Verify(ctx context.Context, key, codeHash string) (bool, error) {
+	if m.attempts[key] >= maxOneTimeCodeAttempts {
+		return false, services.ErrTooManyAttempts
+	}
+	if code, ok := m.codes[key]; ok && code == codeHash {
+		delete(m.codes, key)
+		delete(m.attempts, key)
+		return true, nil
+	}
+	m.attempts[key]++
+	if m.attempts[key] >= maxOneTimeCodeAttempts {
+		delete(m.codes, key)
+	}
+	return false, nil
+}
+
+func (m *mockOneTimeCodeStore) Attempt(ctx context.Context, key string) error {
+	m.tries[key]++
+	if m.tries[key] > maxOneTimeCodeAttempts {
+		return services.ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (m *mockOneTimeCodeStore) Reset(ctx context.Context, key string) error {
+	delete(m.tries, key)
+	return nil
+}
+
+// Mock device grant store. Tests can move lastPoll back instead of waiting
+// out the polling interval.
+type mockDeviceGrant struct {
+	state    string
+	clientID string
+	grant    string
+	interval time.Duration
+	lastPoll time.Time
+}
+
+type mockDeviceGrantStore struct {
+	grants    map[string]*mockDeviceGrant
+	userCodes map[string]string
+}
+
+func newMockDeviceGrantStore() *mockDeviceGrantStore {
+	return &mockDeviceGrantStore{
+		grants:    make(map[string]*mockDeviceGrant),
+		userCodes: make(map[string]string),
+	}
+}
+
+func (m *mockDeviceGrantStore) Create(ctx context.Context, deviceKey, userKey, clientID, grant string, interval, ttl time.Duration) (bool, error) {
+	if _, ok := m.userCodes[userKey]; ok {
+		return false, nil
+	}
+	m.userCodes[userKey] = deviceKey
+	m.grants[deviceKey] = &mockDeviceGrant{state: services.DeviceGrantPending, clientID: clientID, grant: grant, interval: interval}
+	return true, nil
+}
+
+func (m *mockDeviceGrantStore) Pending(ctx context.Context, userKey string) (string, bool, error) {
+	grant, ok := m.grants[m.userCodes[userKey]]
+	if !ok || grant.state != services.DeviceGrantPending {
+		return "", false, nil
+	}
+	return grant.grant, true, nil
+}
+
+func (m *mockDeviceGrantStore) Decide(ctx context.Context, userKey string, approved bool, value string) (bool, error) {
+	deviceKey, ok := m.userCodes[userKey]
+	delete(m.userCodes, userKey)
+	grant, found := m.grants[deviceKey]
+	if !ok || !found || grant.state != services.DeviceGrantPending {
+		return false, nil
+	}
+	grant.state = services.DeviceGrantDenied
+	if approved {
+		grant.state = services.DeviceGrantApproved
+	}
+	if value != "" {
+		grant.grant = value
+	}
+	return true, nil
+}
+
+func (m *mockDeviceGrantStore) Poll(ctx context.Context, deviceKey, clientID string) (string, string, error) {
+	grant, ok := m.grants[deviceKey]
+	if !ok {
+		return services.DeviceGrantExpired, "", nil
+	}
+	if grant.clientID != clientID {
+		return services.DeviceGrantOtherClient, "", nil
+	}
+	lastPoll := grant.lastPoll
+	grant.lastPoll = time.Now()
+	if time.Since(lastPoll) < grant.interval {
+		grant.interval += 5 * time.Second
+		return services.DeviceGrantSlowDown, grant.grant, nil
+	}
+	if grant.state != services.DeviceGrantPending {
+		delete(m.grants, deviceKey)
+	}
+	return grant.state, grant.grant, nil
+}
+
+// Mock security event repository
+type mockSecurityEventRepository struct {
+	events []*entities.SecurityEvent
+}
+
+func newMockSecurityEventRepository() *mockSecurityEventRepository {
+	return &mockSecurityEventRepository{}
+}
+
+func (r *mockSecurityEventRepository) Create(ctx context.Context, event *entities.SecurityEvent) error {
+	event.ID = len(r.events) + 1
+	event.CreatedAt = time.Now()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Mock token blacklist
+type mockTokenBlacklist struct {
+	tokens   map[string]bool
+	families map[string]bool
+}
+
+func newMockTokenBlacklist() *mockTokenBlacklist {
+	return &mockTokenBlacklist{
+		tokens:   make(map[string]bool),
+		families: make(map[string]bool),
+	}
+}
+
+func (b *mockTokenBlacklist) BlacklistToken(ctx context.Context, token string, expiration int64) error {
+	b.tokens[token] = true
+	return nil
+}
+
+func (b *mockTokenBlacklist) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	return b.tokens[token], nil
+}
+
+func (b *mockTokenBlacklist) RevokeFamily(ctx context.Context, familyID string, expiration int64) error {
+	b.families[familyID] = true
+	return nil
+}
+
+func (b *mockTokenBlacklist) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	return b.families[familyID], nil
+}
+
+// Mock cooldown service
+type mockCooldownService struct {
+	active map[string]bool
+}
+
+func newMockCooldownService() *mockCooldownService {
+	return &mockCooldownService{
+		active: make(map[string]bool),
+	}
+}
+
+func (m *mockCooldownService) StartCooldown(ctx context.Context, key string, duration time.Duration) (bool, error) {
+	if m.active[key] {
+		return false, nil
+	}
+	m.active[key] = true
+	return true, nil
+}
+
+// Mock transaction manager
+type mockTransactionManager struct {
+	committed  int
+	rolledBack int
+}
+
+func newMockTransactionManager() *mockTransactionManager {
+	return &mockTransactionManager{}
+}
+
+func (m *mockTransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		m.rolledBack++
+		return err
+	}
+	m.committed++
 	return nil
 }
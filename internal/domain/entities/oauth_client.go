@@ -0,0 +1,34 @@
+package entities
+
+import (
+	"time"
+)
+
+// How clients authenticate at the token endpoint (RFC 7591). Clients other
+// than ClientAuthNone are confidential.
+const (
+	ClientAuthNone          = "none"
+	ClientAuthSecretBasic   = "client_secret_basic"
+	ClientAuthSecretPost    = "client_secret_post"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+)
+
+// OAuthClient is an application registered to sign users in through the
+// authorization endpoint, or a service getting tokens of its own
+type OAuthClient struct {
+	ID   string `json:"client_id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// AuthMethod is one of the ClientAuth methods
+	AuthMethod string `json:"token_endpoint_auth_method" db:"token_endpoint_auth_method"`
+	// SecretHash is the SHA-256 hash of the client secret
+	SecretHash string `json:"-" db:"secret_hash"`
+	// PublicKey is the PEM public key that verifies private_key_jwt assertions
+	PublicKey string `json:"public_key,omitempty" db:"public_key"`
+	// RedirectURIs are the only places codes are sent; they must match exactly
+	RedirectURIs []string `json:"redirect_uris" db:"redirect_uris"`
+	// PostLogoutRedirectURIs are where users may be sent after logging out
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris" db:"post_logout_redirect_uris"`
+	// Scopes are the most a token issued to the client can carry
+	Scopes    []string  `json:"scopes" db:"scopes"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"time"
+)
+
+// One-time token purposes
+const (
+	OneTimeTokenPasswordReset = "password_reset"
+	OneTimeTokenMFAChallenge  = "mfa_challenge"
+	OneTimeTokenMagicLink     = "magic_link"
+	OneTimeTokenAccountUnlock = "account_unlock"
+)
+
+// OneTimeToken is a single-use, time-limited token emailed to a user. Only a
+// hash of the token is stored so a database leak can't be used to redeem it.
+type OneTimeToken struct {
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	Attempts  int        `json:"attempts" db:"attempts"`
+	// Scope is what an MFA challenge's login was granted
+	Scope     []string  `json:"scope,omitempty" db:"scope"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
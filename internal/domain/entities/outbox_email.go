@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"time"
+)
+
+// Outbox email statuses
+const (
+	OutboxEmailPending = "pending"
+	OutboxEmailSent    = "sent"
+	// OutboxEmailDead emails failed too many times and are no longer retried
+	OutboxEmailDead = "dead"
+)
+
+// OutboxEmail is an email waiting to be delivered. It is written in the same
+// transaction as the change that triggered it, so it can't be lost when the
+// mail server is unavailable. Either Template and Data or Subject and Body
+// are set, until the email is sent or given up on; after that only the
+// metadata is kept.
+type OutboxEmail struct {
+	ID            int64                  `json:"id" db:"id"`
+	Recipient     string                 `json:"recipient" db:"recipient"`
+	Template      string                 `json:"template,omitempty" db:"template"`
+	Data          map[string]interface{} `json:"-" db:"data"`
+	Subject       string                 `json:"subject,omitempty" db:"subject"`
+	Body          string                 `json:"-" db:"body"`
+	Status        string                 `json:"status" db:"status"`
+	Attempts      int                    `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time              `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string                 `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+	SentAt        *time.Time             `json:"sent_at,omitempty" db:"sent_at"`
+}
+
+// OutboxStats summarizes the email queue for operators
+type OutboxStats struct {
+	Pending int `json:"pending"`
+	Sent    int `json:"sent"`
+	Dead    int `json:"dead"`
+	// OldestPendingAt is when the longest waiting pending email was queued
+	OldestPendingAt *time.Time `json:"oldest_pending_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package entities
+
+import (
+	"time"
+)
+
+// RefreshToken tracks an issued refresh token by its jti. Every token
+// obtained by redeeming another belongs to the same family, which starts at
+// login and ends when it is revoked or expires.
+type RefreshToken struct {
+	ID         string     `json:"id" db:"id"`
+	FamilyID   string     `json:"family_id" db:"family_id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty" db:"redeemed_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
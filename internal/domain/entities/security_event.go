@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+)
+
+// Security event types
+const (
+	SecurityEventRefreshTokenReuse        = "refresh_token_reuse"
+	SecurityEventPasswordReset            = "password_reset"
+	SecurityEventPasswordSet              = "password_set"
+	SecurityEventPasswordChanged          = "password_changed"
+	SecurityEventEmailChanged             = "email_changed"
+	SecurityEventAccountDisabled          = "account_disabled"
+	SecurityEventAccountLocked            = "account_locked"
+	SecurityEventAccountUnlocked          = "account_unlocked"
+	SecurityEventSessionsRevoked          = "sessions_revoked"
+	SecurityEventMFAEnabled               = "mfa_enabled"
+	SecurityEventMFADisabled              = "mfa_disabled"
+	SecurityEventRecoveryCodeUsed         = "recovery_code_used"
+	SecurityEventRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	SecurityEventPasskeyAdded             = "passkey_added"
+	SecurityEventPasskeyRemoved           = "passkey_removed"
+	SecurityEventPasskeyCloned            = "passkey_clone_detected"
+	SecurityEventRoleAssigned             = "role_assigned"
+	SecurityEventRoleRemoved              = "role_removed"
+	SecurityEventOAuthAuthorized          = "oauth_client_authorized"
+)
+
+type SecurityEvent struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Type      string    `json:"type" db:"type"`
+	Details   string    `json:"details" db:"details"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
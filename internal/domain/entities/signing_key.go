@@ -0,0 +1,26 @@
+package entities
+
+import (
+	"time"
+)
+
+// Signing key lifecycle: a staged key is published for verification before it
+// signs anything, the active key signs new tokens, an inactive key only
+// verifies tokens it signed earlier, and a retired key is gone for good.
+const (
+	SigningKeyStaged   = "staged"
+	SigningKeyActive   = "active"
+	SigningKeyInactive = "inactive"
+	SigningKeyRetired  = "retired"
+)
+
+type SigningKey struct {
+	ID            string     `json:"kid" db:"kid"`
+	Algorithm     string     `json:"alg" db:"algorithm"`
+	PrivateKey    string     `json:"-" db:"private_key"`
+	Status        string     `json:"status" db:"status"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ActivatedAt   *time.Time `json:"activated_at,omitempty" db:"activated_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty" db:"retired_at"`
+}
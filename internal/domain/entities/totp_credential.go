@@ -0,0 +1,17 @@
+package entities
+
+import (
+	"time"
+)
+
+// TOTPCredential is a user's authenticator app enrollment. It only counts
+// as a second factor once the user has confirmed it with a valid code.
+type TOTPCredential struct {
+	UserID int `json:"user_id" db:"user_id"`
+	// Secret is the decrypted shared secret; it is encrypted at rest
+	Secret      string     `json:"-" db:"secret"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	// LastUsedStep is the most recent time step accepted, so a code can't be replayed
+	LastUsedStep int64     `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
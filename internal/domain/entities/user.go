@@ -5,13 +5,19 @@ import (
 )
 
 type User struct {
-	ID            int       `json:"id" db:"id"`
-	Username      string    `json:"username" db:"username"`
-	Email         string    `json:"email" db:"email"`
-	Password      string    `json:"-" db:"password"`
-	EmailVerified bool      `json:"email_verified" db:"email_verified"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	ID            int    `json:"id" db:"id"`
+	Username      string `json:"username" db:"username"`
+	Email         string `json:"email" db:"email"`
+	Password      string `json:"-" db:"password"`
+	EmailVerified bool   `json:"email_verified" db:"email_verified"`
+	// DisabledAt is set when an operator has disabled the account
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	// FailedLogins counts wrong passwords since the last successful login
+	FailedLogins int `json:"-" db:"failed_logins"`
+	// LockedUntil is set while the account is locked after failed logins
+	LockedUntil *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type UserClaims struct {
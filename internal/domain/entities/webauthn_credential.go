@@ -0,0 +1,22 @@
+package entities
+
+import (
+	"time"
+)
+
+// WebAuthnCredential is a passkey registered by a user
+type WebAuthnCredential struct {
+	// ID is the authenticator's credential ID, base64url encoded
+	ID     string `json:"id" db:"id"`
+	UserID int    `json:"user_id" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// PublicKey is the COSE-encoded key that verifies assertions
+	PublicKey []byte `json:"-" db:"public_key"`
+	// SignCount is the last signature counter seen; authenticators that keep
+	// one must always report a higher value, so a lower one reveals a clone
+	SignCount         uint32     `json:"-" db:"sign_count"`
+	AAGUID            string     `json:"aaguid" db:"aaguid"`
+	AttestationFormat string     `json:"attestation_format" db:"attestation_format"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+	"time"
+)
+
+type EmailOutboxRepository interface {
+	Enqueue(ctx context.Context, email *entities.OutboxEmail) error
+	// ClaimDue leases up to limit pending emails that are due, counting the
+	// attempt and hiding them from other workers until the lease expires
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxEmail, error)
+	// MarkSent records the delivery and clears the email's data and body,
+	// keeping only its metadata
+	MarkSent(ctx context.Context, id int64) error
+	// MarkRetry schedules another attempt after a failed delivery
+	MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	// MarkDead stops retrying an email and clears its data and body
+	MarkDead(ctx context.Context, id int64, lastError string) error
+	Stats(ctx context.Context) (*entities.OutboxStats, error)
+	// DeleteSentBefore removes delivered emails older than the cutoff
+	DeleteSentBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type TOTPRepository interface {
+	// Save stores a new, unconfirmed enrollment, replacing any previous one
+	Save(ctx context.Context, credential *entities.TOTPCredential) error
+	GetByUserID(ctx context.Context, userID int) (*entities.TOTPCredential, error)
+	// IsEnabled reports whether the user has a confirmed enrollment
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	Confirm(ctx context.Context, userID int) error
+	// MarkUsed atomically records step as used, returning false if it or a
+	// later step had already been accepted
+	MarkUsed(ctx context.Context, userID int, step int64) (bool, error)
+	Delete(ctx context.Context, userID int) error
+}
+
+type RecoveryCodeRepository interface {
+	// Replace swaps the user's recovery codes for a new set of hashes
+	Replace(ctx context.Context, userID int, codeHashes []string) error
+	// Consume atomically marks an unused code as used, returning false if
+	// there was none
+	Consume(ctx context.Context, userID int, codeHash string) (bool, error)
+	CountUnused(ctx context.Context, userID int) (int, error)
+	DeleteByUser(ctx context.Context, userID int) error
+}
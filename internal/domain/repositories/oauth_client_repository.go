@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client *entities.OAuthClient) error
+	GetByID(ctx context.Context, id string) (*entities.OAuthClient, error)
+	List(ctx context.Context) ([]*entities.OAuthClient, error)
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type OneTimeTokenRepository interface {
+	Create(ctx context.Context, token *entities.OneTimeToken) error
+	// Consume atomically marks an unused, unexpired token as used and returns it
+	Consume(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error)
+	// Get returns an unused, unexpired token without consuming it
+	Get(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error)
+	// RecordFailedAttempt counts a wrong guess against the token and burns it
+	// once maxAttempts is reached
+	RecordFailedAttempt(ctx context.Context, tokenHash string, maxAttempts int) error
+	// DeleteByUser invalidates every outstanding token of the purpose for the user
+	DeleteByUser(ctx context.Context, userID int, purpose string) error
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *entities.RefreshToken) error
+	GetByID(ctx context.Context, id string) (*entities.RefreshToken, error)
+	// MarkRedeemed atomically redeems the token, returning false if it had
+	// already been redeemed
+	MarkRedeemed(ctx context.Context, id string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeByUser revokes every live family of the user and returns their IDs
+	RevokeByUser(ctx context.Context, userID int) ([]string, error)
+}
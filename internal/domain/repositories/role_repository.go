@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type RoleRepository interface {
+	Create(ctx context.Context, role *entities.Role) error
+	GetByName(ctx context.Context, name string) (*entities.Role, error)
+	List(ctx context.Context) ([]*entities.Role, error)
+	Delete(ctx context.Context, name string) error
+
+	ListPermissions(ctx context.Context) ([]*entities.Permission, error)
+	// GrantPermission adds the permission to the role, creating the
+	// permission if it doesn't exist yet
+	GrantPermission(ctx context.Context, role, permission string) error
+	RevokePermission(ctx context.Context, role, permission string) error
+
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+	// ListByUser returns the user's roles with their permissions
+	ListByUser(ctx context.Context, userID int) ([]*entities.Role, error)
+	// ListMembers returns the ids of the users who have the role
+	ListMembers(ctx context.Context, role string) ([]int, error)
+}
@@ -0,0 +1,10 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type SecurityEventRepository interface {
+	Create(ctx context.Context, event *entities.SecurityEvent) error
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *entities.SigningKey) error
+	// List returns every key that has not been retired
+	List(ctx context.Context) ([]*entities.SigningKey, error)
+	// Activate makes the key the only active key, demoting the current one to inactive
+	Activate(ctx context.Context, kid string) error
+	// Retire marks an inactive key retired and erases its key material
+	Retire(ctx context.Context, kid string) error
+}
@@ -0,0 +1,12 @@
+package repositories
+
+import (
+	"context"
+)
+
+// TransactionManager groups repository writes into a single unit of work.
+// Repositories called with the context passed to fn take part in the
+// transaction, which is rolled back if fn returns an error.
+type TransactionManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
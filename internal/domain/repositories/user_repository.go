@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"jwt-auth/internal/domain/entities"
+	"time"
 )
 
 type UserRepository interface {
@@ -11,4 +12,9 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id int) (*entities.User, error)
 	Update(ctx context.Context, user *entities.User) error
 	Delete(ctx context.Context, id int) error
+	// RecordFailedLogin counts a wrong password and returns the new count
+	RecordFailedLogin(ctx context.Context, id int) (int, error)
+	LockUntil(ctx context.Context, id int, until time.Time) error
+	// ResetFailedLogins clears the count and any lock
+	ResetFailedLogins(ctx context.Context, id int) error
 }
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+)
+
+type WebAuthnCredentialRepository interface {
+	Create(ctx context.Context, credential *entities.WebAuthnCredential) error
+	GetByID(ctx context.Context, id string) (*entities.WebAuthnCredential, error)
+	ListByUser(ctx context.Context, userID int) ([]*entities.WebAuthnCredential, error)
+	// UpdateSignCount atomically records a use of the credential, returning
+	// false if the counter did not move forward
+	UpdateSignCount(ctx context.Context, id string, signCount uint32) (bool, error)
+	Delete(ctx context.Context, userID int, id string) error
+}
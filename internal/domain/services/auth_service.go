@@ -2,16 +2,181 @@ package services
 
 import (
 	"context"
+	"errors"
 	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"time"
 )
 
+// Errors the HTTP layer maps to specific responses
+var (
+	ErrEmailNotVerified = errors.New("email address has not been verified")
+	ErrCooldownActive   = errors.New("please wait before requesting another email")
+	ErrAccountDisabled  = errors.New("account has been disabled")
+	ErrMFARequired      = errors.New("multi-factor authentication required")
+	ErrInvalidMFACode   = errors.New("invalid verification code")
+	ErrTooManyAttempts  = errors.New("too many failed attempts, try again later")
+	ErrAccountLocked    = errors.New("account is temporarily locked after too many failed logins")
+	ErrInvalidScope     = errors.New("requested scope is unknown or exceeds the scope granted")
+	// ErrInvalidClient is an authorization request for an unknown client or
+	// redirect URI, which must not be redirected back
+	ErrInvalidClient = errors.New("unknown client or redirect URI")
+	// ErrInvalidUserCode is a device user code that is unknown, expired or
+	// already decided
+	ErrInvalidUserCode = errors.New("user code is invalid or expired")
+)
+
+// Sensitive actions that need a fresh code emailed to the user (step-up)
+const (
+	StepUpChangePassword = "change_password"
+	StepUpChangeEmail    = "change_email"
+	StepUpAddPasskey     = "add_passkey"
+)
+
+// MFAChallengeError is returned by Login when the password was correct but
+// the account needs a second factor. The token is exchanged for the
+// session with VerifyMFA.
+type MFAChallengeError struct {
+	Token     string
+	Methods   []string
+	ExpiresIn int64
+}
+
+func (e *MFAChallengeError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFAChallengeError) Unwrap() error {
+	return ErrMFARequired
+}
+
+// AccountLockedError is returned by Login while the account is locked
+// after too many wrong passwords
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return ErrAccountLocked.Error()
+}
+
+func (e *AccountLockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// OAuthError is an OAuth 2.0 error response (RFC 6749 sections 4.1.2.1
+// and 5.2), e.g. invalid_grant
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
 type AuthService interface {
 	Register(ctx context.Context, req *dto.RegisterRequest) (*dto.AuthResponse, error)
 	Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error)
-	RefreshToken(ctx context.Context, refreshToken string) (*dto.AuthResponse, error)
+	// Authenticate checks the password like Login without issuing tokens.
+	// Accounts with a second factor get an MFAChallengeError, answered with
+	// AuthenticateMFA.
+	Authenticate(ctx context.Context, req *dto.LoginRequest) (*entities.User, error)
+	// AuthenticateMFA also returns the amr value of the code that was used:
+	// an authenticator app code or a recovery code
+	AuthenticateMFA(ctx context.Context, mfaToken, code string) (*entities.User, string, error)
+	// RefreshToken may narrow the scope of the session; an empty scope
+	// keeps it
+	RefreshToken(ctx context.Context, refreshToken, scope string) (*dto.AuthResponse, error)
 	ValidateToken(ctx context.Context, token string) (*dto.UserClaims, error)
 	Logout(ctx context.Context, token string) error
 	InitiatePasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, token, newPassword string) error
 	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	// UnlockAccount lifts a lockout with the token emailed when it started
+	UnlockAccount(ctx context.Context, token string) error
+
+	// Magic links. RequestMagicLink returns the nonce RedeemMagicLink needs
+	// along with the emailed token.
+	RequestMagicLink(ctx context.Context, email string) (string, error)
+	RedeemMagicLink(ctx context.Context, token, nonce string) (*dto.AuthResponse, error)
+
+	// Emailed one-time codes, for login and to step up before sensitive actions
+	RequestLoginCode(ctx context.Context, email string) error
+	LoginWithCode(ctx context.Context, email, code string) (*dto.AuthResponse, error)
+	RequestStepUpCode(ctx context.Context, userID int, action string) error
+	ChangePassword(ctx context.Context, userID int, code, newPassword string) error
+	ChangeEmail(ctx context.Context, userID int, code, newEmail string) error
+
+	// Multi-factor authentication
+	VerifyMFA(ctx context.Context, mfaToken, code string) (*dto.AuthResponse, error)
+	EnrollTOTP(ctx context.Context, userID int) (*dto.TOTPEnrollmentResponse, error)
+	ConfirmTOTP(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error)
+	DisableTOTP(ctx context.Context, userID int, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error)
+
+	// Passkeys (WebAuthn)
+	BeginPasskeyRegistration(ctx context.Context, userID int) (*dto.PasskeyCreationOptions, error)
+	FinishPasskeyRegistration(ctx context.Context, userID int, req *dto.PasskeyRegistrationRequest) (*entities.WebAuthnCredential, error)
+	ListPasskeys(ctx context.Context, userID int) ([]*entities.WebAuthnCredential, error)
+	DeletePasskey(ctx context.Context, userID int, credentialID string) error
+	// BeginPasskeyLogin starts a passwordless login, or the second step of a
+	// password login when given the MFA token from Login
+	BeginPasskeyLogin(ctx context.Context, mfaToken string) (*dto.PasskeyRequestOptions, error)
+	FinishPasskeyLogin(ctx context.Context, req *dto.PasskeyAssertionRequest) (*dto.AuthResponse, error)
+
+	// Operator actions
+	// CreateUser creates an account without issuing tokens. Only an
+	// unverified address is sent the verification email.
+	CreateUser(ctx context.Context, req *dto.RegisterRequest, emailVerified bool) (*entities.User, error)
+	DisableUser(ctx context.Context, userID int) error
+	SetPassword(ctx context.Context, userID int, newPassword string) error
+	MarkEmailVerified(ctx context.Context, userID int) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+	UnlockUser(ctx context.Context, userID int) error
+
+	// Roles and permissions. Changes that take access away end the
+	// affected sessions; new access is in the next token.
+	ListRoles(ctx context.Context) ([]*entities.Role, error)
+	ListPermissions(ctx context.Context) ([]*entities.Permission, error)
+	CreateRole(ctx context.Context, name, description string, permissions []string) (*entities.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	GrantPermission(ctx context.Context, role, permission string) error
+	RevokePermission(ctx context.Context, role, permission string) error
+	ListUserRoles(ctx context.Context, userID int) ([]*entities.Role, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+
+	// OAuth 2.1 authorization server. Requests for an unknown client or
+	// redirect URI fail with ErrInvalidClient; other failures are an
+	// OAuthError for the client.
+	CheckAuthorizationRequest(ctx context.Context, req *dto.AuthorizationRequest) (*dto.AuthorizationPrompt, error)
+	// IssueAuthorizationCode returns a single-use code for the signed-in user
+	IssueAuthorizationCode(ctx context.Context, req *dto.AuthorizationRequest, auth *dto.Authentication) (string, error)
+	OAuthToken(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error)
+	// RegisterOAuthClient returns the client secret, if the client has
+	// one; it is not stored and can't be shown again
+	RegisterOAuthClient(ctx context.Context, client *entities.OAuthClient) (string, error)
+	ListOAuthClients(ctx context.Context) ([]*entities.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, clientID string) error
+	// DeviceAuthorization starts the device flow (RFC 8628) for a client
+	// that can't open a browser. The user approves the user code on
+	// another device while the client polls OAuthToken.
+	DeviceAuthorization(ctx context.Context, req *dto.OAuthTokenRequest) (*dto.DeviceAuthorizationResponse, error)
+	// CheckUserCode returns what the user is asked to approve, or
+	// ErrInvalidUserCode
+	CheckUserCode(ctx context.Context, userCode string) (*dto.AuthorizationPrompt, error)
+	ApproveDeviceCode(ctx context.Context, userCode string, auth *dto.Authentication) error
+	DenyDeviceCode(ctx context.Context, userCode string) error
+
+	// OpenID Connect, which is only enabled with an asymmetric signing key.
+	// OpenIDConfiguration is nil when it is disabled.
+	OpenIDConfiguration() *dto.OpenIDConfiguration
+	// UserInfo returns the standard claims about the user that the scope
+	// allows
+	UserInfo(ctx context.Context, userID int, scope []string) (map[string]interface{}, error)
+	// EndSession ends the session an ID token was issued for. The
+	// post-logout redirect URI, if any, has been checked when it returns.
+	EndSession(ctx context.Context, req *dto.EndSessionRequest) error
 }
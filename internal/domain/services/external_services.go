@@ -1,22 +1,201 @@
 package services
 
-import "context"
+import (
+	"context"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"time"
+)
 
 // JWTManager defines the interface for JWT operations
 // (token generation, validation, etc.)
 type JWTManager interface {
 	GenerateToken(userID string, claims map[string]interface{}) (string, error)
-	GenerateRefreshToken(userID string) (string, error)
+	GenerateRefreshToken(userID string, claims map[string]interface{}) (string, error)
+	// GenerateIDToken issues an OpenID Connect ID token
+	GenerateIDToken(userID string, claims map[string]interface{}) (string, error)
+	// ValidateToken only accepts access tokens
 	ValidateToken(token string) (map[string]interface{}, error)
+	// ValidateRefreshToken only accepts refresh tokens
+	ValidateRefreshToken(token string) (map[string]interface{}, error)
+	// ValidateIDToken only accepts ID tokens, even expired ones
+	ValidateIDToken(token string) (map[string]interface{}, error)
+	// ValidateClientAssertion verifies a private_key_jwt client assertion
+	// with the client's PEM public key
+	ValidateClientAssertion(assertion, publicKeyPEM string) (map[string]interface{}, error)
+	AccessTokenExpiry() time.Duration
+	RefreshTokenExpiry() time.Duration
+	// JWKS returns the public keys that verify issued tokens
+	JWKS() dto.JWKSet
+	// SigningAlgorithm is the alg of the key that signs new tokens
+	SigningAlgorithm() string
 }
 
+// SigningKeyManager defines the interface for rotating the keys that sign tokens
+type SigningKeyManager interface {
+	ListKeys(ctx context.Context) ([]*entities.SigningKey, error)
+	StageKey(ctx context.Context, algorithm string) (*entities.SigningKey, error)
+	PromoteKey(ctx context.Context, kid string) error
+	RetireKey(ctx context.Context, kid string) error
+	RetireExpiredKeys(ctx context.Context) ([]string, error)
+}
+
+// TOTPService generates and checks time-based one-time passwords (RFC 6238)
+type TOTPService interface {
+	GenerateSecret() (string, error)
+	// ProvisioningURI returns the otpauth:// URI shown to the user as a QR code
+	ProvisioningURI(secret, accountName string) string
+	// Validate checks the code around the given time and returns the time
+	// step it matched, so callers can refuse to accept a step twice
+	Validate(secret, code string, at time.Time) (int64, bool)
+}
+
+// WebAuthnService verifies the WebAuthn ceremonies that register and use passkeys
+type WebAuthnService interface {
+	// RelyingParty returns the RP ID and the name shown by authenticators
+	RelyingParty() (id, name string)
+	// Challenge returns the challenge the client signed, to find the ceremony it belongs to
+	Challenge(clientDataJSON []byte) (string, error)
+	// VerifyRegistration checks an attestation made for challenge and returns the new credential
+	VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*entities.WebAuthnCredential, error)
+	// VerifyAssertion checks an assertion made for challenge by the credential
+	VerifyAssertion(challenge string, credential *entities.WebAuthnCredential, clientDataJSON, authenticatorData, signature []byte) (*WebAuthnAssertion, error)
+}
+
+// WebAuthnAssertion is what a verified assertion tells about the authenticator
+type WebAuthnAssertion struct {
+	SignCount uint32
+	// UserVerified means the authenticator checked a PIN or biometric
+	UserVerified bool
+}
+
+// ChallengeStore keeps short-lived, single-use server state (e.g., Redis)
+type ChallengeStore interface {
+	Put(ctx context.Context, key, value string, ttl time.Duration) error
+	// Take returns and deletes the value, reporting false if it was missing or expired
+	Take(ctx context.Context, key string) (string, bool, error)
+}
+
+// States of a device authorization grant, as seen by the polling device
+const (
+	DeviceGrantPending  = "pending"
+	DeviceGrantSlowDown = "slow_down"
+	DeviceGrantApproved = "approved"
+	DeviceGrantDenied   = "denied"
+	DeviceGrantExpired  = "expired"
+	// DeviceGrantOtherClient answers a client polling with a device code
+	// issued to another
+	DeviceGrantOtherClient = "other_client"
+)
+
+// DeviceGrantStore keeps device authorization grants (RFC 8628) from the
+// device's request until the device collects the user's decision (e.g.,
+// Redis). Grants are opaque to the store and found by device key or by
+// user key.
+type DeviceGrantStore interface {
+	// Create stores a pending grant that the client's device may poll every
+	// interval. It reports false if the user key is taken.
+	Create(ctx context.Context, deviceKey, userKey, clientID, grant string, interval, ttl time.Duration) (bool, error)
+	// Pending returns the grant of a user key that awaits a decision
+	Pending(ctx context.Context, userKey string) (string, bool, error)
+	// Decide settles a pending grant, replacing it with grant if that is
+	// not empty, and retires the user key. It reports false if the grant
+	// was no longer pending.
+	Decide(ctx context.Context, userKey string, approved bool, grant string) (bool, error)
+	// Poll records a poll and returns the grant's state and the grant.
+	// Polling faster than the interval answers DeviceGrantSlowDown and
+	// lengthens it. A decided grant is returned once and then removed.
+	// Another client polling is answered DeviceGrantOtherClient and
+	// changes nothing.
+	Poll(ctx context.Context, deviceKey, clientID string) (state, grant string, err error)
+}
+
+// OneTimeCodeStore keeps short-lived codes and counts wrong guesses per key
+// (e.g., Redis). Too many wrong guesses lock the key for a while, and new
+// codes don't reset the count.
+type OneTimeCodeStore interface {
+	// Issue stores the code hash for key, replacing any earlier code. It
+	// returns ErrTooManyAttempts while the key is locked.
+	Issue(ctx context.Context, key, codeHash string, ttl time.Duration) error
+	// Verify consumes the code if it matches. A wrong or missing code counts
+	// as a failed attempt, and ErrTooManyAttempts is returned while locked.
+	Verify(ctx context.Context, key, codeHash string) (bool, error)
+	// Attempt counts a try at a code the caller checks itself, such as an
+	// authenticator app code. It returns ErrTooManyAttempts once the key is
+	// locked. Reset forgives the tries after a correct code.
+	Attempt(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Rate limiting algorithms
+const (
+	// RateLimitFixedWindow counts requests in consecutive windows of Period
+	RateLimitFixedWindow = "fixed_window"
+	// RateLimitSlidingLog keeps the time of every request in the last Period
+	RateLimitSlidingLog = "sliding_log"
+	// RateLimitSlidingWindow weighs the previous window's count by how much
+	// of it still overlaps the last Period
+	RateLimitSlidingWindow = "sliding_window"
+	// RateLimitTokenBucket refills Limit tokens evenly over Period and
+	// allows bursts of up to Limit requests
+	RateLimitTokenBucket = "token_bucket"
+)
+
+// RateLimit is a budget of Limit requests per Period
+type RateLimit struct {
+	Algorithm string
+	Limit     int
+	Period    time.Duration
+}
+
+// RateLimitResult is the outcome of counting one request
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// ResetAfter is how long until the full budget is available again
+	ResetAfter time.Duration
+	// RetryAfter is how long until a denied request would be allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore counts requests against rate limits (e.g., Redis)
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// Email templates known to every EmailService
+const (
+	EmailTemplateVerification  = "verification"
+	EmailTemplatePasswordReset = "password_reset"
+	EmailTemplateSecurityAlert = "security_alert"
+	EmailTemplateMagicLink     = "magic_link"
+	EmailTemplateOneTimeCode   = "one_time_code"
+	EmailTemplateAccountLocked = "account_locked"
+)
+
 // EmailService defines the interface for sending emails
 type EmailService interface {
 	SendEmail(ctx context.Context, to, subject, body string) error
+	// SendTemplate renders one of the EmailTemplate* templates with data and sends it
+	SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error
+}
+
+// EmailQueue reports on emails waiting for delivery
+type EmailQueue interface {
+	Stats(ctx context.Context) (*entities.OutboxStats, error)
 }
 
 // TokenBlacklistService defines the interface for token blacklisting (e.g., Redis)
 type TokenBlacklistService interface {
 	BlacklistToken(ctx context.Context, token string, expiration int64) error
 	IsTokenBlacklisted(ctx context.Context, token string) (bool, error)
+	// RevokeFamily rejects every access token issued to a refresh token family
+	RevokeFamily(ctx context.Context, familyID string, expiration int64) error
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// CooldownService enforces a minimum interval between repeated actions (e.g., Redis)
+type CooldownService interface {
+	// StartCooldown begins a cooldown for key, returning false if one is already running
+	StartCooldown(ctx context.Context, key string, duration time.Duration) (bool, error)
 }
@@ -0,0 +1,375 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationLockID is the Postgres advisory lock taken while migrating so
+// that replicas starting at the same time don't apply migrations twice
+const migrationLockID int64 = 0x6a77742d61757468
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDirtySchema means a migration was interrupted and the schema needs
+// to be checked by hand before anything else touches it
+var ErrDirtySchema = errors.New("database schema is dirty")
+
+// Migration is a pair of SQL scripts that move the schema one version up or down
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Reversible is false when there is no down script
+	Reversible bool
+}
+
+// MigrationStatus describes one known or applied migration
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations and records them in the
+// schema_migrations table
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+}
+
+func NewMigrator(db *DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// LoadMigrations reads <version>_<name>.up.sql and .down.sql files, ordered by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+			migration.Reversible = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkClean(state); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := state[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the given number of most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkClean(state); err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(state))
+		for version := range state {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %d is not known to this build", version)
+			}
+			if !migration.Reversible {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration plus any applied one this build doesn't know
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if applied, ok := state[migration.Version]; ok {
+				status.Applied = true
+				status.Dirty = applied.Dirty
+				status.AppliedAt = applied.AppliedAt
+				delete(state, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, applied := range state {
+			statuses = append(statuses, applied)
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		return nil
+	})
+	return statuses, err
+}
+
+// Check refuses a dirty schema or one that is missing migrations this build needs
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Dirty {
+			return fmt.Errorf("%w: migration %d_%s did not complete", ErrDirtySchema, status.Version, status.Name)
+		}
+		if !status.Applied {
+			return fmt.Errorf("migration %d_%s has not been applied", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// Force records the schema as being exactly at version, without running
+// any migration. It is the way out of a dirty schema once it has been
+// repaired by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return fmt.Errorf("migration %d is not known to this build", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return fmt.Errorf("failed to reset schema version: %w", err)
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, dirty, applied_at)
+				VALUES ($1, $2, FALSE, $3)
+			`, migration.Version, migration.Name, time.Now()); err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// apply runs an up script. The version is recorded as dirty first, and only
+// marked clean in the same transaction as the script, so a crash part-way
+// leaves a trace that stops the next start.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if _, err := conn.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version, name, dirty, applied_at)
+		VALUES ($1, $2, TRUE, $3)
+	`, migration.Version, migration.Name, time.Now()); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	err := m.inTx(ctx, conn, migration.Up, `UPDATE schema_migrations SET dirty = FALSE WHERE version = $1`, migration.Version)
+	if err != nil {
+		// The script ran in a rolled back transaction, so nothing changed
+		if _, cleanupErr := conn.ExecContext(context.Background(), `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); cleanupErr != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w (and failed to clear it: %v)", migration.Version, migration.Name, err, cleanupErr)
+		}
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// revert runs a down script, with the same dirty tracking as apply
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if _, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = TRUE WHERE version = $1`, migration.Version); err != nil {
+		return fmt.Errorf("failed to mark migration %d: %w", migration.Version, err)
+	}
+
+	err := m.inTx(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	if err != nil {
+		if _, cleanupErr := conn.ExecContext(context.Background(), `UPDATE schema_migrations SET dirty = FALSE WHERE version = $1`, migration.Version); cleanupErr != nil {
+			return fmt.Errorf("failed to revert migration %d_%s: %w (and failed to clear it: %v)", migration.Version, migration.Name, err, cleanupErr)
+		}
+		return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// inTx runs a script and the statement recording its outcome atomically
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, script, record string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Without arguments the script is sent as a simple query, which allows
+	// several statements
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// state returns the recorded migrations by version
+func (m *Migrator) state(ctx context.Context, conn *sql.Conn) (map[int64]MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, dirty, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	state := map[int64]MigrationStatus{}
+	for rows.Next() {
+		status := MigrationStatus{Applied: true}
+		if err := rows.Scan(&status.Version, &status.Name, &status.Dirty, &status.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		state[status.Version] = status
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return state, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock.
+// Advisory locks belong to a session, so all work must use that connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func checkClean(state map[int64]MigrationStatus) error {
+	for _, status := range state {
+		if status.Dirty {
+			return fmt.Errorf("%w: migration %d_%s did not complete", ErrDirtySchema, status.Version, status.Name)
+		}
+	}
+	return nil
+}
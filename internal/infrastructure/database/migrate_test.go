@@ -0,0 +1,84 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"jwt-auth/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"000001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"000001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"000010_tenth.up.sql":    {Data: []byte("CREATE TABLE c ();")},
+		"000010_tenth.down.sql":  {Data: []byte("DROP TABLE c;")},
+		"README.md":              {Data: []byte("ignored")},
+		"migrations.go":          {Data: []byte("ignored")},
+		"000003_legacy_name.sql": {Data: []byte("ignored: no direction")},
+	}
+
+	loaded, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+	if len(loaded) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(loaded))
+	}
+	for i, want := range []int64{1, 2, 10} {
+		if loaded[i].Version != want {
+			t.Errorf("migration %d has version %d, want %d", i, loaded[i].Version, want)
+		}
+	}
+	if loaded[0].Name != "first" || loaded[0].Down != "DROP TABLE a;" || !loaded[0].Reversible {
+		t.Errorf("unexpected first migration %+v", loaded[0])
+	}
+	if loaded[1].Reversible {
+		t.Error("a migration without a down script must not be reversible")
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing up script": {
+			"000001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		},
+		"conflicting names": {
+			"000001_first.up.sql":  {Data: []byte("CREATE TABLE a ();")},
+			"000001_other.up.sql":  {Data: []byte("CREATE TABLE b ();")},
+			"000002_second.up.sql": {Data: []byte("CREATE TABLE c ();")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadMigrations(fsys); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load embedded migrations: %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	// Versions are sequential so that a missing file is noticed
+	for i, migration := range loaded {
+		if migration.Version != int64(i+1) {
+			t.Errorf("expected version %d, got %d_%s", i+1, migration.Version, migration.Name)
+		}
+		if !migration.Reversible {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+		if strings.TrimSpace(migration.Up) == "" {
+			t.Errorf("migration %d_%s has an empty up script", migration.Version, migration.Name)
+		}
+	}
+}
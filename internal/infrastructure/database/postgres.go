@@ -11,6 +11,7 @@ type DB struct {
 	*sql.DB
 }
 
+// NewPostgresDB connects to Postgres. The schema is managed separately by Migrator.
 func NewPostgresDB(host, port, user, password, dbname, sslmode string) (*DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, password, dbname, sslmode)
@@ -24,29 +25,5 @@ func NewPostgresDB(host, port, user, password, dbname, sslmode string) (*DB, err
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Create users table if not exists
-	if err := createUsersTable(db); err != nil {
-		return nil, fmt.Errorf("failed to create users table: %w", err)
-	}
-
 	return &DB{db}, nil
 }
-
-func createUsersTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(50) UNIQUE NOT NULL,
-		email VARCHAR(100) UNIQUE NOT NULL,
-		password VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-	`
-
-	_, err := db.Exec(query)
-	return err
-}
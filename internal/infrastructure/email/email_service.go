@@ -6,13 +6,31 @@ import (
 	"jwt-auth/internal/domain/services"
 )
 
-type EmailServiceImpl struct{}
+// EmailServiceImpl prints emails to stdout. It is used when no SMTP server
+// is configured, e.g. in local development.
+type EmailServiceImpl struct {
+	renderer *Renderer
+}
 
-func NewEmailService() services.EmailService {
-	return &EmailServiceImpl{}
+func NewEmailService() (services.EmailService, error) {
+	renderer, err := NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+	return &EmailServiceImpl{
+		renderer: renderer,
+	}, nil
 }
 
 func (e *EmailServiceImpl) SendEmail(ctx context.Context, to, subject, body string) error {
 	fmt.Printf("Sending email to %s: %s - %s\n", to, subject, body)
 	return nil
 }
+
+func (e *EmailServiceImpl) SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error {
+	msg, err := e.renderer.Render(template, data)
+	if err != nil {
+		return err
+	}
+	return e.SendEmail(ctx, to, msg.Subject, msg.Text)
+}
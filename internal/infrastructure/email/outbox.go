@@ -0,0 +1,206 @@
+package email
+
+import (
+	"context"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/domain/services"
+	"log"
+	"time"
+)
+
+// OutboxEmailService implements services.EmailService by queueing emails in
+// the outbox. Called inside a transaction, the email is only queued if the
+// transaction commits; OutboxWorker delivers it afterwards.
+type OutboxEmailService struct {
+	repo     repositories.EmailOutboxRepository
+	renderer *Renderer
+}
+
+func NewOutboxEmailService(repo repositories.EmailOutboxRepository) (services.EmailService, error) {
+	renderer, err := NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxEmailService{
+		repo:     repo,
+		renderer: renderer,
+	}, nil
+}
+
+func (e *OutboxEmailService) SendEmail(ctx context.Context, to, subject, body string) error {
+	msg := &Message{To: to, Subject: subject, Text: body}
+	// Catch messages that could never be sent now rather than on every retry
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	return e.repo.Enqueue(ctx, &entities.OutboxEmail{
+		Recipient: to,
+		Subject:   subject,
+		Body:      body,
+	})
+}
+
+func (e *OutboxEmailService) SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error {
+	msg, err := e.renderer.Render(template, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	return e.repo.Enqueue(ctx, &entities.OutboxEmail{
+		Recipient: to,
+		Template:  template,
+		Data:      data,
+	})
+}
+
+type OutboxWorkerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many deliveries are tried before an email is dead
+	MaxAttempts int
+	// BaseBackoff is the delay after the first failure; it doubles with
+	// every further failure up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// SendTimeout bounds a single delivery and the lease on a claimed email
+	SendTimeout time.Duration
+	// SentRetention is how long delivered emails are kept
+	SentRetention time.Duration
+}
+
+// OutboxWorker delivers queued emails through a transport such as SMTP
+type OutboxWorker struct {
+	repo      repositories.EmailOutboxRepository
+	transport services.EmailService
+	config    *OutboxWorkerConfig
+}
+
+func NewOutboxWorker(repo repositories.EmailOutboxRepository, transport services.EmailService, cfg *OutboxWorkerConfig) *OutboxWorker {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.SendTimeout == 0 {
+		cfg.SendTimeout = time.Minute
+	}
+	if cfg.SentRetention == 0 {
+		cfg.SentRetention = 7 * 24 * time.Hour
+	}
+	return &OutboxWorker{
+		repo:      repo,
+		transport: transport,
+		config:    cfg,
+	}
+}
+
+// Run delivers due emails every poll interval until ctx is cancelled
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// Keep going while full batches come back so a backlog drains quickly
+		for {
+			n, err := w.ProcessBatch(ctx)
+			if err != nil {
+				log.Printf("Failed to process email outbox: %v", err)
+			}
+			if err != nil || n < w.config.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			if _, err := w.repo.DeleteSentBefore(ctx, time.Now().Add(-w.config.SentRetention)); err != nil {
+				log.Printf("Failed to clean up email outbox: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch attempts delivery of one batch of due emails and returns
+// how many it claimed
+func (w *OutboxWorker) ProcessBatch(ctx context.Context) (int, error) {
+	// Lease a little longer than a send may take so a slow send can't be
+	// picked up twice
+	emails, err := w.repo.ClaimDue(ctx, w.config.BatchSize, 2*w.config.SendTimeout)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, email := range emails {
+		if err := w.deliver(ctx, email); err != nil {
+			w.fail(ctx, email, err)
+			continue
+		}
+		if err := w.repo.MarkSent(ctx, email.ID); err != nil {
+			// The lease will expire and the email be sent again; a duplicate
+			// beats a lost email
+			log.Printf("Failed to mark email %d as sent: %v", email.ID, err)
+		}
+	}
+	return len(emails), nil
+}
+
+// Stats reports the state of the queue
+func (w *OutboxWorker) Stats(ctx context.Context) (*entities.OutboxStats, error) {
+	return w.repo.Stats(ctx)
+}
+
+func (w *OutboxWorker) deliver(ctx context.Context, email *entities.OutboxEmail) error {
+	ctx, cancel := context.WithTimeout(ctx, w.config.SendTimeout)
+	defer cancel()
+
+	if email.Template != "" {
+		return w.transport.SendTemplate(ctx, email.Recipient, email.Template, email.Data)
+	}
+	return w.transport.SendEmail(ctx, email.Recipient, email.Subject, email.Body)
+}
+
+func (w *OutboxWorker) fail(ctx context.Context, email *entities.OutboxEmail, cause error) {
+	if email.Attempts >= w.config.MaxAttempts {
+		log.Printf("Giving up on email %d to %s after %d attempts: %v", email.ID, email.Recipient, email.Attempts, cause)
+		if err := w.repo.MarkDead(ctx, email.ID, cause.Error()); err != nil {
+			log.Printf("Failed to mark email %d as dead: %v", email.ID, err)
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(w.backoff(email.Attempts))
+	if err := w.repo.MarkRetry(ctx, email.ID, retryAt, cause.Error()); err != nil {
+		log.Printf("Failed to reschedule email %d: %v", email.ID, err)
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number
+// of failed attempts
+func (w *OutboxWorker) backoff(attempts int) time.Duration {
+	delay := w.config.BaseBackoff
+	for i := 1; i < attempts && delay < w.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.config.MaxBackoff)
+}
@@ -0,0 +1,252 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// memoryOutboxRepository keeps the outbox in memory
+type memoryOutboxRepository struct {
+	mu     sync.Mutex
+	nextID int64
+	emails map[int64]*entities.OutboxEmail
+}
+
+func newMemoryOutboxRepository() *memoryOutboxRepository {
+	return &memoryOutboxRepository{emails: make(map[int64]*entities.OutboxEmail)}
+}
+
+func (r *memoryOutboxRepository) Enqueue(ctx context.Context, email *entities.OutboxEmail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	email.ID = r.nextID
+	email.Status = entities.OutboxEmailPending
+	email.NextAttemptAt = time.Now()
+	email.CreatedAt = time.Now()
+	r.emails[email.ID] = email
+	return nil
+}
+
+func (r *memoryOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxEmail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed []*entities.OutboxEmail
+	for id := int64(1); id <= r.nextID && len(claimed) < limit; id++ {
+		email, ok := r.emails[id]
+		if !ok || email.Status != entities.OutboxEmailPending || email.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		email.Attempts++
+		email.NextAttemptAt = time.Now().Add(lease)
+		copied := *email
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (r *memoryOutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.emails[id].Status = entities.OutboxEmailSent
+	r.emails[id].SentAt = &now
+	r.emails[id].Data, r.emails[id].Body = nil, ""
+	return nil
+}
+
+func (r *memoryOutboxRepository) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emails[id].NextAttemptAt = nextAttemptAt
+	r.emails[id].LastError = lastError
+	return nil
+}
+
+func (r *memoryOutboxRepository) MarkDead(ctx context.Context, id int64, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emails[id].Status = entities.OutboxEmailDead
+	r.emails[id].LastError = lastError
+	r.emails[id].Data, r.emails[id].Body = nil, ""
+	return nil
+}
+
+func (r *memoryOutboxRepository) Stats(ctx context.Context) (*entities.OutboxStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := &entities.OutboxStats{}
+	for _, email := range r.emails {
+		switch email.Status {
+		case entities.OutboxEmailPending:
+			stats.Pending++
+		case entities.OutboxEmailSent:
+			stats.Sent++
+		case entities.OutboxEmailDead:
+			stats.Dead++
+		}
+	}
+	return stats, nil
+}
+
+func (r *memoryOutboxRepository) DeleteSentBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// makeDue lets the next ProcessBatch retry without waiting for the backoff
+func (r *memoryOutboxRepository) makeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, email := range r.emails {
+		email.NextAttemptAt = time.Now()
+	}
+}
+
+// flakyTransport fails until it has been called failures times
+type flakyTransport struct {
+	failures  int
+	calls     int
+	templates []string
+}
+
+func (f *flakyTransport) SendEmail(ctx context.Context, to, subject, body string) error {
+	return f.SendTemplate(ctx, to, "", nil)
+}
+
+func (f *flakyTransport) SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("connection refused")
+	}
+	f.templates = append(f.templates, template)
+	return nil
+}
+
+func newTestOutbox(t *testing.T, failures int) (*memoryOutboxRepository, services.EmailService, *OutboxWorker, *flakyTransport) {
+	t.Helper()
+
+	repo := newMemoryOutboxRepository()
+	outbox, err := NewOutboxEmailService(repo)
+	if err != nil {
+		t.Fatalf("failed to create outbox: %v", err)
+	}
+	transport := &flakyTransport{failures: failures}
+	worker := NewOutboxWorker(repo, transport, &OutboxWorkerConfig{
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  10 * time.Minute,
+	})
+	return repo, outbox, worker, transport
+}
+
+func TestOutboxWorker_RetriesWithBackoff(t *testing.T) {
+	repo, outbox, worker, transport := newTestOutbox(t, 2)
+	ctx := context.Background()
+
+	err := outbox.SendTemplate(ctx, "jane@example.com", services.EmailTemplateVerification, map[string]interface{}{
+		"Username": "jane",
+		"Link":     "https://app.example.com/verify",
+	})
+	if err != nil {
+		t.Fatalf("failed to queue email: %v", err)
+	}
+
+	if _, err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch failed: %v", err)
+	}
+	email := repo.emails[1]
+	if email.Status != entities.OutboxEmailPending || email.LastError == "" {
+		t.Fatalf("expected a pending retry, got %+v", email)
+	}
+	if delay := time.Until(email.NextAttemptAt); delay < 50*time.Second || delay > time.Minute {
+		t.Errorf("expected the first retry in about a minute, got %v", delay)
+	}
+
+	// Not due yet
+	if n, _ := worker.ProcessBatch(ctx); n != 0 {
+		t.Errorf("expected nothing due before the backoff elapses, claimed %d", n)
+	}
+
+	repo.makeDue()
+	worker.ProcessBatch(ctx)
+	if delay := time.Until(repo.emails[1].NextAttemptAt); delay < 110*time.Second || delay > 2*time.Minute {
+		t.Errorf("expected the backoff to double, got %v", delay)
+	}
+
+	repo.makeDue()
+	worker.ProcessBatch(ctx)
+	if email := repo.emails[1]; email.Status != entities.OutboxEmailSent || email.Attempts != 3 {
+		t.Errorf("expected the email to be sent on the third attempt, got %+v", email)
+	}
+	if len(transport.templates) != 1 || transport.templates[0] != services.EmailTemplateVerification {
+		t.Errorf("unexpected deliveries %v", transport.templates)
+	}
+}
+
+func TestOutboxWorker_DeadLettersAfterMaxAttempts(t *testing.T) {
+	repo, outbox, worker, _ := newTestOutbox(t, 100)
+	ctx := context.Background()
+
+	if err := outbox.SendEmail(ctx, "jane@example.com", "Hello", "body"); err != nil {
+		t.Fatalf("failed to queue email: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		repo.makeDue()
+		worker.ProcessBatch(ctx)
+	}
+
+	email := repo.emails[1]
+	if email.Status != entities.OutboxEmailDead || email.Attempts != 3 {
+		t.Fatalf("expected the email to be dead after 3 attempts, got %+v", email)
+	}
+	if email.LastError != "connection refused" {
+		t.Errorf("expected the last error to be kept, got %q", email.LastError)
+	}
+
+	stats, err := worker.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Dead != 1 || stats.Pending != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestOutboxEmailService_RejectsUndeliverableEmails(t *testing.T) {
+	repo, outbox, _, _ := newTestOutbox(t, 0)
+	ctx := context.Background()
+
+	if err := outbox.SendTemplate(ctx, "jane@example.com", "unknown", nil); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+	if err := outbox.SendEmail(ctx, "not an address", "Hello", "body"); err == nil {
+		t.Error("expected an error for an invalid recipient")
+	}
+	if len(repo.emails) != 0 {
+		t.Errorf("nothing should have been queued, got %d emails", len(repo.emails))
+	}
+}
+
+func TestOutboxWorker_Backoff(t *testing.T) {
+	worker := NewOutboxWorker(nil, nil, &OutboxWorkerConfig{
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	})
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		5:  5 * time.Minute,
+		60: 5 * time.Minute,
+	} {
+		if got := worker.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"jwt-auth/internal/domain/services"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP transport security modes
+const (
+	TLSModeStartTLS = "starttls"
+	TLSModeImplicit = "tls"
+	TLSModeNone     = "none"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// TLSMode is starttls (default), tls for implicit TLS (usually port 465)
+	// or none for local relays
+	TLSMode string
+	// TLSConfig overrides the TLS settings, e.g. to trust a private CA
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+// SMTPEmailService implements services.EmailService over SMTP
+type SMTPEmailService struct {
+	config   *SMTPConfig
+	renderer *Renderer
+}
+
+func NewSMTPEmailService(cfg *SMTPConfig) (services.EmailService, error) {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid SMTP sender address: %w", err)
+	}
+	switch cfg.TLSMode {
+	case "":
+		cfg.TLSMode = TLSModeStartTLS
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		return nil, fmt.Errorf("unsupported SMTP TLS mode %q", cfg.TLSMode)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	renderer, err := NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+	return &SMTPEmailService{
+		config:   cfg,
+		renderer: renderer,
+	}, nil
+}
+
+func (e *SMTPEmailService) SendEmail(ctx context.Context, to, subject, body string) error {
+	return e.send(ctx, &Message{To: to, Subject: subject, Text: body})
+}
+
+func (e *SMTPEmailService) SendTemplate(ctx context.Context, to, template string, data map[string]interface{}) error {
+	msg, err := e.renderer.Render(template, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return e.send(ctx, msg)
+}
+
+func (e *SMTPEmailService) send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes(e.config.From)
+	if err != nil {
+		return err
+	}
+
+	client, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if e.config.Username != "" {
+		auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.config.From)
+	to, _ := mail.ParseAddress(msg.To)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("smtp RCPT TO failed: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects and secures the session according to the TLS mode
+func (e *SMTPEmailService) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(e.config.Host, e.config.Port)
+	dialer := &net.Dialer{Timeout: e.config.Timeout}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	// Bound the whole conversation, not just the dial
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if e.config.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, e.tlsConfig())
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("smtp TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start smtp session: %w", err)
+	}
+
+	if e.config.TLSMode == TLSModeStartTLS {
+		// Never fall back to plain text when STARTTLS was asked for
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(e.tlsConfig()); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+func (e *SMTPEmailService) tlsConfig() *tls.Config {
+	if e.config.TLSConfig != nil {
+		cfg := e.config.TLSConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = e.config.Host
+		}
+		return cfg
+	}
+	return &tls.Config{ServerName: e.config.Host, MinVersion: tls.VersionTLS12}
+}
@@ -0,0 +1,369 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+)
+
+// receivedMail is what the fake SMTP server accepted
+type receivedMail struct {
+	auth string
+	from string
+	to   string
+	data string
+	tls  bool
+}
+
+// fakeSMTPServer speaks just enough SMTP to accept a single message
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+	received  chan receivedMail
+}
+
+func newFakeSMTPServer(t *testing.T, implicit bool) (*fakeSMTPServer, *x509.CertPool) {
+	t.Helper()
+
+	cert, pool := newTestCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeSMTPServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		implicit:  implicit,
+		received:  make(chan receivedMail, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s, pool
+}
+
+func (s *fakeSMTPServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var got receivedMail
+	if s.implicit {
+		conn = tls.Server(conn, s.tlsConfig)
+		got.tls = true
+	}
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			reply("250-localhost")
+			if !got.tls {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			conn = tls.Server(conn, s.tlsConfig)
+			r = bufio.NewReader(conn)
+			got.tls = true
+		case "AUTH":
+			got.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+			reply("235 ok")
+		case "MAIL":
+			got.from = line
+			reply("250 ok")
+		case "RCPT":
+			got.to = line
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			got.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.received <- got
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func newTestSMTPService(t *testing.T, server *fakeSMTPServer, pool *x509.CertPool, mode string) services.EmailService {
+	t.Helper()
+
+	svc, err := NewSMTPEmailService(&SMTPConfig{
+		Host:      "127.0.0.1",
+		Port:      server.port(),
+		Username:  "mailer",
+		Password:  "secret",
+		From:      "Auth Service <noreply@example.com>",
+		TLSMode:   mode,
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create SMTP email service: %v", err)
+	}
+	return svc
+}
+
+func waitForMail(t *testing.T, server *fakeSMTPServer) receivedMail {
+	t.Helper()
+
+	select {
+	case got := <-server.received:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+		return receivedMail{}
+	}
+}
+
+// parseAlternatives returns the decoded parts of a multipart/alternative message by content type
+func parseAlternatives(t *testing.T, data string) (*mail.Message, map[string]string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %q", msg.Header.Get("Content-Type"))
+	}
+
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("failed to decode part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+	return msg, parts
+}
+
+func TestSMTPEmailService_SendTemplate(t *testing.T) {
+	for _, mode := range []string{TLSModeStartTLS, TLSModeImplicit} {
+		t.Run(mode, func(t *testing.T) {
+			server, pool := newFakeSMTPServer(t, mode == TLSModeImplicit)
+			svc := newTestSMTPService(t, server, pool, mode)
+
+			link := "https://app.example.com/verify?token=a<b>&c"
+			err := svc.SendTemplate(context.Background(), "jane@example.com", services.EmailTemplateVerification, map[string]interface{}{
+				"Username":  "jane",
+				"Link":      link,
+				"ExpiresIn": "24h0m0s",
+			})
+			if err != nil {
+				t.Fatalf("SendTemplate failed: %v", err)
+			}
+
+			got := waitForMail(t, server)
+			if !got.tls {
+				t.Error("message was sent without TLS")
+			}
+			if creds, _ := base64.StdEncoding.DecodeString(got.auth); string(creds) != "\x00mailer\x00secret" {
+				t.Errorf("unexpected credentials %q", creds)
+			}
+			if got.from != "MAIL FROM:<noreply@example.com>" || !strings.HasPrefix(got.to, "RCPT TO:<jane@example.com>") {
+				t.Errorf("unexpected envelope %q / %q", got.from, got.to)
+			}
+
+			msg, parts := parseAlternatives(t, got.data)
+			if msg.Header.Get("To") != "jane@example.com" {
+				t.Errorf("unexpected To header %q", msg.Header.Get("To"))
+			}
+			if msg.Header.Get("Subject") == "" {
+				t.Error("template subject missing")
+			}
+			if !strings.Contains(parts["text/plain"], link) {
+				t.Errorf("text part does not contain the link: %q", parts["text/plain"])
+			}
+			html := parts["text/html"]
+			if !strings.Contains(html, `href="https://app.example.com/verify?token=a%3cb%3e&amp;c"`) || strings.Contains(html, "a<b>") {
+				t.Errorf("HTML part does not escape the link: %q", html)
+			}
+		})
+	}
+}
+
+func TestSMTPEmailService_RequiresStartTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// A server that never offers STARTTLS
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "220 localhost ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(strings.ToUpper(line), "EHLO") {
+				io.WriteString(conn, "250 localhost\r\n")
+			} else {
+				io.WriteString(conn, "221 bye\r\n")
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	svc, err := NewSMTPEmailService(&SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "noreply@example.com",
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create SMTP email service: %v", err)
+	}
+
+	err = svc.SendEmail(context.Background(), "jane@example.com", "Hello", "body")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected a STARTTLS error, got %v", err)
+	}
+}
+
+func TestMessage_RejectsHeaderInjection(t *testing.T) {
+	for _, msg := range []*Message{
+		{To: "jane@example.com\r\nBcc: eve@example.com", Subject: "Hello", Text: "body"},
+		{To: "jane@example.com", Subject: "Hello\r\nBcc: eve@example.com", Text: "body"},
+	} {
+		if _, err := msg.Bytes("noreply@example.com"); err == nil {
+			t.Errorf("expected an error for %q / %q", msg.To, msg.Subject)
+		}
+	}
+}
+
+func TestRenderer_AllTemplates(t *testing.T) {
+	renderer, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	data := map[string]interface{}{
+		"Username":  "jane",
+		"Link":      "https://app.example.com/x",
+		"ExpiresIn": "30m0s",
+		"Message":   "Your password was changed",
+		"Time":      time.Now().Format(time.RFC1123),
+		"Code":      "123456",
+		"Purpose":   "sign in",
+		"Until":     time.Now().Format(time.RFC1123),
+	}
+	for _, name := range []string{
+		services.EmailTemplateVerification,
+		services.EmailTemplatePasswordReset,
+		services.EmailTemplateSecurityAlert,
+		services.EmailTemplateMagicLink,
+		services.EmailTemplateOneTimeCode,
+		services.EmailTemplateAccountLocked,
+	} {
+		msg, err := renderer.Render(name, data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if msg.Subject == "" || msg.Text == "" || msg.HTML == "" {
+			t.Errorf("%s: incomplete message %+v", name, msg)
+		}
+	}
+	if _, err := renderer.Render("unknown", data); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
@@ -0,0 +1,84 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Renderer turns a named template into a message. Every template has a
+// <name>.txt.tmpl file, which also defines the "subject" block, and may have
+// a <name>.html.tmpl alternative.
+type Renderer struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func NewRenderer() (*Renderer, error) {
+	r := &Renderer{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email templates: %w", err)
+	}
+	for _, entry := range entries {
+		path := "templates/" + entry.Name()
+		switch {
+		case strings.HasSuffix(entry.Name(), ".txt.tmpl"):
+			name := strings.TrimSuffix(entry.Name(), ".txt.tmpl")
+			tmpl, err := texttemplate.ParseFS(templateFS, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse email template %s: %w", entry.Name(), err)
+			}
+			if tmpl.Lookup("subject") == nil {
+				return nil, fmt.Errorf("email template %s does not define a subject", entry.Name())
+			}
+			r.text[name] = tmpl
+		case strings.HasSuffix(entry.Name(), ".html.tmpl"):
+			name := strings.TrimSuffix(entry.Name(), ".html.tmpl")
+			tmpl, err := htmltemplate.ParseFS(templateFS, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse email template %s: %w", entry.Name(), err)
+			}
+			r.html[name] = tmpl
+		}
+	}
+	return r, nil
+}
+
+func (r *Renderer) Render(name string, data map[string]interface{}) (*Message, error) {
+	text, ok := r.text[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("failed to render email body: %w", err)
+	}
+
+	msg := &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body.String(),
+	}
+	if html, ok := r.html[name]; ok {
+		var htmlBody bytes.Buffer
+		if err := html.Execute(&htmlBody, data); err != nil {
+			return nil, fmt.Errorf("failed to render email HTML: %w", err)
+		}
+		msg.HTML = htmlBody.String()
+	}
+	return msg, nil
+}
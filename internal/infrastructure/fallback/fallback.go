@@ -0,0 +1,174 @@
+// Package fallback decides what a feature backed by Redis does while Redis
+// is unavailable: degrade to an in-process store, fail open, or fail closed.
+package fallback
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"jwt-auth/internal/domain/services"
+)
+
+// What a feature does while Redis is unavailable
+const (
+	// ModeFallback switches to an in-process store, which only knows what
+	// this replica has seen
+	ModeFallback = "fallback"
+	// ModeOpen carries on as if the check passed
+	ModeOpen = "open"
+	// ModeClosed fails the request
+	ModeClosed = "closed"
+)
+
+func validateMode(mode string) error {
+	switch mode {
+	case ModeFallback, ModeOpen, ModeClosed:
+		return nil
+	}
+	return fmt.Errorf("unknown degraded mode %q (expected %s, %s or %s)", mode, ModeFallback, ModeOpen, ModeClosed)
+}
+
+// degradation logs when a feature starts and stops degrading, rather than
+// on every request
+type degradation struct {
+	feature string
+	mode    string
+
+	mu       sync.Mutex
+	degraded bool
+}
+
+func (d *degradation) observe(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if (err != nil) == d.degraded {
+		return
+	}
+	d.degraded = err != nil
+	if d.degraded {
+		log.Printf("%s is degraded (%s): %v", d.feature, d.mode, err)
+	} else {
+		log.Printf("%s has recovered", d.feature)
+	}
+}
+
+// Implements services.RateLimitStore on top of a primary store
+type RateLimitStore struct {
+	primary  services.RateLimitStore
+	fallback services.RateLimitStore
+	mode     string
+	state    degradation
+}
+
+// NewRateLimitStore answers from primary, and by mode while it fails
+func NewRateLimitStore(primary, fallback services.RateLimitStore, mode string) (*RateLimitStore, error) {
+	if err := validateMode(mode); err != nil {
+		return nil, err
+	}
+	return &RateLimitStore{
+		primary:  primary,
+		fallback: fallback,
+		mode:     mode,
+		state:    degradation{feature: "Rate limiting", mode: mode},
+	}, nil
+}
+
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit services.RateLimit) (*services.RateLimitResult, error) {
+	result, err := s.primary.Allow(ctx, key, limit)
+	s.state.observe(err)
+	if err == nil {
+		return result, nil
+	}
+
+	switch s.mode {
+	case ModeFallback:
+		return s.fallback.Allow(ctx, key, limit)
+	case ModeOpen:
+		// Nothing was counted, so the whole budget is reported
+		return &services.RateLimitResult{Allowed: true, Remaining: limit.Limit}, nil
+	}
+	return nil, err
+}
+
+// Implements services.TokenBlacklistService on top of a primary blacklist.
+// When falling back, tokens and sessions are also blacklisted in a local
+// blacklist, so this replica keeps rejecting them even if Redis missed the
+// write.
+type TokenBlacklist struct {
+	primary services.TokenBlacklistService
+	local   services.TokenBlacklistService
+	mode    string
+	state   degradation
+}
+
+// NewTokenBlacklist answers from primary, and by mode while it fails. local
+// is only used by ModeFallback.
+func NewTokenBlacklist(primary, local services.TokenBlacklistService, mode string) (*TokenBlacklist, error) {
+	if err := validateMode(mode); err != nil {
+		return nil, err
+	}
+	return &TokenBlacklist{
+		primary: primary,
+		local:   local,
+		mode:    mode,
+		state:   degradation{feature: "Token blacklist", mode: mode},
+	}, nil
+}
+
+func (b *TokenBlacklist) BlacklistToken(ctx context.Context, token string, expiresIn int64) error {
+	if b.mode == ModeFallback {
+		b.local.BlacklistToken(ctx, token, expiresIn)
+	}
+	return b.write(b.primary.BlacklistToken(ctx, token, expiresIn))
+}
+
+func (b *TokenBlacklist) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	if b.mode == ModeFallback {
+		if blacklisted, _ := b.local.IsTokenBlacklisted(ctx, token); blacklisted {
+			return true, nil
+		}
+	}
+	return b.read(b.primary.IsTokenBlacklisted(ctx, token))
+}
+
+func (b *TokenBlacklist) RevokeFamily(ctx context.Context, familyID string, expiresIn int64) error {
+	if b.mode == ModeFallback {
+		b.local.RevokeFamily(ctx, familyID, expiresIn)
+	}
+	return b.write(b.primary.RevokeFamily(ctx, familyID, expiresIn))
+}
+
+func (b *TokenBlacklist) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	if b.mode == ModeFallback {
+		if revoked, _ := b.local.IsFamilyRevoked(ctx, familyID); revoked {
+			return true, nil
+		}
+	}
+	return b.read(b.primary.IsFamilyRevoked(ctx, familyID))
+}
+
+// write settles a failed write: only failing closed reports it. Falling
+// back keeps the local entry, failing open accepts the loss.
+func (b *TokenBlacklist) write(err error) error {
+	b.state.observe(err)
+	if err != nil && b.mode == ModeClosed {
+		return err
+	}
+	return nil
+}
+
+// read settles a failed lookup. When falling back the local blacklist has
+// already been checked, so both that and failing open answer "not
+// blacklisted".
+func (b *TokenBlacklist) read(found bool, err error) (bool, error) {
+	b.state.observe(err)
+	if err != nil {
+		if b.mode == ModeClosed {
+			return false, err
+		}
+		return false, nil
+	}
+	return found, nil
+}
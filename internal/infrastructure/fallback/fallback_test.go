@@ -0,0 +1,114 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/infrastructure/memory"
+)
+
+var errUnavailable = errors.New("redis is unavailable")
+
+// downRateLimitStore and downBlacklist stand in for Redis while it is down
+type downRateLimitStore struct{}
+
+func (downRateLimitStore) Allow(ctx context.Context, key string, limit services.RateLimit) (*services.RateLimitResult, error) {
+	return nil, errUnavailable
+}
+
+type downBlacklist struct{}
+
+func (downBlacklist) BlacklistToken(ctx context.Context, token string, expiresIn int64) error {
+	return errUnavailable
+}
+
+func (downBlacklist) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	return false, errUnavailable
+}
+
+func (downBlacklist) RevokeFamily(ctx context.Context, familyID string, expiresIn int64) error {
+	return errUnavailable
+}
+
+func (downBlacklist) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	return false, errUnavailable
+}
+
+func TestRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	limit := services.RateLimit{Algorithm: services.RateLimitFixedWindow, Limit: 1, Period: time.Minute}
+
+	if _, err := NewRateLimitStore(downRateLimitStore{}, nil, "sometimes"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+
+	t.Run("Fallback", func(t *testing.T) {
+		store, _ := NewRateLimitStore(downRateLimitStore{}, memory.NewRateLimitStore(10), ModeFallback)
+		if result, err := store.Allow(ctx, "client", limit); err != nil || !result.Allowed {
+			t.Fatalf("expected the in-process store to allow the first request, got %+v %v", result, err)
+		}
+		if result, err := store.Allow(ctx, "client", limit); err != nil || result.Allowed {
+			t.Errorf("expected the in-process store to enforce the limit, got %+v %v", result, err)
+		}
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		store, _ := NewRateLimitStore(downRateLimitStore{}, nil, ModeOpen)
+		for i := 0; i < 3; i++ {
+			if result, err := store.Allow(ctx, "client", limit); err != nil || !result.Allowed {
+				t.Fatalf("expected every request to be allowed, got %+v %v", result, err)
+			}
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		store, _ := NewRateLimitStore(downRateLimitStore{}, nil, ModeClosed)
+		if _, err := store.Allow(ctx, "client", limit); !errors.Is(err, errUnavailable) {
+			t.Errorf("expected the error to be passed on, got %v", err)
+		}
+	})
+}
+
+func TestTokenBlacklist(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fallback", func(t *testing.T) {
+		blacklist, _ := NewTokenBlacklist(downBlacklist{}, memory.NewTokenBlacklist(10), ModeFallback)
+		if err := blacklist.BlacklistToken(ctx, "token", 60); err != nil {
+			t.Fatalf("expected the token to be blacklisted locally, got %v", err)
+		}
+		if blacklisted, err := blacklist.IsTokenBlacklisted(ctx, "token"); err != nil || !blacklisted {
+			t.Errorf("expected the local entry to be found, got %v %v", blacklisted, err)
+		}
+		if blacklisted, err := blacklist.IsTokenBlacklisted(ctx, "other"); err != nil || blacklisted {
+			t.Errorf("expected other tokens to pass, got %v %v", blacklisted, err)
+		}
+		blacklist.RevokeFamily(ctx, "family", 60)
+		if revoked, err := blacklist.IsFamilyRevoked(ctx, "family"); err != nil || !revoked {
+			t.Errorf("expected the local entry to be found, got %v %v", revoked, err)
+		}
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		blacklist, _ := NewTokenBlacklist(downBlacklist{}, nil, ModeOpen)
+		if err := blacklist.BlacklistToken(ctx, "token", 60); err != nil {
+			t.Fatalf("expected the failed write to be ignored, got %v", err)
+		}
+		if blacklisted, err := blacklist.IsTokenBlacklisted(ctx, "token"); err != nil || blacklisted {
+			t.Errorf("expected lookups to pass, got %v %v", blacklisted, err)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		blacklist, _ := NewTokenBlacklist(downBlacklist{}, nil, ModeClosed)
+		if err := blacklist.BlacklistToken(ctx, "token", 60); !errors.Is(err, errUnavailable) {
+			t.Errorf("expected the failed write to be reported, got %v", err)
+		}
+		if _, err := blacklist.IsFamilyRevoked(ctx, "family"); !errors.Is(err, errUnavailable) {
+			t.Errorf("expected the failed lookup to be reported, got %v", err)
+		}
+	})
+}
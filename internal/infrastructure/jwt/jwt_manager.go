@@ -1,49 +1,197 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"jwt-auth/internal/application/dto"
 	"jwt-auth/internal/domain/services"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Token types carried in the "token_type" claim so that a refresh token can
+// never be accepted where an access token is expected (and vice versa).
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeID      = "id"
 )
 
+type JWTConfig struct {
+	// Algorithm is one of HS256 (default), RS256, ES256 or EdDSA
+	Algorithm string
+	// SecretKey is the HMAC secret used with HS256
+	SecretKey string
+	// PrivateKeyPath is the PEM encoded private key used with RS256, ES256 and EdDSA
+	PrivateKeyPath string
+	// KeyID overrides the kid header; defaults to the RFC 7638 key thumbprint
+	KeyID              string
+	AccessTokenExpiry  time.Duration
+	RefreshTokenExpiry time.Duration
+}
+
+// JWTManagerImpl issues and verifies signed JWTs (RFC 7519).
 type JWTManagerImpl struct {
-	tokens map[string]map[string]interface{}
+	keyring            *Keyring
+	accessTokenExpiry  time.Duration
+	refreshTokenExpiry time.Duration
 }
 
-func NewJWTManager() services.JWTManager {
+// NewJWTManager creates a manager that signs with the configured key only
+func NewJWTManager(cfg *JWTConfig) (services.JWTManager, error) {
+	keyring, err := NewKeyring(context.Background(), cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTManagerWithKeyring(cfg, keyring), nil
+}
+
+// NewJWTManagerWithKeyring creates a manager backed by a (possibly rotating) keyring
+func NewJWTManagerWithKeyring(cfg *JWTConfig, keyring *Keyring) services.JWTManager {
 	return &JWTManagerImpl{
-		tokens: make(map[string]map[string]interface{}),
+		keyring:            keyring,
+		accessTokenExpiry:  cfg.AccessTokenExpiry,
+		refreshTokenExpiry: cfg.RefreshTokenExpiry,
 	}
 }
 
 func (j *JWTManagerImpl) GenerateToken(userID string, claims map[string]interface{}) (string, error) {
-	email, _ := claims["email"].(string)
-	username, _ := claims["username"].(string)
-	if email == "" {
-		email = "unknown@example.com"
+	return j.sign(userID, TokenTypeAccess, j.accessTokenExpiry, claims)
+}
+
+func (j *JWTManagerImpl) GenerateRefreshToken(userID string, claims map[string]interface{}) (string, error) {
+	return j.sign(userID, TokenTypeRefresh, j.refreshTokenExpiry, claims)
+}
+
+// GenerateIDToken issues an OpenID Connect ID token, which lives as long as
+// an access token
+func (j *JWTManagerImpl) GenerateIDToken(userID string, claims map[string]interface{}) (string, error) {
+	return j.sign(userID, TokenTypeID, j.accessTokenExpiry, claims)
+}
+
+func (j *JWTManagerImpl) ValidateToken(token string) (map[string]interface{}, error) {
+	return j.parse(token, TokenTypeAccess, gojwt.WithExpirationRequired(), gojwt.WithIssuedAt())
+}
+
+func (j *JWTManagerImpl) ValidateRefreshToken(token string) (map[string]interface{}, error) {
+	return j.parse(token, TokenTypeRefresh, gojwt.WithExpirationRequired(), gojwt.WithIssuedAt())
+}
+
+// ValidateIDToken checks the signature of an ID token we issued. Expired
+// tokens are accepted: an ID token only identifies a past sign-in, as in
+// the id_token_hint of a logout request.
+func (j *JWTManagerImpl) ValidateIDToken(token string) (map[string]interface{}, error) {
+	return j.parse(token, TokenTypeID, gojwt.WithoutClaimsValidation())
+}
+
+// ValidateClientAssertion verifies a JWT a client signed to authenticate
+// (RFC 7523) with the client's registered public key. The caller checks the
+// claims.
+func (j *JWTManagerImpl) ValidateClientAssertion(assertion, publicKeyPEM string) (map[string]interface{}, error) {
+	key, method, err := parseVerificationKey([]byte(publicKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("invalid client key: %w", err)
 	}
-	token := "access_" + userID + "_" + email
-	j.tokens[token] = map[string]interface{}{
-		"user_id":  userID,
-		"username": username,
-		"email":    email,
+	claims := gojwt.MapClaims{}
+	_, err = gojwt.ParseWithClaims(assertion, claims, func(t *gojwt.Token) (interface{}, error) {
+		return key, nil
+	}, gojwt.WithValidMethods([]string{method.Alg()}), gojwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid client assertion: %w", err)
 	}
-	return token, nil
+	return claims, nil
 }
 
-func (j *JWTManagerImpl) GenerateRefreshToken(userID string) (string, error) {
-	token := "refresh_" + userID
-	// Store minimal claims for refresh token
-	j.tokens[token] = map[string]interface{}{
-		"user_id":  userID,
-		"username": "",
-		"email":    "",
+func (j *JWTManagerImpl) AccessTokenExpiry() time.Duration {
+	return j.accessTokenExpiry
+}
+
+func (j *JWTManagerImpl) RefreshTokenExpiry() time.Duration {
+	return j.refreshTokenExpiry
+}
+
+// JWKS returns the public verification keys. HMAC secrets are never published,
+// so only asymmetric keys appear in the set.
+func (j *JWTManagerImpl) JWKS() dto.JWKSet {
+	return j.keyring.jwks()
+}
+
+func (j *JWTManagerImpl) SigningAlgorithm() string {
+	return j.keyring.signingKey().method.Alg()
+}
+
+func (j *JWTManagerImpl) sign(userID, tokenType string, expiry time.Duration, extra map[string]interface{}) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := gojwt.MapClaims{}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	// Registered claims always win over caller-supplied ones, except that
+	// callers which track individual tokens may choose the jti
+	claims["sub"] = userID
+	if id, _ := claims["jti"].(string); id == "" {
+		claims["jti"] = jti
 	}
-	return token, nil
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(expiry).Unix()
+	claims["token_type"] = tokenType
+
+	key := j.keyring.signingKey()
+	token := gojwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
 }
 
-func (j *JWTManagerImpl) ValidateToken(token string) (map[string]interface{}, error) {
-	if claims, ok := j.tokens[token]; ok {
-		return claims, nil
+func (j *JWTManagerImpl) parse(token, tokenType string, options ...gojwt.ParserOption) (map[string]interface{}, error) {
+	claims := gojwt.MapClaims{}
+	_, err := gojwt.ParseWithClaims(token, claims, func(t *gojwt.Token) (interface{}, error) {
+		key := j.keyring.signingKey()
+		// Tokens issued before kid headers were introduced carry none
+		if kid, ok := t.Header["kid"].(string); ok {
+			if key, ok = j.keyring.verificationKey(kid); !ok {
+				return nil, fmt.Errorf("unknown key id %q", kid)
+			}
+		}
+		// Never let the token pick the algorithm for a key
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return key.verifyKey, nil
+	}, append(options, gojwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}))...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if typ, _ := claims["token_type"].(string); typ != tokenType {
+		return nil, fmt.Errorf("invalid token: expected %s token", tokenType)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("invalid token: missing subject")
+	}
+
+	// Keep the user_id key the application layer has always relied on
+	claims["user_id"] = sub
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
 	}
-	return nil, fmt.Errorf("invalid token")
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,326 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func writeTestKey(t *testing.T, priv interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestJWTManager_AsymmetricAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		alg string
+		kty string
+		key interface{}
+	}{
+		{AlgorithmRS256, "RSA", rsaKey},
+		{AlgorithmES256, "EC", ecKey},
+		{AlgorithmEdDSA, "OKP", edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			manager, err := NewJWTManager(&JWTConfig{
+				Algorithm:          tt.alg,
+				PrivateKeyPath:     writeTestKey(t, tt.key),
+				AccessTokenExpiry:  time.Hour,
+				RefreshTokenExpiry: time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("NewJWTManager failed: %v", err)
+			}
+
+			token, err := manager.GenerateToken("42", map[string]interface{}{"email": "a@example.com"})
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+			claims, err := manager.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken failed: %v", err)
+			}
+			if claims["user_id"] != "42" {
+				t.Errorf("unexpected user_id: %v", claims["user_id"])
+			}
+
+			parsed, _, err := gojwt.NewParser().ParseUnverified(token, gojwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified failed: %v", err)
+			}
+			if parsed.Header["alg"] != tt.alg {
+				t.Errorf("unexpected alg header: %v", parsed.Header["alg"])
+			}
+
+			jwks := manager.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("expected 1 published key, got %d", len(jwks.Keys))
+			}
+			if jwks.Keys[0].Kty != tt.kty || jwks.Keys[0].Kid != parsed.Header["kid"] {
+				t.Errorf("published key does not match token: %+v", jwks.Keys[0])
+			}
+		})
+	}
+}
+
+func TestJWTManager_HMACNotPublished(t *testing.T) {
+	manager, err := NewJWTManager(&JWTConfig{SecretKey: "secret", AccessTokenExpiry: time.Hour})
+	if err != nil {
+		t.Fatalf("NewJWTManager failed: %v", err)
+	}
+	if keys := manager.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("expected no published keys for HS256, got %d", len(keys))
+	}
+}
+
+func TestJWTManager_IDTokens(t *testing.T) {
+	// Every token this manager issues has already expired
+	manager, err := NewJWTManager(&JWTConfig{SecretKey: "secret", AccessTokenExpiry: -time.Minute})
+	if err != nil {
+		t.Fatalf("NewJWTManager failed: %v", err)
+	}
+
+	idToken, err := manager.GenerateIDToken("1", map[string]interface{}{"aud": "client"})
+	if err != nil {
+		t.Fatalf("GenerateIDToken failed: %v", err)
+	}
+	claims, err := manager.ValidateIDToken(idToken)
+	if err != nil {
+		t.Fatalf("expected an expired ID token to be accepted as a hint: %v", err)
+	}
+	if claims["aud"] != "client" || claims["sub"] != "1" {
+		t.Errorf("unexpected claims %v", claims)
+	}
+
+	accessToken, err := manager.GenerateToken("1", nil)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err := manager.ValidateIDToken(accessToken); err == nil {
+		t.Error("expected an access token to be rejected as an ID token")
+	}
+}
+
+func TestJWTManager_RejectsAlgorithmMismatch(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := NewJWTManager(&JWTConfig{
+		Algorithm:      AlgorithmRS256,
+		PrivateKeyPath: writeTestKey(t, ecKey),
+	}); err == nil {
+		t.Error("expected an ECDSA key to be rejected for RS256")
+	}
+
+	// A token signed with HS256 must not verify against an asymmetric manager
+	manager, err := NewJWTManager(&JWTConfig{
+		Algorithm:         AlgorithmES256,
+		PrivateKeyPath:    writeTestKey(t, ecKey),
+		AccessTokenExpiry: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewJWTManager failed: %v", err)
+	}
+	hmac, _ := NewJWTManager(&JWTConfig{SecretKey: "secret", AccessTokenExpiry: time.Hour})
+	token, _ := hmac.GenerateToken("1", nil)
+	if _, err := manager.ValidateToken(token); err == nil {
+		t.Error("expected HS256 token to be rejected by ES256 manager")
+	}
+}
+
+// memorySigningKeyRepository is an in-memory repositories.SigningKeyRepository
+type memorySigningKeyRepository struct {
+	keys []*entities.SigningKey
+	// err makes List fail, as when the database is unreachable
+	err error
+}
+
+func (r *memorySigningKeyRepository) Create(ctx context.Context, key *entities.SigningKey) error {
+	for _, k := range r.keys {
+		if k.ID == key.ID {
+			return fmt.Errorf("duplicate kid")
+		}
+	}
+	copied := *key
+	copied.CreatedAt = time.Now()
+	r.keys = append(r.keys, &copied)
+	return nil
+}
+
+func (r *memorySigningKeyRepository) List(ctx context.Context) ([]*entities.SigningKey, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var keys []*entities.SigningKey
+	for _, k := range r.keys {
+		if k.Status != entities.SigningKeyRetired {
+			copied := *k
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+func (r *memorySigningKeyRepository) Activate(ctx context.Context, kid string) error {
+	now := time.Now()
+	for _, k := range r.keys {
+		if k.Status == entities.SigningKeyActive && k.ID != kid {
+			k.Status = entities.SigningKeyInactive
+			k.DeactivatedAt = &now
+		}
+	}
+	for _, k := range r.keys {
+		if k.ID == kid {
+			k.Status = entities.SigningKeyActive
+			k.ActivatedAt = &now
+			return nil
+		}
+	}
+	return fmt.Errorf("signing key not found")
+}
+
+func (r *memorySigningKeyRepository) Retire(ctx context.Context, kid string) error {
+	for _, k := range r.keys {
+		if k.ID == kid && k.Status == entities.SigningKeyInactive {
+			k.Status = entities.SigningKeyRetired
+			k.PrivateKey = ""
+			return nil
+		}
+	}
+	return fmt.Errorf("signing key not found or not inactive")
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	ctx := context.Background()
+	repo := &memorySigningKeyRepository{}
+	cfg := &JWTConfig{
+		SecretKey:          "seed-secret",
+		AccessTokenExpiry:  time.Hour,
+		RefreshTokenExpiry: time.Hour,
+	}
+
+	keyring, err := NewKeyring(ctx, cfg, repo)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	if len(repo.keys) != 1 || repo.keys[0].Status != entities.SigningKeyActive {
+		t.Fatalf("expected configured key to seed the keyring, got %+v", repo.keys)
+	}
+	manager := NewJWTManagerWithKeyring(cfg, keyring)
+
+	oldToken, _ := manager.GenerateToken("1", nil)
+
+	staged, err := keyring.StageKey(ctx, AlgorithmES256)
+	if err != nil {
+		t.Fatalf("StageKey failed: %v", err)
+	}
+	if keys := manager.JWKS().Keys; len(keys) != 1 || keys[0].Kid != staged.ID {
+		t.Fatalf("expected staged key to be published, got %+v", keys)
+	}
+
+	// A second replica sharing the same repository
+	replica, err := NewKeyring(ctx, cfg, repo)
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	replicaManager := NewJWTManagerWithKeyring(cfg, replica)
+
+	if err := keyring.PromoteKey(ctx, staged.ID); err != nil {
+		t.Fatalf("PromoteKey failed: %v", err)
+	}
+	newToken, _ := manager.GenerateToken("1", nil)
+	parsed, _, _ := gojwt.NewParser().ParseUnverified(newToken, gojwt.MapClaims{})
+	if parsed.Header["kid"] != staged.ID || parsed.Method.Alg() != AlgorithmES256 {
+		t.Fatalf("expected new token to be signed by promoted key, got %v", parsed.Header)
+	}
+
+	// Both generations verify, on both replicas
+	for _, m := range []services.JWTManager{manager, replicaManager} {
+		if _, err := m.ValidateToken(oldToken); err != nil {
+			t.Errorf("old token should still verify: %v", err)
+		}
+		if _, err := m.ValidateToken(newToken); err != nil {
+			t.Errorf("new token should verify: %v", err)
+		}
+	}
+
+	// The previous key cannot be retired while its tokens may be alive
+	oldKid := repo.keys[0].ID
+	if err := keyring.RetireKey(ctx, oldKid); err == nil {
+		t.Error("expected retiring a key with live tokens to fail")
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	repo.keys[0].DeactivatedAt = &past
+	retired, err := keyring.RetireExpiredKeys(ctx)
+	if err != nil || len(retired) != 1 || retired[0] != oldKid {
+		t.Fatalf("expected %s to be retired, got %v (%v)", oldKid, retired, err)
+	}
+	if _, err := manager.ValidateToken(oldToken); err == nil {
+		t.Error("tokens signed by a retired key must not verify")
+	}
+}
+
+func TestKeyring_SeedsOnlyAnEmptyRepository(t *testing.T) {
+	ctx := context.Background()
+	cfg := &JWTConfig{
+		SecretKey:          "seed-secret",
+		AccessTokenExpiry:  time.Hour,
+		RefreshTokenExpiry: time.Hour,
+	}
+
+	repo := &memorySigningKeyRepository{err: fmt.Errorf("connection refused")}
+	if _, err := NewKeyring(ctx, cfg, repo); err == nil {
+		t.Error("expected an unreachable repository to fail the keyring")
+	}
+	if len(repo.keys) != 0 {
+		t.Errorf("expected no key to be seeded, got %+v", repo.keys)
+	}
+
+	// Keys that exist but can't be used are not replaced either
+	repo = &memorySigningKeyRepository{}
+	staged, err := generateSigningKey(AlgorithmES256)
+	if err != nil {
+		t.Fatalf("generateSigningKey failed: %v", err)
+	}
+	material, _ := encodeSigningKey(staged)
+	repo.Create(ctx, &entities.SigningKey{
+		ID:         staged.kid,
+		Algorithm:  AlgorithmES256,
+		PrivateKey: material,
+		Status:     entities.SigningKeyStaged,
+	})
+	if _, err := NewKeyring(ctx, cfg, repo); err == nil {
+		t.Error("expected a keyring without an active key to fail")
+	}
+	if len(repo.keys) != 1 {
+		t.Errorf("expected no key to be seeded, got %+v", repo.keys)
+	}
+}
@@ -0,0 +1,305 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reloadThrottle limits how often an unknown kid forces a reload
+const reloadThrottle = 10 * time.Second
+
+// Keyring holds the key that signs new tokens plus every key that may still
+// verify tokens issued earlier, selected by kid. When backed by a repository
+// the rotation state lives in the database so that every replica agrees, and
+// each replica picks up changes by reloading.
+type Keyring struct {
+	repo             repositories.SigningKeyRepository
+	maxTokenLifetime time.Duration
+
+	mu         sync.RWMutex
+	active     *signingKey
+	keys       map[string]*signingKey
+	lastReload time.Time
+}
+
+// NewKeyring builds a keyring around the configured key. Without a repository
+// the configured key is the only key. With one, the configured key seeds an
+// empty keyring and is ignored afterwards in favour of the persisted keys.
+func NewKeyring(ctx context.Context, cfg *JWTConfig, repo repositories.SigningKeyRepository) (*Keyring, error) {
+	configured, err := newConfiguredKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	kr := &Keyring{
+		repo:             repo,
+		maxTokenLifetime: max(cfg.AccessTokenExpiry, cfg.RefreshTokenExpiry),
+		active:           configured,
+		keys:             map[string]*signingKey{configured.kid: configured},
+	}
+	if repo == nil {
+		return kr, nil
+	}
+
+	// Seed only a keyring that has no keys yet. When they can't be loaded,
+	// a new key would silently take over from the ones tokens were signed
+	// with.
+	records, err := repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	if len(records) == 0 {
+		material, err := encodeSigningKey(configured)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		seed := &entities.SigningKey{
+			ID:          configured.kid,
+			Algorithm:   configured.method.Alg(),
+			PrivateKey:  material,
+			Status:      entities.SigningKeyActive,
+			ActivatedAt: &now,
+		}
+		if err := repo.Create(ctx, seed); err != nil {
+			// Another replica may have seeded the keyring first
+			log.Printf("Failed to seed signing keyring: %v", err)
+		}
+	}
+
+	if err := kr.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Reload replaces the in-memory keys with the persisted ones
+func (kr *Keyring) Reload(ctx context.Context) error {
+	if kr.repo == nil {
+		return nil
+	}
+
+	records, err := kr.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var active *signingKey
+	keys := make(map[string]*signingKey, len(records))
+	for _, record := range records {
+		key, err := decodeSigningKey(record.Algorithm, record.PrivateKey, record.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", record.ID, err)
+		}
+		keys[key.kid] = key
+		if record.Status == entities.SigningKeyActive {
+			active = key
+		}
+	}
+	if active == nil {
+		return fmt.Errorf("no active signing key")
+	}
+
+	kr.mu.Lock()
+	kr.active = active
+	kr.keys = keys
+	kr.lastReload = time.Now()
+	kr.mu.Unlock()
+	return nil
+}
+
+// Watch periodically reloads the keyring and retires keys whose tokens have
+// all expired, until ctx is cancelled.
+func (kr *Keyring) Watch(ctx context.Context, interval time.Duration) {
+	if kr.repo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := kr.Reload(ctx); err != nil {
+				log.Printf("Failed to reload signing keyring: %v", err)
+				continue
+			}
+			if retired, err := kr.RetireExpiredKeys(ctx); err != nil {
+				log.Printf("Failed to retire signing keys: %v", err)
+			} else if len(retired) > 0 {
+				log.Printf("Retired signing keys: %v", retired)
+			}
+		}
+	}
+}
+
+func (kr *Keyring) ListKeys(ctx context.Context) ([]*entities.SigningKey, error) {
+	if kr.repo == nil {
+		kr.mu.RLock()
+		defer kr.mu.RUnlock()
+		return []*entities.SigningKey{{
+			ID:        kr.active.kid,
+			Algorithm: kr.active.method.Alg(),
+			Status:    entities.SigningKeyActive,
+		}}, nil
+	}
+	return kr.repo.List(ctx)
+}
+
+// StageKey generates a new key and publishes it for verification without
+// signing with it yet, so verifiers can fetch it before it is promoted.
+func (kr *Keyring) StageKey(ctx context.Context, algorithm string) (*entities.SigningKey, error) {
+	if kr.repo == nil {
+		return nil, fmt.Errorf("key rotation requires a persistent keyring")
+	}
+
+	key, err := generateSigningKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	material, err := encodeSigningKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &entities.SigningKey{
+		ID:         key.kid,
+		Algorithm:  key.method.Alg(),
+		PrivateKey: material,
+		Status:     entities.SigningKeyStaged,
+	}
+	if err := kr.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, kr.Reload(ctx)
+}
+
+// PromoteKey makes a staged key the signing key. The previous signing key
+// keeps verifying the tokens it issued until it is retired.
+func (kr *Keyring) PromoteKey(ctx context.Context, kid string) error {
+	if kr.repo == nil {
+		return fmt.Errorf("key rotation requires a persistent keyring")
+	}
+	if err := kr.repo.Activate(ctx, kid); err != nil {
+		return err
+	}
+	return kr.Reload(ctx)
+}
+
+// RetireKey removes an inactive key once every token it signed has expired
+func (kr *Keyring) RetireKey(ctx context.Context, kid string) error {
+	if kr.repo == nil {
+		return fmt.Errorf("key rotation requires a persistent keyring")
+	}
+
+	records, err := kr.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.ID != kid {
+			continue
+		}
+		if !kr.expired(record) {
+			return fmt.Errorf("signing key %s may still have unexpired tokens", kid)
+		}
+		if err := kr.repo.Retire(ctx, kid); err != nil {
+			return err
+		}
+		return kr.Reload(ctx)
+	}
+	return fmt.Errorf("signing key not found")
+}
+
+// RetireExpiredKeys retires every inactive key whose tokens have all expired
+func (kr *Keyring) RetireExpiredKeys(ctx context.Context) ([]string, error) {
+	if kr.repo == nil {
+		return nil, nil
+	}
+
+	records, err := kr.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var retired []string
+	for _, record := range records {
+		if !kr.expired(record) {
+			continue
+		}
+		if err := kr.repo.Retire(ctx, record.ID); err != nil {
+			return retired, err
+		}
+		retired = append(retired, record.ID)
+	}
+	if len(retired) > 0 {
+		return retired, kr.Reload(ctx)
+	}
+	return retired, nil
+}
+
+// expired reports whether an inactive key can no longer have live tokens
+func (kr *Keyring) expired(record *entities.SigningKey) bool {
+	if record.Status != entities.SigningKeyInactive || record.DeactivatedAt == nil {
+		return false
+	}
+	return time.Since(*record.DeactivatedAt) > kr.maxTokenLifetime
+}
+
+func (kr *Keyring) signingKey() *signingKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active
+}
+
+// verificationKey looks up a key by kid, reloading once if another replica
+// may have staged a key this one has not seen yet.
+func (kr *Keyring) verificationKey(kid string) (*signingKey, bool) {
+	kr.mu.Lock()
+	key, ok := kr.keys[kid]
+	stale := time.Since(kr.lastReload) > reloadThrottle
+	if !ok && stale {
+		// Count the attempt even if it fails so bogus kids can't hammer the database
+		kr.lastReload = time.Now()
+	}
+	kr.mu.Unlock()
+	if ok || kr.repo == nil || !stale {
+		return key, ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := kr.Reload(ctx); err != nil {
+		log.Printf("Failed to reload signing keyring: %v", err)
+		return nil, false
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok = kr.keys[kid]
+	return key, ok
+}
+
+func (kr *Keyring) jwks() dto.JWKSet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := dto.JWKSet{Keys: []dto.JWK{}}
+	for _, key := range kr.keys {
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
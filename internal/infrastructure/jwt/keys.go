@@ -0,0 +1,273 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"jwt-auth/internal/application/dto"
+	"math/big"
+	"os"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// signingKey is a single key identified by its kid. For HMAC keys both
+// signKey and verifyKey are the shared secret; for asymmetric keys signKey
+// holds the private key and verifyKey the public key.
+type signingKey struct {
+	kid       string
+	method    gojwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func newHMACKey(secret []byte, kid string) *signingKey {
+	k := &signingKey{
+		method:    gojwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+	k.kid = kid
+	if k.kid == "" {
+		k.kid = k.thumbprint()
+	}
+	return k
+}
+
+// loadSigningKey reads a PEM encoded private key from path
+func loadSigningKey(alg, path, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return parseSigningKey(alg, data, kid)
+}
+
+// parseSigningKey parses a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1)
+// and checks that it matches the requested algorithm.
+func parseSigningKey(alg string, data []byte, kid string) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM private key")
+	}
+
+	var priv interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	k := &signingKey{signKey: priv}
+	switch key := priv.(type) {
+	case *rsa.PrivateKey:
+		if alg != AlgorithmRS256 {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		k.method = gojwt.SigningMethodRS256
+		k.verifyKey = &key.PublicKey
+	case *ecdsa.PrivateKey:
+		if alg != AlgorithmES256 {
+			return nil, fmt.Errorf("ECDSA key cannot be used with %s", alg)
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		k.method = gojwt.SigningMethodES256
+		k.verifyKey = &key.PublicKey
+	case ed25519.PrivateKey:
+		if alg != AlgorithmEdDSA {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+		k.method = gojwt.SigningMethodEdDSA
+		k.verifyKey = key.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+
+	k.kid = kid
+	if k.kid == "" {
+		k.kid = k.thumbprint()
+	}
+	return k, nil
+}
+
+// parseVerificationKey parses a PEM encoded public key (PKIX) and returns
+// it with the one algorithm it may verify
+func parseVerificationKey(data []byte) (interface{}, gojwt.SigningMethod, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("failed to decode PEM public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, nil, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		return key, gojwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, gojwt.SigningMethodES256, nil
+	case ed25519.PublicKey:
+		return key, gojwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported public key type %T", pub)
+}
+
+// jwk returns the public part of the key as a JSON Web Key (RFC 7517).
+// Symmetric keys are never published.
+func (k *signingKey) jwk() (dto.JWK, bool) {
+	key := dto.JWK{
+		Use: "sig",
+		Alg: k.method.Alg(),
+		Kid: k.kid,
+	}
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = b64(pub.N.Bytes())
+		key.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		key.Kty = "EC"
+		key.Crv = "P-256"
+		key.X = b64(pub.X.FillBytes(make([]byte, 32)))
+		key.Y = b64(pub.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = b64(pub)
+	default:
+		return dto.JWK{}, false
+	}
+	return key, true
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the default kid
+func (k *signingKey) thumbprint() string {
+	var members string
+	if secret, ok := k.verifyKey.([]byte); ok {
+		members = fmt.Sprintf(`{"k":"%s","kty":"oct"}`, b64(secret))
+	} else {
+		key, _ := k.jwk()
+		switch key.Kty {
+		case "RSA":
+			members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, key.E, key.N)
+		case "EC":
+			members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, key.Crv, key.X, key.Y)
+		case "OKP":
+			members = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, key.Crv, key.X)
+		}
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newConfiguredKey builds the key described by the static configuration
+func newConfiguredKey(cfg *JWTConfig) (*signingKey, error) {
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		if cfg.SecretKey == "" {
+			return nil, fmt.Errorf("HS256 requires a secret key")
+		}
+		return newHMACKey([]byte(cfg.SecretKey), cfg.KeyID), nil
+	case AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA:
+		if cfg.PrivateKeyPath == "" {
+			return nil, fmt.Errorf("%s requires a private key path", cfg.Algorithm)
+		}
+		return loadSigningKey(cfg.Algorithm, cfg.PrivateKeyPath, cfg.KeyID)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+}
+
+// generateSigningKey creates a fresh random key for the algorithm
+func generateSigningKey(alg string) (*signingKey, error) {
+	var priv interface{}
+	var err error
+	switch alg {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+		return newHMACKey(secret, ""), nil
+	case AlgorithmRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return parseSigningKey(alg, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "")
+}
+
+// encodeSigningKey serializes the private key material for storage: the
+// base64 secret for HMAC keys, PKCS#8 PEM otherwise.
+func encodeSigningKey(k *signingKey) (string, error) {
+	if secret, ok := k.signKey.([]byte); ok {
+		return base64.StdEncoding.EncodeToString(secret), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// decodeSigningKey is the inverse of encodeSigningKey
+func decodeSigningKey(alg, material, kid string) (*signingKey, error) {
+	if alg == AlgorithmHS256 {
+		secret, err := base64.StdEncoding.DecodeString(material)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret: %w", err)
+		}
+		return newHMACKey(secret, kid), nil
+	}
+	return parseSigningKey(alg, []byte(material), kid)
+}
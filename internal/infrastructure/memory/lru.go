@@ -0,0 +1,65 @@
+// Package memory keeps state in the process. It stands in for Redis when
+// Redis is unavailable, so it is bounded and only as good as one replica's
+// view of the traffic.
+package memory
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a map bounded to size entries that evicts the least recently used
+// one. Entries may expire. It is not safe for concurrent use.
+type lru[V any] struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newLRU[V any](size int) *lru[V] {
+	return &lru[V]{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the entry under key unless it has expired
+func (l *lru[V]) get(key string, now time.Time) (V, bool) {
+	element, ok := l.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	entry := element.Value.(*lruEntry[V])
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.entries, key)
+		var zero V
+		return zero, false
+	}
+	l.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// set stores value under key until expiresAt; a zero time never expires
+func (l *lru[V]) set(key string, value V, expiresAt time.Time) {
+	if element, ok := l.entries[key]; ok {
+		entry := element.Value.(*lruEntry[V])
+		entry.value, entry.expiresAt = value, expiresAt
+		l.order.MoveToFront(element)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for l.size > 0 && l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
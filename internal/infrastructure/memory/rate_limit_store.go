@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+)
+
+// Implements services.RateLimitStore in the process, for when Redis is
+// unavailable. Counts are per replica, so behind a load balancer clients
+// get up to one budget per replica. The sliding log is approximated by a
+// sliding window counter, which needs no per-request state. Once more than
+// size clients are tracked the least recently seen are forgotten.
+type RateLimitStore struct {
+	mu       sync.Mutex
+	counters *lru[*rateLimitState]
+	now      func() time.Time
+}
+
+type rateLimitState struct {
+	// Window algorithms: the window number and the counts of it and the
+	// window before
+	window            int64
+	current, previous int
+	// Token bucket
+	tokens float64
+	ts     int64
+}
+
+func NewRateLimitStore(size int) *RateLimitStore {
+	return &RateLimitStore{
+		counters: newLRU[*rateLimitState](size),
+		now:      time.Now,
+	}
+}
+
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit services.RateLimit) (*services.RateLimitResult, error) {
+	switch limit.Algorithm {
+	case services.RateLimitFixedWindow, services.RateLimitSlidingLog,
+		services.RateLimitSlidingWindow, services.RateLimitTokenBucket:
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+	if limit.Limit <= 0 || limit.Period < time.Millisecond {
+		return nil, fmt.Errorf("invalid rate limit of %d per %s", limit.Limit, limit.Period)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key = limit.Algorithm + ":" + key
+	state, ok := s.counters.get(key, now)
+	if !ok {
+		state = &rateLimitState{tokens: float64(limit.Limit), ts: now.UnixMilli()}
+	}
+	// Nothing is remembered for longer than two periods
+	s.counters.set(key, state, now.Add(2*limit.Period))
+
+	switch limit.Algorithm {
+	case services.RateLimitFixedWindow:
+		return state.fixedWindow(now.UnixMilli(), limit), nil
+	case services.RateLimitTokenBucket:
+		return state.tokenBucket(now.UnixMilli(), limit), nil
+	default:
+		return state.slidingWindow(now.UnixMilli(), limit), nil
+	}
+}
+
+// roll moves the counters on to window
+func (st *rateLimitState) roll(window int64) {
+	if st.window == window {
+		return
+	}
+	st.previous = 0
+	if st.window == window-1 {
+		st.previous = st.current
+	}
+	st.current, st.window = 0, window
+}
+
+func (st *rateLimitState) fixedWindow(now int64, limit services.RateLimit) *services.RateLimitResult {
+	period := limit.Period.Milliseconds()
+	st.roll(now / period)
+	reset := milliseconds(period - now%period)
+	if st.current >= limit.Limit {
+		return &services.RateLimitResult{ResetAfter: reset, RetryAfter: reset}
+	}
+	st.current++
+	return &services.RateLimitResult{Allowed: true, Remaining: limit.Limit - st.current, ResetAfter: reset}
+}
+
+// slidingWindow mirrors the Redis sliding window script
+func (st *rateLimitState) slidingWindow(now int64, limit services.RateLimit) *services.RateLimitResult {
+	period := limit.Period.Milliseconds()
+	st.roll(now / period)
+	elapsed := now % period
+	budget := float64(limit.Limit)
+	count := float64(st.previous)*float64(period-elapsed)/float64(period) + float64(st.current)
+
+	if count+1 <= budget {
+		st.current++
+		return &services.RateLimitResult{
+			Allowed:    true,
+			Remaining:  int(math.Floor(budget - count - 1)),
+			ResetAfter: milliseconds(2*period - elapsed),
+		}
+	}
+
+	var retry int64
+	if st.current+1 > limit.Limit {
+		// Wait for this window to become the previous one and decay enough
+		retry = period - elapsed + int64(math.Ceil(float64(period)*(1-(budget-1)/float64(st.current))))
+	} else {
+		// Wait for the previous window to decay enough
+		retry = int64(math.Ceil(float64(period)-float64(period)*(budget-1-float64(st.current))/float64(st.previous))) - elapsed
+	}
+	reset := period - elapsed
+	if st.current > 0 {
+		reset += period
+	}
+	return &services.RateLimitResult{ResetAfter: milliseconds(reset), RetryAfter: milliseconds(max(retry, 1))}
+}
+
+// tokenBucket mirrors the Redis token bucket script
+func (st *rateLimitState) tokenBucket(now int64, limit services.RateLimit) *services.RateLimitResult {
+	budget := float64(limit.Limit)
+	rate := budget / float64(limit.Period.Milliseconds())
+	st.tokens = math.Min(budget, st.tokens+float64(max(0, now-st.ts))*rate)
+	st.ts = now
+
+	result := &services.RateLimitResult{}
+	if st.tokens >= 1 {
+		st.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = milliseconds(int64(math.Ceil((1 - st.tokens) / rate)))
+	}
+	result.Remaining = int(math.Floor(st.tokens))
+	result.ResetAfter = milliseconds(int64(math.Ceil((budget - st.tokens) / rate)))
+	return result
+}
+
+func milliseconds(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+)
+
+func TestRateLimitStore_Allow(t *testing.T) {
+	store := NewRateLimitStore(100)
+	now := time.UnixMilli(1_000_000_000_000)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, algorithm := range []string{
+		services.RateLimitFixedWindow,
+		services.RateLimitSlidingLog,
+		services.RateLimitSlidingWindow,
+		services.RateLimitTokenBucket,
+	} {
+		t.Run(algorithm, func(t *testing.T) {
+			limit := services.RateLimit{Algorithm: algorithm, Limit: 3, Period: time.Minute}
+
+			for i := 0; i < 3; i++ {
+				result, err := store.Allow(ctx, "client", limit)
+				if err != nil {
+					t.Fatalf("Allow failed: %v", err)
+				}
+				if !result.Allowed || result.Remaining != 2-i {
+					t.Fatalf("request %d: expected to be allowed with %d remaining, got %+v", i+1, 2-i, result)
+				}
+			}
+			result, _ := store.Allow(ctx, "client", limit)
+			if result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 2*time.Minute {
+				t.Fatalf("expected the fourth request to be denied with a retry time, got %+v", result)
+			}
+			if result, _ := store.Allow(ctx, "other", limit); !result.Allowed {
+				t.Error("expected another key to be allowed")
+			}
+		})
+	}
+
+	t.Run("Budgets come back over time", func(t *testing.T) {
+		limit := services.RateLimit{Algorithm: services.RateLimitSlidingWindow, Limit: 2, Period: time.Minute}
+		store.Allow(ctx, "later", limit)
+		store.Allow(ctx, "later", limit)
+		denied, _ := store.Allow(ctx, "later", limit)
+		if denied.Allowed {
+			t.Fatal("expected the third request to be denied")
+		}
+		now = now.Add(denied.RetryAfter)
+		if result, _ := store.Allow(ctx, "later", limit); !result.Allowed {
+			t.Errorf("expected a request after Retry-After to be allowed, got %+v", result)
+		}
+	})
+
+	t.Run("Least recently seen clients are forgotten", func(t *testing.T) {
+		store := NewRateLimitStore(2)
+		limit := services.RateLimit{Algorithm: services.RateLimitFixedWindow, Limit: 1, Period: time.Minute}
+		store.Allow(ctx, "a", limit)
+		store.Allow(ctx, "b", limit)
+		store.Allow(ctx, "c", limit)
+		if result, _ := store.Allow(ctx, "a", limit); !result.Allowed {
+			t.Error("expected the evicted client to start over")
+		}
+		if result, _ := store.Allow(ctx, "c", limit); result.Allowed {
+			t.Error("expected a tracked client to stay limited")
+		}
+	})
+}
+
+func TestTokenBlacklist(t *testing.T) {
+	blacklist := NewTokenBlacklist(2)
+	ctx := context.Background()
+
+	blacklist.BlacklistToken(ctx, "token-1", 60)
+	blacklist.RevokeFamily(ctx, "family-1", 60)
+	if blacklisted, _ := blacklist.IsTokenBlacklisted(ctx, "token-1"); !blacklisted {
+		t.Error("expected the token to be blacklisted")
+	}
+	if revoked, _ := blacklist.IsFamilyRevoked(ctx, "family-1"); !revoked {
+		t.Error("expected the family to be revoked")
+	}
+	if blacklisted, _ := blacklist.IsTokenBlacklisted(ctx, "family-1"); blacklisted {
+		t.Error("expected tokens and families to be kept apart")
+	}
+
+	blacklist.BlacklistToken(ctx, "expired", 0)
+	if blacklisted, _ := blacklist.IsTokenBlacklisted(ctx, "expired"); blacklisted {
+		t.Error("expected an expired entry to be gone")
+	}
+}
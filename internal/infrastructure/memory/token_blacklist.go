@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Implements services.TokenBlacklistService in the process. Once more
+// than size tokens and sessions are blacklisted the oldest are forgotten.
+type TokenBlacklist struct {
+	mu      sync.Mutex
+	entries *lru[struct{}]
+}
+
+func NewTokenBlacklist(size int) *TokenBlacklist {
+	return &TokenBlacklist{
+		entries: newLRU[struct{}](size),
+	}
+}
+
+func (b *TokenBlacklist) BlacklistToken(ctx context.Context, token string, expiresIn int64) error {
+	b.add(tokenKey(token), expiresIn)
+	return nil
+}
+
+func (b *TokenBlacklist) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	return b.has(tokenKey(token)), nil
+}
+
+func (b *TokenBlacklist) RevokeFamily(ctx context.Context, familyID string, expiresIn int64) error {
+	b.add("family:"+familyID, expiresIn)
+	return nil
+}
+
+func (b *TokenBlacklist) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	return b.has("family:" + familyID), nil
+}
+
+func (b *TokenBlacklist) add(key string, expiresIn int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries.set(key, struct{}{}, time.Now().Add(time.Duration(expiresIn)*time.Second))
+}
+
+func (b *TokenBlacklist) has(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.entries.get(key, time.Now())
+	return ok
+}
+
+// tokenKey hashes tokens so entries stay small
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Implements services.ChallengeStore
+type ChallengeStore struct {
+	redisClient *redis.Client
+}
+
+func NewChallengeStore(redisClient *redis.Client) *ChallengeStore {
+	return &ChallengeStore{
+		redisClient: redisClient,
+	}
+}
+
+func (s *ChallengeStore) Put(ctx context.Context, key, value string, ttl time.Duration) error {
+	key = fmt.Sprintf("challenge:%s", key)
+	return s.redisClient.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *ChallengeStore) Take(ctx context.Context, key string) (string, bool, error) {
+	key = fmt.Sprintf("challenge:%s", key)
+	// GETDEL makes sure two requests can't both redeem the same challenge
+	value, err := s.redisClient.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen is returned instead of calling Redis while it is considered down
+var ErrCircuitOpen = errors.New("redis is unavailable")
+
+// CircuitBreaker is a redis.Hook that stops calling Redis after Threshold
+// consecutive failures, so requests fail fast instead of each waiting for
+// a timeout. After Cooldown one call is let through to probe Redis; if it
+// succeeds the circuit closes again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Open tells whether calls are currently short-circuited
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// allow decides whether a call may go to Redis
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	// Let a single probe through once the cooldown is over
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of a call
+func (b *CircuitBreaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up; that says nothing about Redis
+		return
+	}
+	if !isUnavailable(err) {
+		if b.failures >= b.threshold {
+			log.Printf("Redis is reachable again, closing the circuit")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures == b.threshold {
+		log.Printf("Redis failed %d times in a row, opening the circuit for %s: %v", b.failures, b.cooldown, err)
+	}
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// isUnavailable tells apart errors that mean Redis can't be reached from
+// answers such as redis.Nil or a WRONGTYPE reply
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, redis.ErrPoolTimeout) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
+
+func (b *CircuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *CircuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !b.allow() {
+			cmd.SetErr(ErrCircuitOpen)
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *CircuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !b.allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
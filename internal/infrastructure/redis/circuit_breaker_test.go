@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// Nothing listens on port 1, so every call fails to connect
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	breaker := NewCircuitBreaker(2, 50*time.Millisecond)
+	redisClient.AddHook(breaker)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := redisClient.Ping(ctx).Err(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: expected a connection error, got %v", i+1, err)
+		}
+	}
+	if !breaker.Open() {
+		t.Fatal("expected the circuit to open after 2 failures")
+	}
+
+	if err := redisClient.Ping(ctx).Err(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected calls to fail fast while open, got %v", err)
+	}
+	if _, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Ping(ctx)
+		return nil
+	}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected pipelines to fail fast while open, got %v", err)
+	}
+
+	// After the cooldown a probe goes through, and fails again
+	time.Sleep(60 * time.Millisecond)
+	if err := redisClient.Ping(ctx).Err(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the probe to reach Redis, got %v", err)
+	}
+	if err := redisClient.Ping(ctx).Err(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the circuit to open again after a failed probe, got %v", err)
+	}
+
+	t.Run("Replies are not failures", func(t *testing.T) {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     ":6379",                   // Use a real or mock Redis instance
+			Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
+		})
+		breaker := NewCircuitBreaker(1, time.Minute)
+		redisClient.AddHook(breaker)
+		if err := redisClient.Get(ctx, "circuit_breaker:missing").Err(); err != redis.Nil {
+			t.Fatalf("expected redis.Nil, got %v", err)
+		}
+		if breaker.Open() {
+			t.Error("expected redis.Nil to leave the circuit closed")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Implements services.CooldownService
+type CooldownService struct {
+	redisClient *redis.Client
+}
+
+func NewCooldownService(redisClient *redis.Client) *CooldownService {
+	return &CooldownService{
+		redisClient: redisClient,
+	}
+}
+
+func (s *CooldownService) StartCooldown(ctx context.Context, key string, duration time.Duration) (bool, error) {
+	key = fmt.Sprintf("cooldown:%s", key)
+	return s.redisClient.SetNX(ctx, key, true, duration).Result()
+}
@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slowDownIncrement is how much each slow_down lengthens the polling
+// interval (RFC 8628 section 3.5)
+const slowDownIncrement = 5 * time.Second
+
+// decideDeviceGrantScript settles a pending grant once. The device key is
+// the one the user key pointed to when it was read, and the grant is left
+// alone if that has changed since.
+//
+// KEYS: user code, device code. ARGV: new state, grant or "".
+// Returns 1 if the grant was pending.
+var decideDeviceGrantScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= KEYS[2] then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+if redis.call("HGET", KEYS[2], "state") ~= "pending" then
+	return 0
+end
+redis.call("HSET", KEYS[2], "state", ARGV[1])
+if ARGV[2] ~= "" then
+	redis.call("HSET", KEYS[2], "grant", ARGV[2])
+end
+return 1
+`)
+
+// pollDeviceGrantScript records a poll and hands out a decided grant once.
+// Polls by another client change nothing, so they can't use up the grant.
+//
+// KEYS: device code. ARGV: slow down increment (ms), client id.
+// Returns {state, grant}.
+var pollDeviceGrantScript = redis.NewScript(nowScript + `
+local fields = redis.call("HMGET", KEYS[1], "state", "grant", "interval", "last_poll", "client")
+if not fields[1] then
+	return {"expired", ""}
+end
+if fields[5] ~= ARGV[2] then
+	return {"other_client", ""}
+end
+local interval, last = tonumber(fields[3]), tonumber(fields[4])
+redis.call("HSET", KEYS[1], "last_poll", now)
+if now - last < interval then
+	redis.call("HSET", KEYS[1], "interval", interval + tonumber(ARGV[1]))
+	return {"slow_down", fields[2]}
+end
+if fields[1] ~= "pending" then
+	redis.call("DEL", KEYS[1])
+end
+return {fields[1], fields[2]}
+`)
+
+// Implements services.DeviceGrantStore
+type DeviceGrantStore struct {
+	redisClient *redis.Client
+}
+
+func NewDeviceGrantStore(redisClient *redis.Client) *DeviceGrantStore {
+	return &DeviceGrantStore{
+		redisClient: redisClient,
+	}
+}
+
+// Device and user keys share the {device} hash tag, so that on Redis
+// Cluster the scripts touching both find them in the same slot
+
+func (s *DeviceGrantStore) deviceKey(key string) string {
+	return fmt.Sprintf("{device}:code:%s", key)
+}
+
+func (s *DeviceGrantStore) userKey(key string) string {
+	return fmt.Sprintf("{device}:user:%s", key)
+}
+
+func (s *DeviceGrantStore) Create(ctx context.Context, deviceKey, userKey, clientID, grant string, interval, ttl time.Duration) (bool, error) {
+	device := s.deviceKey(deviceKey)
+	created, err := s.redisClient.SetNX(ctx, s.userKey(userKey), device, ttl).Result()
+	if err != nil || !created {
+		return false, err
+	}
+
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, device,
+			"state", services.DeviceGrantPending,
+			"client", clientID,
+			"grant", grant,
+			"interval", interval.Milliseconds(),
+			"last_poll", 0,
+		)
+		pipe.PExpire(ctx, device, ttl)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *DeviceGrantStore) Pending(ctx context.Context, userKey string) (string, bool, error) {
+	device, err := s.redisClient.Get(ctx, s.userKey(userKey)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	values, err := s.redisClient.HMGet(ctx, device, "state", "grant").Result()
+	if err != nil {
+		return "", false, err
+	}
+	state, _ := values[0].(string)
+	grant, _ := values[1].(string)
+	if state != services.DeviceGrantPending {
+		return "", false, nil
+	}
+	return grant, true, nil
+}
+
+func (s *DeviceGrantStore) Decide(ctx context.Context, userKey string, approved bool, grant string) (bool, error) {
+	state := services.DeviceGrantDenied
+	if approved {
+		state = services.DeviceGrantApproved
+	}
+	user := s.userKey(userKey)
+	device, err := s.redisClient.Get(ctx, user).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	result, err := decideDeviceGrantScript.Run(ctx, s.redisClient, []string{user, device}, state, grant).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+func (s *DeviceGrantStore) Poll(ctx context.Context, deviceKey, clientID string) (string, string, error) {
+	result, err := pollDeviceGrantScript.Run(ctx, s.redisClient, []string{s.deviceKey(deviceKey)},
+		slowDownIncrement.Milliseconds(), clientID).StringSlice()
+	if err != nil {
+		return "", "", err
+	}
+	return result[0], result[1], nil
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDeviceGrantStore(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     ":6379",                   // Use a real or mock Redis instance
+		Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
+	})
+	store := NewDeviceGrantStore(redisClient)
+	ctx := context.Background()
+	// Keys per run keep earlier runs from colliding
+	run := time.Now().Format("150405.000000")
+
+	created, err := store.Create(ctx, "device:"+run, "user:"+run, "client", "request", 50*time.Millisecond, time.Minute)
+	if err != nil || !created {
+		t.Fatalf("Create failed: %v, %v", created, err)
+	}
+	if created, _ := store.Create(ctx, "other:"+run, "user:"+run, "client", "request", time.Second, time.Minute); created {
+		t.Fatal("expected a taken user key to be refused")
+	}
+
+	grant, found, err := store.Pending(ctx, "user:"+run)
+	if err != nil || !found || grant != "request" {
+		t.Fatalf("expected the pending grant, got %q, %v, %v", grant, found, err)
+	}
+
+	if state, _, err := store.Poll(ctx, "device:"+run, "client"); err != nil || state != services.DeviceGrantPending {
+		t.Fatalf("expected pending, got %q, %v", state, err)
+	}
+	if state, _, _ := store.Poll(ctx, "device:"+run, "client"); state != services.DeviceGrantSlowDown {
+		t.Fatalf("expected an early poll to slow down, got %q", state)
+	}
+
+	decided, err := store.Decide(ctx, "user:"+run, true, "approved")
+	if err != nil || !decided {
+		t.Fatalf("Decide failed: %v, %v", decided, err)
+	}
+	if decided, _ := store.Decide(ctx, "user:"+run, false, ""); decided {
+		t.Error("expected a grant to be decided once")
+	}
+	if _, found, _ := store.Pending(ctx, "user:"+run); found {
+		t.Error("expected a decided grant not to be pending")
+	}
+
+	// The slow down lengthened the interval by five seconds
+	if state, _, _ := store.Poll(ctx, "device:"+run, "client"); state != services.DeviceGrantSlowDown {
+		t.Fatalf("expected the lengthened interval to apply, got %q", state)
+	}
+	redisClient.HSet(ctx, store.deviceKey("device:"+run), "last_poll", 0)
+	if state, _, _ := store.Poll(ctx, "device:"+run, "other"); state != services.DeviceGrantOtherClient {
+		t.Fatalf("expected another client to be told apart, got %q", state)
+	}
+	state, grant, err := store.Poll(ctx, "device:"+run, "client")
+	if err != nil || state != services.DeviceGrantApproved || grant != "approved" {
+		t.Fatalf("expected the approved grant, got %q, %q, %v", state, grant, err)
+	}
+	if state, _, _ := store.Poll(ctx, "device:"+run, "client"); state != services.DeviceGrantExpired {
+		t.Errorf("expected the grant to be handed out once, got %q", state)
+	}
+}
@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// verifyCodeScript checks a code and counts the failure atomically, so
+// parallel guesses can't get past the attempt limit.
+//
+// KEYS: code, attempts, lock. ARGV: code hash, max attempts, lockout (ms).
+// Returns 1 on a match, 0 on a miss and -1 while locked.
+var verifyCodeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	return -1
+end
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1], KEYS[2])
+	return 1
+end
+local attempts = redis.call("INCR", KEYS[2])
+if attempts == 1 then
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+end
+if attempts >= tonumber(ARGV[2]) then
+	redis.call("DEL", KEYS[1], KEYS[2])
+	redis.call("SET", KEYS[3], 1, "PX", ARGV[3])
+end
+return 0
+`)
+
+// attemptScript counts a try before the caller checks the code, so parallel
+// guesses are counted too.
+//
+// KEYS: attempts, lock. ARGV: max attempts, lockout (ms).
+// Returns 1 if the try may go ahead and -1 while locked.
+var attemptScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return -1
+end
+local attempts = redis.call("INCR", KEYS[1])
+if attempts == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if attempts > tonumber(ARGV[1]) then
+	redis.call("DEL", KEYS[1])
+	redis.call("SET", KEYS[2], 1, "PX", ARGV[2])
+	return -1
+end
+return 1
+`)
+
+// Implements services.OneTimeCodeStore
+type OneTimeCodeStore struct {
+	redisClient *redis.Client
+	maxAttempts int
+	lockout     time.Duration
+}
+
+// NewOneTimeCodeStore locks a key for lockout once maxAttempts wrong codes
+// were tried within that time
+func NewOneTimeCodeStore(redisClient *redis.Client, maxAttempts int, lockout time.Duration) *OneTimeCodeStore {
+	return &OneTimeCodeStore{
+		redisClient: redisClient,
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+	}
+}
+
+func (s *OneTimeCodeStore) keys(key string) []string {
+	return []string{
+		fmt.Sprintf("otp:code:%s", key),
+		fmt.Sprintf("otp:attempts:%s", key),
+		fmt.Sprintf("otp:lock:%s", key),
+	}
+}
+
+func (s *OneTimeCodeStore) Issue(ctx context.Context, key, codeHash string, ttl time.Duration) error {
+	keys := s.keys(key)
+	locked, err := s.redisClient.Exists(ctx, keys[2]).Result()
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return services.ErrTooManyAttempts
+	}
+	return s.redisClient.Set(ctx, keys[0], codeHash, ttl).Err()
+}
+
+func (s *OneTimeCodeStore) Verify(ctx context.Context, key, codeHash string) (bool, error) {
+	result, err := verifyCodeScript.Run(ctx, s.redisClient, s.keys(key), codeHash, s.maxAttempts, s.lockout.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	switch result {
+	case 1:
+		return true, nil
+	case -1:
+		return false, services.ErrTooManyAttempts
+	default:
+		return false, nil
+	}
+}
+
+func (s *OneTimeCodeStore) Attempt(ctx context.Context, key string) error {
+	keys := s.keys(key)
+	result, err := attemptScript.Run(ctx, s.redisClient, keys[1:], s.maxAttempts, s.lockout.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if result == -1 {
+		return services.ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (s *OneTimeCodeStore) Reset(ctx context.Context, key string) error {
+	return s.redisClient.Del(ctx, s.keys(key)[1]).Err()
+}
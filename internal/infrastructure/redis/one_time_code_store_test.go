@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOneTimeCodeStore_Attempt(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     ":6379",                   // Use a real or mock Redis instance
+		Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
+	})
+	store := NewOneTimeCodeStore(redisClient, 3, time.Minute)
+	ctx := context.Background()
+	// Keys per run keep earlier runs from colliding
+	key := "attempt:" + time.Now().Format("150405.000000")
+
+	for i := 0; i < 2; i++ {
+		if err := store.Attempt(ctx, key); err != nil {
+			t.Fatalf("Attempt %d failed: %v", i+1, err)
+		}
+	}
+	// A correct code forgives the tries so far
+	if err := store.Reset(ctx, key); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.Attempt(ctx, key); err != nil {
+			t.Fatalf("expected try %d to be allowed, got %v", i+1, err)
+		}
+	}
+	if err := store.Attempt(ctx, key); !errors.Is(err, services.ErrTooManyAttempts) {
+		t.Fatalf("expected the 4th try to lock the key, got %v", err)
+	}
+	store.Reset(ctx, key)
+	if err := store.Attempt(ctx, key); !errors.Is(err, services.ErrTooManyAttempts) {
+		t.Errorf("expected Reset not to lift the lock, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The rate limiting algorithms run as Lua scripts so that reading and
+// updating the counters is atomic and concurrent requests can't overshoot
+// the limit. Time comes from the Redis server, so every replica of the
+// service sees the same clock.
+//
+// Every script takes the limit and the period in milliseconds and returns
+// {allowed (1 or 0), remaining, reset after (ms), retry after (ms)}.
+
+// nowScript is shared by the scripts that need the current time in ms
+const nowScript = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+`
+
+var fixedWindowScript = redis.NewScript(`
+local limit, period = tonumber(ARGV[1]), tonumber(ARGV[2])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -1 then
+	-- A counter must never outlive its window
+	redis.call("PEXPIRE", KEYS[1], period)
+	ttl = period
+end
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count >= limit then
+	return {0, 0, ttl, ttl}
+end
+count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], period)
+	ttl = period
+end
+return {1, limit - count, ttl, 0}
+`)
+
+// ARGV[3] is a unique member for this request
+var slidingLogScript = redis.NewScript(nowScript + `
+local limit, period = tonumber(ARGV[1]), tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - period)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed, retry = 0, 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], period)
+	count = count + 1
+	allowed = 1
+else
+	-- The oldest request is the next to leave the window
+	local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+	retry = tonumber(oldest[2]) + period - now
+end
+local newest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+return {allowed, limit - count, tonumber(newest[2]) + period - now, retry}
+`)
+
+// The counters live in one key per window, derived from KEYS[1]
+var slidingWindowScript = redis.NewScript(nowScript + `
+local limit, period = tonumber(ARGV[1]), tonumber(ARGV[2])
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local current_key = KEYS[1] .. ":" .. window
+local previous = tonumber(redis.call("GET", KEYS[1] .. ":" .. (window - 1)) or "0")
+local current = tonumber(redis.call("GET", current_key) or "0")
+local count = previous * (period - elapsed) / period + current
+
+if count + 1 <= limit then
+	redis.call("INCR", current_key)
+	redis.call("PEXPIRE", current_key, period * 2)
+	-- Everything is forgotten once this window has slid past
+	return {1, math.floor(limit - count - 1), 2 * period - elapsed, 0}
+end
+
+local retry
+if current + 1 > limit then
+	-- Wait for this window to become the previous one and decay enough
+	retry = period - elapsed + math.ceil(period * (1 - (limit - 1) / current))
+else
+	-- Wait for the previous window to decay enough
+	retry = math.ceil(period - period * (limit - 1 - current) / previous) - elapsed
+end
+local reset = period - elapsed
+if current > 0 then
+	reset = reset + period
+end
+return {0, 0, reset, math.max(retry, 1)}
+`)
+
+var tokenBucketScript = redis.NewScript(nowScript + `
+local limit, period = tonumber(ARGV[1]), tonumber(ARGV[2])
+local rate = limit / period
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens, ts = tonumber(bucket[1]), tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens, ts = limit, now
+end
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+local reset = math.ceil((limit - tokens) / rate)
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+var rateLimitScripts = map[string]*redis.Script{
+	services.RateLimitFixedWindow:   fixedWindowScript,
+	services.RateLimitSlidingLog:    slidingLogScript,
+	services.RateLimitSlidingWindow: slidingWindowScript,
+	services.RateLimitTokenBucket:   tokenBucketScript,
+}
+
+// Implements services.RateLimitStore
+type RateLimitStore struct {
+	redisClient *redis.Client
+}
+
+func NewRateLimitStore(redisClient *redis.Client) *RateLimitStore {
+	return &RateLimitStore{
+		redisClient: redisClient,
+	}
+}
+
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit services.RateLimit) (*services.RateLimitResult, error) {
+	script, ok := rateLimitScripts[limit.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+	if limit.Limit <= 0 || limit.Period < time.Millisecond {
+		return nil, fmt.Errorf("invalid rate limit of %d per %s", limit.Limit, limit.Period)
+	}
+
+	// Algorithms keep differently shaped state, so they get separate keys
+	key = fmt.Sprintf("rate_limit:%s:%s", limit.Algorithm, key)
+	args := []interface{}{limit.Limit, limit.Period.Milliseconds()}
+	if limit.Algorithm == services.RateLimitSlidingLog {
+		member := make([]byte, 8)
+		if _, err := rand.Read(member); err != nil {
+			return nil, err
+		}
+		args = append(args, hex.EncodeToString(member))
+	}
+
+	values, err := script.Run(ctx, s.redisClient, []string{key}, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit result %v", values)
+	}
+	return &services.RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		ResetAfter: time.Duration(values[2]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
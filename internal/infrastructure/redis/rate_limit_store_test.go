@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimitStore_Allow(t *testing.T) {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     ":6379",                   // Use a real or mock Redis instance
+		Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
+	})
+	store := NewRateLimitStore(redisClient)
+	ctx := context.Background()
+	// A key per run keeps earlier runs from counting
+	run := time.Now().Format("150405.000000")
+
+	for _, algorithm := range []string{
+		services.RateLimitFixedWindow,
+		services.RateLimitSlidingLog,
+		services.RateLimitSlidingWindow,
+		services.RateLimitTokenBucket,
+	} {
+		t.Run(algorithm, func(t *testing.T) {
+			limit := services.RateLimit{Algorithm: algorithm, Limit: 3, Period: time.Minute}
+			key := "test:" + run
+
+			for i := 0; i < 3; i++ {
+				result, err := store.Allow(ctx, key, limit)
+				if err != nil {
+					t.Fatalf("Allow failed: %v", err)
+				}
+				if !result.Allowed || result.Remaining != 2-i {
+					t.Fatalf("request %d: expected to be allowed with %d remaining, got %+v", i+1, 2-i, result)
+				}
+				if result.ResetAfter <= 0 || result.ResetAfter > 2*time.Minute {
+					t.Errorf("request %d: unexpected reset after %v", i+1, result.ResetAfter)
+				}
+			}
+
+			result, err := store.Allow(ctx, key, limit)
+			if err != nil {
+				t.Fatalf("Allow failed: %v", err)
+			}
+			if result.Allowed || result.Remaining != 0 {
+				t.Fatalf("expected the fourth request to be denied, got %+v", result)
+			}
+			if result.RetryAfter <= 0 || result.RetryAfter > 2*time.Minute {
+				t.Errorf("unexpected retry after %v", result.RetryAfter)
+			}
+
+			// Other keys have their own budget
+			if result, _ := store.Allow(ctx, key+":other", limit); !result.Allowed {
+				t.Error("expected another key to be allowed")
+			}
+		})
+	}
+
+	t.Run("Token bucket refills", func(t *testing.T) {
+		limit := services.RateLimit{Algorithm: services.RateLimitTokenBucket, Limit: 10, Period: 100 * time.Millisecond}
+		key := "test:refill:" + run
+		for i := 0; i < 10; i++ {
+			store.Allow(ctx, key, limit)
+		}
+		result, _ := store.Allow(ctx, key, limit)
+		if result.Allowed || result.RetryAfter > 20*time.Millisecond {
+			t.Fatalf("expected an empty bucket that refills quickly, got %+v", result)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if result, _ := store.Allow(ctx, key, limit); !result.Allowed {
+			t.Errorf("expected a token after the refill, got %+v", result)
+		}
+	})
+
+	t.Run("Invalid limits", func(t *testing.T) {
+		if _, err := store.Allow(ctx, "test", services.RateLimit{Algorithm: "leaky", Limit: 1, Period: time.Second}); err == nil {
+			t.Error("expected an unknown algorithm to be rejected")
+		}
+		if _, err := store.Allow(ctx, "test", services.RateLimit{Algorithm: services.RateLimitFixedWindow, Period: time.Second}); err == nil {
+			t.Error("expected a zero limit to be rejected")
+		}
+	})
+}
@@ -32,3 +32,17 @@ func (s *TokenBlacklistService) IsTokenBlacklisted(ctx context.Context, token st
 	}
 	return exists > 0, nil
 }
+
+func (s *TokenBlacklistService) RevokeFamily(ctx context.Context, familyID string, expiresIn int64) error {
+	key := fmt.Sprintf("blacklist:family:%s", familyID)
+	return s.redisClient.Set(ctx, key, true, time.Duration(expiresIn)*time.Second).Err()
+}
+
+func (s *TokenBlacklistService) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	key := fmt.Sprintf("blacklist:family:%s", familyID)
+	exists, err := s.redisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
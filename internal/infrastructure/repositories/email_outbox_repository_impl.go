@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type emailOutboxRepository struct {
+	db *database.DB
+}
+
+func NewEmailOutboxRepository(db *database.DB) repositories.EmailOutboxRepository {
+	return &emailOutboxRepository{
+		db: db,
+	}
+}
+
+func (r *emailOutboxRepository) Enqueue(ctx context.Context, email *entities.OutboxEmail) error {
+	data, err := json.Marshal(email.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode email data: %w", err)
+	}
+
+	query := `
+		INSERT INTO email_outbox (recipient, template, data, subject, body, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id, created_at
+	`
+
+	now := time.Now()
+	err = r.db.QueryRowContext(
+		ctx, query,
+		email.Recipient, email.Template, string(data), email.Subject, email.Body,
+		entities.OutboxEmailPending, now,
+	).Scan(&email.ID, &email.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+
+	email.Status = entities.OutboxEmailPending
+	email.NextAttemptAt = now
+	return nil
+}
+
+func (r *emailOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entities.OutboxEmail, error) {
+	// SKIP LOCKED lets several replicas drain the queue without blocking on
+	// or double-sending each other's rows
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1, next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, template, data, subject, body, status, attempts, next_attempt_at, last_error, created_at
+	`
+
+	now := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, now.Add(lease), entities.OutboxEmailPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []*entities.OutboxEmail
+	for rows.Next() {
+		email := &entities.OutboxEmail{}
+		var data string
+		if err := rows.Scan(
+			&email.ID, &email.Recipient, &email.Template, &data, &email.Subject, &email.Body,
+			&email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError, &email.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan email: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &email.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode email data: %w", err)
+		}
+		emails = append(emails, email)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+
+	return emails, nil
+}
+
+// scrubbedContent clears what an email said once it won't be sent again.
+// Emails carry live reset links, unlock links and codes, which must not
+// outlive delivery in the database.
+const scrubbedContent = `data = '{}', body = ''`
+
+func (r *emailOutboxRepository) MarkSent(ctx context.Context, id int64) error {
+	query := `UPDATE email_outbox SET status = $1, sent_at = $2, last_error = '', ` + scrubbedContent + ` WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, entities.OutboxEmailSent, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to mark email as sent: %w", err)
+	}
+
+	return nil
+}
+
+func (r *emailOutboxRepository) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE email_outbox SET next_attempt_at = $1, last_error = $2 WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, nextAttemptAt, lastError, id); err != nil {
+		return fmt.Errorf("failed to reschedule email: %w", err)
+	}
+
+	return nil
+}
+
+func (r *emailOutboxRepository) MarkDead(ctx context.Context, id int64, lastError string) error {
+	query := `UPDATE email_outbox SET status = $1, last_error = $2, ` + scrubbedContent + ` WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, entities.OutboxEmailDead, lastError, id); err != nil {
+		return fmt.Errorf("failed to mark email as dead: %w", err)
+	}
+
+	return nil
+}
+
+func (r *emailOutboxRepository) Stats(ctx context.Context) (*entities.OutboxStats, error) {
+	query := `
+		SELECT status, COUNT(*), MIN(created_at)
+		FROM email_outbox
+		GROUP BY status
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email outbox stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &entities.OutboxStats{}
+	for rows.Next() {
+		var status string
+		var count int
+		var oldest time.Time
+		if err := rows.Scan(&status, &count, &oldest); err != nil {
+			return nil, fmt.Errorf("failed to scan email outbox stats: %w", err)
+		}
+		switch status {
+		case entities.OutboxEmailPending:
+			stats.Pending = count
+			stats.OldestPendingAt = &oldest
+		case entities.OutboxEmailSent:
+			stats.Sent = count
+		case entities.OutboxEmailDead:
+			stats.Dead = count
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get email outbox stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *emailOutboxRepository) DeleteSentBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM email_outbox WHERE status = $1 AND sent_at < $2`
+
+	result, err := r.db.ExecContext(ctx, query, entities.OutboxEmailSent, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent emails: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type oauthClientRepository struct {
+	db *database.DB
+}
+
+func NewOAuthClientRepository(db *database.DB) repositories.OAuthClientRepository {
+	return &oauthClientRepository{
+		db: db,
+	}
+}
+
+func (r *oauthClientRepository) Create(ctx context.Context, client *entities.OAuthClient) error {
+	// A nil slice would be stored as NULL
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+	if client.PostLogoutRedirectURIs == nil {
+		client.PostLogoutRedirectURIs = []string{}
+	}
+	query := `
+		INSERT INTO oauth_clients (id, name, token_endpoint_auth_method, secret_hash, public_key,
+			redirect_uris, post_logout_redirect_uris, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		client.ID, client.Name, client.AuthMethod, client.SecretHash, client.PublicKey,
+		pq.Array(client.RedirectURIs), pq.Array(client.PostLogoutRedirectURIs), pq.Array(client.Scopes), time.Now(),
+	).Scan(&client.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("oauth client %s already exists", client.ID)
+		}
+		return fmt.Errorf("failed to create oauth client: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthClientRepository) GetByID(ctx context.Context, id string) (*entities.OAuthClient, error) {
+	query := `
+		SELECT id, name, token_endpoint_auth_method, secret_hash, public_key,
+			redirect_uris, post_logout_redirect_uris, scopes, created_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+
+	client, err := scanOAuthClient(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("oauth client not found")
+		}
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return client, nil
+}
+
+func (r *oauthClientRepository) List(ctx context.Context) ([]*entities.OAuthClient, error) {
+	query := `
+		SELECT id, name, token_endpoint_auth_method, secret_hash, public_key,
+			redirect_uris, post_logout_redirect_uris, scopes, created_at
+		FROM oauth_clients
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*entities.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan oauth client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+func (r *oauthClientRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM oauth_clients WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete oauth client: %w", err)
+	}
+	return expectRow(result, "oauth client not found")
+}
+
+func scanOAuthClient(row interface{ Scan(...interface{}) error }) (*entities.OAuthClient, error) {
+	client := &entities.OAuthClient{}
+	err := row.Scan(&client.ID, &client.Name, &client.AuthMethod, &client.SecretHash, &client.PublicKey,
+		pq.Array(&client.RedirectURIs), pq.Array(&client.PostLogoutRedirectURIs), pq.Array(&client.Scopes), &client.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
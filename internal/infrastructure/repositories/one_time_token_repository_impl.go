@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type oneTimeTokenRepository struct {
+	db *database.DB
+}
+
+func NewOneTimeTokenRepository(db *database.DB) repositories.OneTimeTokenRepository {
+	return &oneTimeTokenRepository{
+		db: db,
+	}
+}
+
+func (r *oneTimeTokenRepository) Create(ctx context.Context, token *entities.OneTimeToken) error {
+	query := `
+		INSERT INTO one_time_tokens (token_hash, user_id, purpose, expires_at, scope, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`
+
+	scope := token.Scope
+	if scope == nil {
+		scope = []string{}
+	}
+	err := r.db.QueryRowContext(
+		ctx, query,
+		token.TokenHash, token.UserID, token.Purpose, token.ExpiresAt, pq.Array(scope), time.Now(),
+	).Scan(&token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create one-time token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oneTimeTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error) {
+	query := `
+		UPDATE one_time_tokens
+		SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING token_hash, user_id, purpose, expires_at, used_at, attempts, scope, created_at
+	`
+
+	token := &entities.OneTimeToken{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash, purpose, time.Now()).Scan(
+		&token.TokenHash, &token.UserID, &token.Purpose,
+		&token.ExpiresAt, &token.UsedAt, &token.Attempts, pq.Array(&token.Scope), &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token is invalid or has expired")
+		}
+		return nil, fmt.Errorf("failed to consume one-time token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *oneTimeTokenRepository) Get(ctx context.Context, tokenHash, purpose string) (*entities.OneTimeToken, error) {
+	query := `
+		SELECT token_hash, user_id, purpose, expires_at, used_at, attempts, scope, created_at
+		FROM one_time_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+	`
+
+	token := &entities.OneTimeToken{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash, purpose, time.Now()).Scan(
+		&token.TokenHash, &token.UserID, &token.Purpose,
+		&token.ExpiresAt, &token.UsedAt, &token.Attempts, pq.Array(&token.Scope), &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token is invalid or has expired")
+		}
+		return nil, fmt.Errorf("failed to get one-time token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *oneTimeTokenRepository) RecordFailedAttempt(ctx context.Context, tokenHash string, maxAttempts int) error {
+	query := `
+		UPDATE one_time_tokens
+		SET attempts = attempts + 1,
+			used_at = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE used_at END
+		WHERE token_hash = $1 AND used_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, tokenHash, maxAttempts, time.Now()); err != nil {
+		return fmt.Errorf("failed to record failed attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oneTimeTokenRepository) DeleteByUser(ctx context.Context, userID int, purpose string) error {
+	query := `DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, purpose); err != nil {
+		return fmt.Errorf("failed to delete one-time tokens: %w", err)
+	}
+
+	return nil
+}
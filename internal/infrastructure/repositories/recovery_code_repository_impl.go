@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type recoveryCodeRepository struct {
+	db *database.DB
+}
+
+func NewRecoveryCodeRepository(db *database.DB) repositories.RecoveryCodeRepository {
+	return &recoveryCodeRepository{
+		db: db,
+	}
+}
+
+func (r *recoveryCodeRepository) Replace(ctx context.Context, userID int, codeHashes []string) error {
+	return r.db.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := r.DeleteByUser(ctx, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`
+		now := time.Now()
+		for _, hash := range codeHashes {
+			if _, err := r.db.ExecContext(ctx, query, userID, hash, now); err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *recoveryCodeRepository) Consume(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(ctx context.Context, userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *recoveryCodeRepository) DeleteByUser(ctx context.Context, userID int) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type refreshTokenRepository struct {
+	db *database.DB
+}
+
+func NewRefreshTokenRepository(db *database.DB) repositories.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		db: db,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *entities.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, family_id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		token.ID, token.FamilyID, token.UserID, token.ExpiresAt, time.Now(),
+	).Scan(&token.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByID(ctx context.Context, id string) (*entities.RefreshToken, error) {
+	query := `
+		SELECT id, family_id, user_id, expires_at, redeemed_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE id = $1
+	`
+
+	token := &entities.RefreshToken{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&token.ID, &token.FamilyID, &token.UserID, &token.ExpiresAt,
+		&token.RedeemedAt, &token.RevokedAt, &token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("refresh token not found")
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *refreshTokenRepository) MarkRedeemed(ctx context.Context, id string) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET redeemed_at = $2
+		WHERE id = $1 AND redeemed_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to redeem refresh token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, familyID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeByUser(ctx context.Context, userID int) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		RETURNING family_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var families []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, fmt.Errorf("failed to scan refresh token family: %w", err)
+		}
+		if !seen[familyID] {
+			seen[familyID] = true
+			families = append(families, familyID)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return families, nil
+}
@@ -0,0 +1,288 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type roleRepository struct {
+	db *database.DB
+}
+
+func NewRoleRepository(db *database.DB) repositories.RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *entities.Role) error {
+	query := `
+		INSERT INTO roles (name, description, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, role.Name, role.Description, time.Now()).Scan(&role.ID, &role.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("role %s already exists", role.Name)
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	for _, permission := range role.Permissions {
+		if err := r.GrantPermission(ctx, role.Name, permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *roleRepository) GetByName(ctx context.Context, name string) (*entities.Role, error) {
+	query := `
+		SELECT id, name, description, created_at
+		FROM roles
+		WHERE name = $1
+	`
+
+	role := &entities.Role{}
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role %s not found", name)
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	if err := r.loadPermissions(ctx, []*entities.Role{role}); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]*entities.Role, error) {
+	query := `
+		SELECT id, name, description, created_at
+		FROM roles
+		ORDER BY name
+	`
+
+	return r.queryRoles(ctx, query)
+}
+
+func (r *roleRepository) Delete(ctx context.Context, name string) error {
+	query := `DELETE FROM roles WHERE name = $1`
+
+	result, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	return expectRow(result, fmt.Sprintf("role %s not found", name))
+}
+
+func (r *roleRepository) ListPermissions(ctx context.Context) ([]*entities.Permission, error) {
+	query := `
+		SELECT id, name, description, created_at
+		FROM permissions
+		ORDER BY name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []*entities.Permission{}
+	for rows.Next() {
+		permission := &entities.Permission{}
+		if err := rows.Scan(&permission.ID, &permission.Name, &permission.Description, &permission.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *roleRepository) GrantPermission(ctx context.Context, role, permission string) error {
+	if _, err := r.GetByName(ctx, role); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx,
+		`INSERT INTO permissions (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, permission,
+	); err != nil {
+		return fmt.Errorf("failed to create permission: %w", err)
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r, permissions p
+		WHERE r.name = $1 AND p.name = $2
+		ON CONFLICT DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, role, permission); err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) RevokePermission(ctx context.Context, role, permission string) error {
+	query := `
+		DELETE FROM role_permissions
+		WHERE role_id = (SELECT id FROM roles WHERE name = $1)
+		AND permission_id = (SELECT id FROM permissions WHERE name = $2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, role, permission)
+	if err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return expectRow(result, fmt.Sprintf("role %s does not have permission %s", role, permission))
+}
+
+func (r *roleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	if _, err := r.GetByName(ctx, role); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id, created_at)
+		SELECT $1, id, $3 FROM roles WHERE name = $2
+		ON CONFLICT DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, role, time.Now()); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+	return expectRow(result, fmt.Sprintf("user does not have role %s", role))
+}
+
+func (r *roleRepository) ListByUser(ctx context.Context, userID int) ([]*entities.Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.created_at
+		FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+
+	return r.queryRoles(ctx, query, userID)
+}
+
+func (r *roleRepository) ListMembers(ctx context.Context, role string) ([]int, error) {
+	query := `
+		SELECT ur.user_id
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE r.name = $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role members: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan role member: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// queryRoles runs a query selecting id, name, description and created_at
+// of roles, and loads their permissions
+func (r *roleRepository) queryRoles(ctx context.Context, query string, args ...interface{}) ([]*entities.Role, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []*entities.Role{}
+	for rows.Next() {
+		role := &entities.Role{}
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Done with the rows before querying again on the same connection
+	rows.Close()
+
+	if err := r.loadPermissions(ctx, roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// loadPermissions fills in the permissions of the roles
+func (r *roleRepository) loadPermissions(ctx context.Context, roles []*entities.Role) error {
+	query := `
+		SELECT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		WHERE rp.role_id = $1
+		ORDER BY p.name
+	`
+
+	for _, role := range roles {
+		rows, err := r.db.QueryContext(ctx, query, role.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load role permissions: %w", err)
+		}
+		role.Permissions = []string{}
+		for rows.Next() {
+			var permission string
+			if err := rows.Scan(&permission); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan role permission: %w", err)
+			}
+			role.Permissions = append(role.Permissions, permission)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectRow turns a statement that affected no rows into an error
+func expectRow(result sql.Result, notFound string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s", notFound)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type securityEventRepository struct {
+	db *database.DB
+}
+
+func NewSecurityEventRepository(db *database.DB) repositories.SecurityEventRepository {
+	return &securityEventRepository{
+		db: db,
+	}
+}
+
+func (r *securityEventRepository) Create(ctx context.Context, event *entities.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (user_id, type, details, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		event.UserID, event.Type, event.Details, time.Now(),
+	).Scan(&event.ID, &event.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return nil
+}
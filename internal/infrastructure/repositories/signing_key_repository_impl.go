@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/crypto"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+// signingKeyRepository encrypts private keys before they reach the
+// database, bound to their kid. Keys stored in the clear by earlier versions
+// are encrypted the first time they are listed.
+type signingKeyRepository struct {
+	db  *database.DB
+	box *crypto.SecretBox
+}
+
+func NewSigningKeyRepository(db *database.DB, box *crypto.SecretBox) repositories.SigningKeyRepository {
+	return &signingKeyRepository{
+		db:  db,
+		box: box,
+	}
+}
+
+func signingKeyContext(kid string) string {
+	return "signing_key:" + kid
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, key *entities.SigningKey) error {
+	sealed, err := r.box.Seal(key.PrivateKey, signingKeyContext(key.ID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key, status, created_at, activated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`
+
+	err = r.db.QueryRowContext(
+		ctx, query,
+		key.ID, key.Algorithm, sealed, key.Status, time.Now(), key.ActivatedAt,
+	).Scan(&key.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *signingKeyRepository) List(ctx context.Context) ([]*entities.SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key, status, created_at, activated_at, deactivated_at, retired_at
+		FROM signing_keys
+		WHERE status <> $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, entities.SigningKeyRetired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys, unsealed []*entities.SigningKey
+	for rows.Next() {
+		key := &entities.SigningKey{}
+		var stored string
+		if err := rows.Scan(
+			&key.ID, &key.Algorithm, &stored, &key.Status,
+			&key.CreatedAt, &key.ActivatedAt, &key.DeactivatedAt, &key.RetiredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+
+		if crypto.IsSealed(stored) {
+			key.PrivateKey, err = r.box.Open(stored, signingKeyContext(key.ID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt signing key %s: %w", key.ID, err)
+			}
+		} else {
+			key.PrivateKey = stored
+			unsealed = append(unsealed, key)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	for _, key := range unsealed {
+		if err := r.seal(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// seal encrypts a key stored in the clear. The condition on the stored
+// value keeps replicas doing the same from sealing it twice.
+func (r *signingKeyRepository) seal(ctx context.Context, key *entities.SigningKey) error {
+	sealed, err := r.box.Seal(key.PrivateKey, signingKeyContext(key.ID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	query := `UPDATE signing_keys SET private_key = $1 WHERE kid = $2 AND private_key = $3`
+	if _, err := r.db.ExecContext(ctx, query, sealed, key.ID, key.PrivateKey); err != nil {
+		return fmt.Errorf("failed to encrypt signing key %s: %w", key.ID, err)
+	}
+
+	return nil
+}
+
+func (r *signingKeyRepository) Activate(ctx context.Context, kid string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// Demote the current signer first so the partial unique index holds
+	_, err = tx.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET status = $1, deactivated_at = $2
+		WHERE status = $3 AND kid <> $4
+	`, entities.SigningKeyInactive, now, entities.SigningKeyActive, kid)
+	if err != nil {
+		return fmt.Errorf("failed to demote active signing key: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET status = $1, activated_at = $2, deactivated_at = NULL
+		WHERE kid = $3 AND status IN ($4, $5)
+	`, entities.SigningKeyActive, now, kid, entities.SigningKeyStaged, entities.SigningKeyInactive)
+	if err != nil {
+		return fmt.Errorf("failed to activate signing key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("signing key not found")
+	}
+
+	return tx.Commit()
+}
+
+func (r *signingKeyRepository) Retire(ctx context.Context, kid string) error {
+	query := `
+		UPDATE signing_keys
+		SET status = $1, private_key = '', retired_at = $2
+		WHERE kid = $3 AND status = $4
+	`
+
+	result, err := r.db.ExecContext(ctx, query, entities.SigningKeyRetired, time.Now(), kid, entities.SigningKeyInactive)
+	if err != nil {
+		return fmt.Errorf("failed to retire signing key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("signing key not found or not inactive")
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/crypto"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+// totpRepository encrypts secrets before they reach the database. The
+// user's id is bound to the ciphertext so a secret can't be moved between
+// accounts.
+type totpRepository struct {
+	db  *database.DB
+	box *crypto.SecretBox
+}
+
+func NewTOTPRepository(db *database.DB, box *crypto.SecretBox) repositories.TOTPRepository {
+	return &totpRepository{
+		db:  db,
+		box: box,
+	}
+}
+
+func totpSecretContext(userID int) string {
+	return fmt.Sprintf("totp:%d", userID)
+}
+
+func (r *totpRepository) Save(ctx context.Context, credential *entities.TOTPCredential) error {
+	sealed, err := r.box.Seal(credential.Secret, totpSecretContext(credential.UserID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	query := `
+		INSERT INTO totp_credentials (user_id, secret, confirmed_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, confirmed_at = NULL, last_used_step = 0, created_at = EXCLUDED.created_at
+		RETURNING created_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query, credential.UserID, sealed, time.Now()).Scan(&credential.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save TOTP credential: %w", err)
+	}
+
+	credential.ConfirmedAt = nil
+	credential.LastUsedStep = 0
+	return nil
+}
+
+func (r *totpRepository) GetByUserID(ctx context.Context, userID int) (*entities.TOTPCredential, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM totp_credentials
+		WHERE user_id = $1
+	`
+
+	credential := &entities.TOTPCredential{}
+	var sealed string
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&credential.UserID, &sealed, &credential.ConfirmedAt,
+		&credential.LastUsedStep, &credential.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("TOTP credential not found")
+		}
+		return nil, fmt.Errorf("failed to get TOTP credential: %w", err)
+	}
+
+	credential.Secret, err = r.box.Open(sealed, totpSecretContext(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	return credential, nil
+}
+
+func (r *totpRepository) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM totp_credentials WHERE user_id = $1 AND confirmed_at IS NOT NULL)`
+
+	var enabled bool
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to check TOTP credential: %w", err)
+	}
+
+	return enabled, nil
+}
+
+func (r *totpRepository) Confirm(ctx context.Context, userID int) error {
+	query := `UPDATE totp_credentials SET confirmed_at = $2 WHERE user_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to confirm TOTP credential: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("TOTP credential not found")
+	}
+
+	return nil
+}
+
+func (r *totpRepository) MarkUsed(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `
+		UPDATE totp_credentials
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *totpRepository) Delete(ctx context.Context, userID int) error {
+	query := `DELETE FROM totp_credentials WHERE user_id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP credential: %w", err)
+	}
+
+	return nil
+}
@@ -22,15 +22,15 @@ func NewUserRepository(db *database.DB) repositories.UserRepository {
 
 func (r *userRepository) Create(ctx context.Context, user *entities.User) error {
 	query := `
-		INSERT INTO users (username, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (username, email, password, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at
 	`
 
 	now := time.Now()
 	err := r.db.QueryRowContext(
 		ctx, query,
-		user.Username, user.Email, user.Password, now, now,
+		user.Username, user.Email, user.Password, user.EmailVerified, now, now,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
@@ -42,7 +42,7 @@ func (r *userRepository) Create(ctx context.Context, user *entities.User) error
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
 	query := `
-		SELECT id, username, email, password, created_at, updated_at
+		SELECT id, username, email, password, email_verified, disabled_at, failed_logins, locked_until, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -50,7 +50,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entitie
 	user := &entities.User{}
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerified, &user.DisabledAt, &user.FailedLogins, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -65,7 +65,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*entitie
 
 func (r *userRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
 	query := `
-		SELECT id, username, email, password, created_at, updated_at
+		SELECT id, username, email, password, email_verified, disabled_at, failed_logins, locked_until, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -73,7 +73,7 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*entities.User, e
 	user := &entities.User{}
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.EmailVerified, &user.DisabledAt, &user.FailedLogins, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -89,14 +89,14 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*entities.User, e
 func (r *userRepository) Update(ctx context.Context, user *entities.User) error {
 	query := `
 		UPDATE users
-		SET username = $2, email = $3, password = $4, updated_at = $5
+		SET username = $2, email = $3, password = $4, email_verified = $5, disabled_at = $6, updated_at = $7
 		WHERE id = $1
 		RETURNING updated_at
 	`
 
 	err := r.db.QueryRowContext(
 		ctx, query,
-		user.ID, user.Username, user.Email, user.Password, time.Now(),
+		user.ID, user.Username, user.Email, user.Password, user.EmailVerified, user.DisabledAt, time.Now(),
 	).Scan(&user.UpdatedAt)
 
 	if err != nil {
@@ -125,3 +125,40 @@ func (r *userRepository) Delete(ctx context.Context, id int) error {
 
 	return nil
 }
+
+func (r *userRepository) RecordFailedLogin(ctx context.Context, id int) (int, error) {
+	// Incremented in SQL so concurrent attempts are all counted
+	query := `
+		UPDATE users
+		SET failed_logins = failed_logins + 1
+		WHERE id = $1
+		RETURNING failed_logins
+	`
+
+	var failures int
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&failures); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("user not found")
+		}
+		return 0, fmt.Errorf("failed to record failed login: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *userRepository) LockUntil(ctx context.Context, id int, until time.Time) error {
+	query := `UPDATE users SET locked_until = $2 WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id, until); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/repositories"
+	"jwt-auth/internal/infrastructure/database"
+	"time"
+)
+
+type webAuthnCredentialRepository struct {
+	db *database.DB
+}
+
+func NewWebAuthnCredentialRepository(db *database.DB) repositories.WebAuthnCredentialRepository {
+	return &webAuthnCredentialRepository{
+		db: db,
+	}
+}
+
+func (r *webAuthnCredentialRepository) Create(ctx context.Context, credential *entities.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (id, user_id, name, public_key, sign_count, aaguid, attestation_format, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		credential.ID, credential.UserID, credential.Name, credential.PublicKey,
+		int64(credential.SignCount), credential.AAGUID, credential.AttestationFormat, time.Now(),
+	).Scan(&credential.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("passkey is already registered")
+		}
+		return fmt.Errorf("failed to create passkey: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webAuthnCredentialRepository) GetByID(ctx context.Context, id string) (*entities.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, aaguid, attestation_format, last_used_at, created_at
+		FROM webauthn_credentials
+		WHERE id = $1
+	`
+
+	credential, err := scanWebAuthnCredential(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("passkey not found")
+		}
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+
+	return credential, nil
+}
+
+func (r *webAuthnCredentialRepository) ListByUser(ctx context.Context, userID int) ([]*entities.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, aaguid, attestation_format, last_used_at, created_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	credentials := []*entities.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan passkey: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+
+	return credentials, nil
+}
+
+func (r *webAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id string, signCount uint32) (bool, error) {
+	// Authenticators that don't keep a counter always report zero
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, last_used_at = $3
+		WHERE id = $1 AND (sign_count < $2 OR ($2 = 0 AND sign_count = 0))
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, int64(signCount), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *webAuthnCredentialRepository) Delete(ctx context.Context, userID int, id string) error {
+	query := `DELETE FROM webauthn_credentials WHERE user_id = $1 AND id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("passkey not found")
+	}
+
+	return nil
+}
+
+// scanWebAuthnCredential reads a row selected with the columns above
+func scanWebAuthnCredential(row interface{ Scan(...interface{}) error }) (*entities.WebAuthnCredential, error) {
+	credential := &entities.WebAuthnCredential{}
+	var signCount int64
+	err := row.Scan(
+		&credential.ID, &credential.UserID, &credential.Name, &credential.PublicKey, &signCount,
+		&credential.AAGUID, &credential.AttestationFormat, &credential.LastUsedAt, &credential.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	credential.SignCount = uint32(signCount)
+	return credential, nil
+}
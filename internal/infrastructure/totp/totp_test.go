@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors of RFC 6238 appendix B, truncated to 6 digits
+func TestCodeAt_RFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := GenerateCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateCode failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestTOTPService_Validate(t *testing.T) {
+	svc := NewTOTPService("JWT Auth")
+	secret, err := svc.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	code, _ := GenerateCode(secret, now)
+	step, ok := svc.Validate(secret, code, now)
+	if !ok || step != now.Unix()/30 {
+		t.Errorf("expected the current code to validate at step %d, got %d, %v", now.Unix()/30, step, ok)
+	}
+
+	previous, _ := GenerateCode(secret, now.Add(-30*time.Second))
+	if _, ok := svc.Validate(secret, previous, now); !ok {
+		t.Error("expected the previous code to be accepted for clock drift")
+	}
+
+	stale, _ := GenerateCode(secret, now.Add(-2*time.Minute))
+	if _, ok := svc.Validate(secret, stale, now); ok {
+		t.Error("expected a code from two minutes ago to be rejected")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := svc.Validate(secret, bad, now); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestTOTPService_ProvisioningURI(t *testing.T) {
+	svc := NewTOTPService("JWT Auth")
+	uri, err := url.Parse(svc.ProvisioningURI("JBSWY3DPEHPK3PXP", "jane@example.com"))
+	if err != nil {
+		t.Fatalf("invalid URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	if !strings.HasSuffix(uri.Path, "JWT Auth:jane@example.com") {
+		t.Errorf("unexpected label %q", uri.Path)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "JWT Auth" || query.Get("digits") != "6" {
+		t.Errorf("unexpected parameters %v", query)
+	}
+}
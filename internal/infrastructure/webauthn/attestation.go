@@ -0,0 +1,103 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+// idFidoGenCeAAGUID is the certificate extension carrying the authenticator model
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestationStatement checks the attestation statement of a new
+// credential. Only the "none" and "packed" formats are accepted.
+//
+// Attestation certificates are checked for the form the specification
+// requires but are not chained to vendor roots, so attestation proves the
+// authenticator holds the key rather than which model it is.
+func verifyAttestationStatement(format string, statement map[interface{}]interface{}, authData *authenticatorData, key *publicKey, clientDataHash []byte) error {
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return fmt.Errorf("none attestation must have an empty statement")
+		}
+		return nil
+	case "packed":
+		return verifyPackedAttestation(statement, authData, key, clientDataHash)
+	default:
+		return fmt.Errorf("unsupported attestation format %q", format)
+	}
+}
+
+func verifyPackedAttestation(statement map[interface{}]interface{}, authData *authenticatorData, key *publicKey, clientDataHash []byte) error {
+	algorithm, ok := statement["alg"].(int64)
+	if !ok {
+		return fmt.Errorf("packed attestation is missing alg")
+	}
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return fmt.Errorf("packed attestation is missing sig")
+	}
+	signed := append(append([]byte{}, authData.raw...), clientDataHash...)
+
+	chain, hasChain := statement["x5c"].([]interface{})
+	if !hasChain {
+		// Self attestation: the credential key signs its own registration
+		if algorithm != key.algorithm {
+			return fmt.Errorf("self attestation algorithm does not match the credential key")
+		}
+		return key.verify(signed, signature)
+	}
+
+	if len(chain) == 0 {
+		return fmt.Errorf("empty attestation certificate chain")
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return fmt.Errorf("invalid attestation certificate")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("invalid attestation certificate: %w", err)
+	}
+	if err := verifySignature(algorithm, cert.PublicKey, signed, signature); err != nil {
+		return err
+	}
+	return verifyPackedCertificate(cert, authData.aaguid)
+}
+
+// verifyPackedCertificate applies the requirements on packed attestation
+// certificates from WebAuthn §8.2.1
+func verifyPackedCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return fmt.Errorf("attestation certificate must be version 3")
+	}
+	subject := cert.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" {
+		return fmt.Errorf("attestation certificate subject is incomplete")
+	}
+	if len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return fmt.Errorf("attestation certificate has the wrong organizational unit")
+	}
+	if cert.BasicConstraintsValid && cert.IsCA {
+		return fmt.Errorf("attestation certificate must not be a CA")
+	}
+
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		if extension.Critical {
+			return fmt.Errorf("AAGUID extension must not be critical")
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(extension.Value, &value); err != nil {
+			return fmt.Errorf("invalid AAGUID extension: %w", err)
+		}
+		if !bytes.Equal(value, aaguid) {
+			return fmt.Errorf("attestation certificate AAGUID does not match the authenticator")
+		}
+	}
+	return nil
+}
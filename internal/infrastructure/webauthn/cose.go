@@ -0,0 +1,135 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for credentials
+const (
+	algES256 = -7
+	algEdDSA = -8
+	algRS256 = -257
+)
+
+// COSE key parameters
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseRSAN      = -1
+	coseRSAE      = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// publicKey is a credential public key decoded from its COSE encoding
+type publicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key
+func parsePublicKey(data []byte) (*publicKey, error) {
+	value, n, err := decodeCBOR(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("invalid public key: trailing data")
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid public key: not a map")
+	}
+
+	keyType, _ := m[int64(coseKeyType)].(int64)
+	algorithm, _ := m[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == algES256:
+		curve, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("invalid public key: unsupported EC2 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid public key: point is not on the curve")
+		}
+		return &publicKey{algorithm: algorithm, key: key}, nil
+
+	case keyType == coseKeyTypeOKP && algorithm == algEdDSA:
+		curve, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key: unsupported OKP key")
+		}
+		return &publicKey{algorithm: algorithm, key: ed25519.PublicKey(x)}, nil
+
+	case keyType == coseKeyTypeRSA && algorithm == algRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid public key: unsupported RSA key")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &publicKey{algorithm: algorithm, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported public key type %d with algorithm %d", keyType, algorithm)
+	}
+}
+
+// verify checks a signature over message made with the key's algorithm
+func (k *publicKey) verify(message, signature []byte) error {
+	return verifySignature(k.algorithm, k.key, message, signature)
+}
+
+func verifySignature(algorithm int64, key crypto.PublicKey, message, signature []byte) error {
+	switch algorithm {
+	case algES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm")
+		}
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(ecKey, digest[:], signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case algEdDSA:
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm")
+		}
+		if !ed25519.Verify(edKey, message, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case algRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm")
+		}
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %d", algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"jwt-auth/internal/domain/entities"
+	"jwt-auth/internal/domain/services"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+	flagExtensionData    = 0x80
+)
+
+type Config struct {
+	// RPID is the domain credentials are scoped to, e.g. "example.com"
+	RPID   string
+	RPName string
+	// Origins are the web origins allowed to run ceremonies, e.g. "https://app.example.com"
+	Origins []string
+}
+
+// WebAuthnServiceImpl verifies registration (attestation) and
+// authentication (assertion) ceremonies as described in WebAuthn Level 2
+type WebAuthnServiceImpl struct {
+	config   *Config
+	rpIDHash [32]byte
+}
+
+func NewWebAuthnService(config *Config) (services.WebAuthnService, error) {
+	if config.RPID == "" {
+		return nil, fmt.Errorf("relying party ID is required")
+	}
+	if len(config.Origins) == 0 {
+		return nil, fmt.Errorf("at least one allowed origin is required")
+	}
+	return &WebAuthnServiceImpl{
+		config:   config,
+		rpIDHash: sha256.Sum256([]byte(config.RPID)),
+	}, nil
+}
+
+func (s *WebAuthnServiceImpl) RelyingParty() (string, string) {
+	return s.config.RPID, s.config.RPName
+}
+
+// clientData is the JSON the browser signs over
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (s *WebAuthnServiceImpl) Challenge(clientDataJSON []byte) (string, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return "", fmt.Errorf("invalid client data: %w", err)
+	}
+	if data.Challenge == "" {
+		return "", fmt.Errorf("invalid client data: missing challenge")
+	}
+	return data.Challenge, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin
+func (s *WebAuthnServiceImpl) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", data.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return fmt.Errorf("challenge mismatch")
+	}
+	for _, origin := range s.config.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", data.Origin)
+}
+
+// authenticatorData is the parsed authenticator data structure
+type authenticatorData struct {
+	raw       []byte
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// Only present in registrations
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("authenticator data too short")
+	}
+	authData := &authenticatorData{
+		raw:       data,
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rest := data[37:]
+	if authData.flags&flagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("attested credential data too short")
+		}
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("invalid credential ID length")
+		}
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		authData.publicKey = rest[:n]
+		rest = rest[n:]
+	}
+	if authData.flags&flagExtensionData != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %w", err)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing bytes in authenticator data")
+	}
+	return authData, nil
+}
+
+// verifyAuthenticatorData checks the RP ID hash and the user presence flag
+func (s *WebAuthnServiceImpl) verifyAuthenticatorData(authData *authenticatorData) error {
+	if subtle.ConstantTimeCompare(authData.rpIDHash, s.rpIDHash[:]) != 1 {
+		return fmt.Errorf("credential is not scoped to %s", s.config.RPID)
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return fmt.Errorf("user was not present")
+	}
+	return nil
+}
+
+func (s *WebAuthnServiceImpl) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*entities.WebAuthnCredential, error) {
+	if err := s.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	value, n, err := decodeCBOR(attestationObject)
+	if err != nil || n != len(attestationObject) {
+		return nil, fmt.Errorf("invalid attestation object")
+	}
+	object, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid attestation object")
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, fmt.Errorf("invalid attestation object")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("attestation has no credential")
+	}
+	key, err := parsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestationStatement(format, statement, authData, key, clientDataHash[:]); err != nil {
+		return nil, fmt.Errorf("attestation verification failed: %w", err)
+	}
+
+	return &entities.WebAuthnCredential{
+		ID:                base64.RawURLEncoding.EncodeToString(authData.credentialID),
+		PublicKey:         authData.publicKey,
+		SignCount:         authData.signCount,
+		AAGUID:            hex.EncodeToString(authData.aaguid),
+		AttestationFormat: format,
+	}, nil
+}
+
+func (s *WebAuthnServiceImpl) VerifyAssertion(challenge string, credential *entities.WebAuthnCredential, clientDataJSON, rawAuthData, signature []byte) (*services.WebAuthnAssertion, error) {
+	if err := s.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.verify(append(append([]byte{}, rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	return &services.WebAuthnAssertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
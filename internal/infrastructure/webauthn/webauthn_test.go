@@ -0,0 +1,181 @@
+package webauthn
+
+import (
+	"bytes"
+	"jwt-auth/internal/infrastructure/webauthn/webauthntest"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+func newTestService(t *testing.T) *WebAuthnServiceImpl {
+	t.Helper()
+	svc, err := NewWebAuthnService(&Config{
+		RPID:    testRPID,
+		RPName:  "Example",
+		Origins: []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatalf("NewWebAuthnService failed: %v", err)
+	}
+	return svc.(*WebAuthnServiceImpl)
+}
+
+func TestVerifyRegistration_Formats(t *testing.T) {
+	svc := newTestService(t)
+
+	tests := []struct {
+		name        string
+		format      string
+		certificate bool
+	}{
+		{"none", webauthntest.FormatNone, false},
+		{"packed self attestation", webauthntest.FormatPacked, false},
+		{"packed with certificate", webauthntest.FormatPacked, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+			authenticator.Format = tt.format
+			authenticator.AAGUID = [16]byte{1, 2, 3}
+			if tt.certificate {
+				if err := authenticator.UseAttestationCertificate(); err != nil {
+					t.Fatalf("UseAttestationCertificate failed: %v", err)
+				}
+			}
+
+			attestation, err := authenticator.Register("registration-challenge", []byte("1"))
+			if err != nil {
+				t.Fatalf("Register failed: %v", err)
+			}
+			challenge, err := svc.Challenge(attestation.ClientDataJSON)
+			if err != nil || challenge != "registration-challenge" {
+				t.Fatalf("Challenge = %q, %v", challenge, err)
+			}
+
+			credential, err := svc.VerifyRegistration("registration-challenge", attestation.ClientDataJSON, attestation.AttestationObject)
+			if err != nil {
+				t.Fatalf("VerifyRegistration failed: %v", err)
+			}
+			if credential.ID != attestation.CredentialID || credential.AttestationFormat != tt.format {
+				t.Errorf("unexpected credential %+v", credential)
+			}
+			if credential.AAGUID != "01020300000000000000000000000000" {
+				t.Errorf("unexpected AAGUID %s", credential.AAGUID)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistration_Rejects(t *testing.T) {
+	svc := newTestService(t)
+
+	register := func(authenticator *webauthntest.Authenticator) *webauthntest.Attestation {
+		attestation, err := authenticator.Register("challenge", []byte("1"))
+		if err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		return attestation
+	}
+
+	t.Run("Wrong challenge", func(t *testing.T) {
+		attestation := register(webauthntest.NewAuthenticator(testRPID, testOrigin))
+		if _, err := svc.VerifyRegistration("other", attestation.ClientDataJSON, attestation.AttestationObject); err == nil {
+			t.Error("expected a mismatched challenge to be rejected")
+		}
+	})
+
+	t.Run("Wrong origin", func(t *testing.T) {
+		attestation := register(webauthntest.NewAuthenticator(testRPID, "https://evil.example.net"))
+		if _, err := svc.VerifyRegistration("challenge", attestation.ClientDataJSON, attestation.AttestationObject); err == nil {
+			t.Error("expected an unknown origin to be rejected")
+		}
+	})
+
+	t.Run("Wrong RP ID", func(t *testing.T) {
+		attestation := register(webauthntest.NewAuthenticator("evil.example.net", testOrigin))
+		if _, err := svc.VerifyRegistration("challenge", attestation.ClientDataJSON, attestation.AttestationObject); err == nil {
+			t.Error("expected a credential for another RP to be rejected")
+		}
+	})
+
+	t.Run("Tampered packed attestation", func(t *testing.T) {
+		authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+		authenticator.Format = webauthntest.FormatPacked
+		attestation := register(authenticator)
+		// Change the client data the attestation signed
+		tampered := bytes.Replace(attestation.ClientDataJSON, []byte(`"crossOrigin":false`), []byte(`"crossOrigin":true `), 1)
+		if _, err := svc.VerifyRegistration("challenge", tampered, attestation.AttestationObject); err == nil {
+			t.Error("expected a tampered attestation to be rejected")
+		}
+	})
+
+	t.Run("Truncated attestation object", func(t *testing.T) {
+		attestation := register(webauthntest.NewAuthenticator(testRPID, testOrigin))
+		object := attestation.AttestationObject[:len(attestation.AttestationObject)-10]
+		if _, err := svc.VerifyRegistration("challenge", attestation.ClientDataJSON, object); err == nil {
+			t.Error("expected a truncated attestation object to be rejected")
+		}
+	})
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	svc := newTestService(t)
+	authenticator := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	attestation, _ := authenticator.Register("challenge", []byte("1"))
+	credential, err := svc.VerifyRegistration("challenge", attestation.ClientDataJSON, attestation.AttestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration failed: %v", err)
+	}
+
+	assertion, _ := authenticator.Assert("login-challenge", credential.ID)
+	result, err := svc.VerifyAssertion("login-challenge", credential, assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature)
+	if err != nil {
+		t.Fatalf("VerifyAssertion failed: %v", err)
+	}
+	if result.SignCount != 1 || !result.UserVerified {
+		t.Errorf("unexpected assertion %+v", result)
+	}
+
+	if _, err := svc.VerifyAssertion("other", credential, assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature); err == nil {
+		t.Error("expected a mismatched challenge to be rejected")
+	}
+
+	signature := append([]byte{}, assertion.Signature...)
+	signature[len(signature)-1] ^= 0xff
+	if _, err := svc.VerifyAssertion("login-challenge", credential, assertion.ClientDataJSON, assertion.AuthenticatorData, signature); err == nil {
+		t.Error("expected a bad signature to be rejected")
+	}
+
+	// A registration response can't be replayed as a login
+	if _, err := svc.VerifyAssertion("challenge", credential, attestation.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature); err == nil {
+		t.Error("expected create client data to be rejected for an assertion")
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	value, n, err := decodeCBOR([]byte{0xa2, 0x01, 0x02, 0x20, 0x43, 'a', 'b', 'c', 0xff})
+	if err != nil || n != 8 {
+		t.Fatalf("decodeCBOR = %v, %d, %v", value, n, err)
+	}
+	m := value.(map[interface{}]interface{})
+	if m[int64(1)] != int64(2) || !bytes.Equal(m[int64(-1)].([]byte), []byte("abc")) {
+		t.Errorf("unexpected map %v", m)
+	}
+
+	invalid := [][]byte{
+		{},
+		{0x5f},      // indefinite length byte string
+		{0x43, 'a'}, // truncated byte string
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // huge array
+		{0xa2, 0x01, 0x01, 0x01, 0x02},                         // duplicate key
+	}
+	for _, data := range invalid {
+		if _, _, err := decodeCBOR(data); err == nil {
+			t.Errorf("expected % x to be rejected", data)
+		}
+	}
+}
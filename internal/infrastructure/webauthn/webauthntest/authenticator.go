@@ -0,0 +1,247 @@
+// Package webauthntest provides a software authenticator for testing
+// WebAuthn ceremonies without a browser or security key.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Attestation formats the authenticator can produce
+const (
+	FormatNone = "none"
+	// FormatPacked signs with the attestation certificate when one is set,
+	// and with the credential key (self attestation) otherwise
+	FormatPacked = "packed"
+)
+
+// Authenticator creates ES256 credentials and signs with them like a
+// security key or platform authenticator would
+type Authenticator struct {
+	RPID   string
+	Origin string
+	Format string
+	AAGUID [16]byte
+	// UserVerified sets the UV flag, as if a PIN or biometric was checked
+	UserVerified bool
+
+	attestationKey  *ecdsa.PrivateKey
+	attestationCert []byte
+	credentials     []*credential
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// Attestation is the authenticator's response to a registration
+type Attestation struct {
+	CredentialID      string
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// Assertion is the authenticator's response to an authentication
+type Assertion struct {
+	CredentialID      string
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+func NewAuthenticator(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Format:       FormatNone,
+		UserVerified: true,
+	}
+}
+
+// UseAttestationCertificate makes packed attestations carry a certificate
+// chain instead of being self-attested
+func (a *Authenticator) UseAttestationCertificate() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	aaguid, err := asn1.Marshal(a.AAGUID[:])
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Test Authenticators"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}, Value: aaguid},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	a.attestationKey = key
+	a.attestationCert = der
+	return nil
+}
+
+// Register creates a credential for the user in response to a
+// navigator.credentials.create() challenge
+func (a *Authenticator) Register(challenge string, userHandle []byte) (*Attestation, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, key: key, userHandle: userHandle}
+
+	clientDataJSON := a.clientData("webauthn.create", challenge)
+	authData := a.authenticatorData(cred.signCount)
+	authData = append(authData, a.AAGUID[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, encodeCBOR(coseKey(&key.PublicKey))...)
+	authData[32] |= 0x40
+
+	statement := map[interface{}]interface{}{}
+	if a.Format == FormatPacked {
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		signer := key
+		if a.attestationKey != nil {
+			signer = a.attestationKey
+			statement["x5c"] = []interface{}{a.attestationCert}
+		}
+		sig, err := sign(signer, append(append([]byte{}, authData...), clientDataHash[:]...))
+		if err != nil {
+			return nil, err
+		}
+		statement["alg"] = -7
+		statement["sig"] = sig
+	}
+
+	a.credentials = append(a.credentials, cred)
+	return &Attestation{
+		CredentialID:   base64.RawURLEncoding.EncodeToString(id),
+		ClientDataJSON: clientDataJSON,
+		AttestationObject: encodeCBOR(map[interface{}]interface{}{
+			"fmt":      a.Format,
+			"attStmt":  statement,
+			"authData": authData,
+		}),
+	}, nil
+}
+
+// Assert signs a navigator.credentials.get() challenge with the credential,
+// or with the most recent credential when credentialID is empty (a
+// discoverable login)
+func (a *Authenticator) Assert(challenge, credentialID string) (*Assertion, error) {
+	cred := a.credential(credentialID)
+	if cred == nil {
+		return nil, fmt.Errorf("no such credential")
+	}
+	cred.signCount++
+
+	clientDataJSON := a.clientData("webauthn.get", challenge)
+	authData := a.authenticatorData(cred.signCount)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	sig, err := sign(cred.key, append(append([]byte{}, authData...), clientDataHash[:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		CredentialID:      base64.RawURLEncoding.EncodeToString(cred.id),
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         sig,
+		UserHandle:        cred.userHandle,
+	}, nil
+}
+
+// SetSignCount rewinds or advances a credential's counter, e.g. to act
+// like a cloned authenticator
+func (a *Authenticator) SetSignCount(credentialID string, count uint32) {
+	if cred := a.credential(credentialID); cred != nil {
+		cred.signCount = count
+	}
+}
+
+func (a *Authenticator) credential(id string) *credential {
+	if len(a.credentials) == 0 {
+		return nil
+	}
+	if id == "" {
+		return a.credentials[len(a.credentials)-1]
+	}
+	for _, cred := range a.credentials {
+		if base64.RawURLEncoding.EncodeToString(cred.id) == id {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return data
+}
+
+func (a *Authenticator) authenticatorData(signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags := byte(0x01)
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func coseKey(key *ecdsa.PublicKey) map[interface{}]interface{} {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return map[interface{}]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: x,
+		-3: y,
+	}
+}
+
+func sign(key *ecdsa.PrivateKey, message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
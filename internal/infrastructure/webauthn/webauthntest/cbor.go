@@ -0,0 +1,65 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// encodeCBOR encodes the values authenticators produce: integers, byte and
+// text strings, arrays and maps
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		return encodeInt(int64(v))
+	case int64:
+		return encodeInt(v)
+	case []byte:
+		return append(encodeHead(2, uint64(len(v))), v...)
+	case string:
+		return append(encodeHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := encodeHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := encodeHead(5, uint64(len(v)))
+		for key, item := range v {
+			out = append(out, encodeCBOR(key)...)
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	default:
+		panic(fmt.Sprintf("cbor: unsupported type %T", value))
+	}
+}
+
+func encodeInt(v int64) []byte {
+	if v < 0 {
+		return encodeHead(1, uint64(-1-v))
+	}
+	return encodeHead(0, uint64(v))
+}
+
+func encodeHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		out := []byte{major | 25, 0, 0}
+		binary.BigEndian.PutUint16(out[1:], uint16(arg))
+		return out
+	case arg <= 0xffffffff:
+		out := []byte{major | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(out[1:], uint32(arg))
+		return out
+	default:
+		out := []byte{major | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(out[1:], arg)
+		return out
+	}
+}
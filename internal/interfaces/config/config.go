@@ -4,20 +4,29 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	Auth      AuthConfig
+	SMTP      SMTPConfig
+	Outbox    OutboxConfig
+	WebAuthn  WebAuthnConfig
+	RateLimit RateLimitConfig
+	Redis     RedisConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
+	// TrustedProxies may set the client IP with X-Forwarded-For
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -27,12 +36,126 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// AutoMigrate applies pending migrations on startup
+	AutoMigrate bool
 }
 
 type JWTConfig struct {
+	Algorithm          string
 	SecretKey          string
+	PrivateKeyPath     string
+	KeyID              string
 	AccessTokenExpiry  time.Duration
 	RefreshTokenExpiry time.Duration
+	// KeyringRefreshInterval is how often each replica reloads rotated keys
+	KeyringRefreshInterval time.Duration
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+	TLSMode  string
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	Timeout time.Duration
+}
+
+type RedisConfig struct {
+	Host     string
+	Port     string
+	Password string
+	// BreakerThreshold consecutive failures stop calls to Redis for
+	// BreakerCooldown, after which one call probes it again
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RateLimitMode and BlacklistMode choose what rate limiting and the
+	// token blacklist do while Redis is unavailable: "fallback" to an
+	// in-process store of up to FallbackSize entries, "open" or "closed"
+	RateLimitMode string
+	BlacklistMode string
+	FallbackSize  int
+}
+
+type RateLimitConfig struct {
+	// Limit requests per Period is each client IP's budget on the auth
+	// routes without a policy of their own
+	Limit     int
+	Period    time.Duration
+	Algorithm string
+	// Policies are "ROUTE KEY LIMIT/PERIOD [ALGORITHM]" entries; those
+	// from RATE_LIMIT_POLICIES override the defaults for the same route
+	Policies []string
+	// Allowlist networks (e.g., internal ones) are never throttled
+	Allowlist []string
+}
+
+// defaultRateLimitPolicies give the sensitive auth routes their own budgets
+var defaultRateLimitPolicies = []string{
+	"/api/v1/auth/register ip 10/1h",
+	"/api/v1/auth/login ip 30/15m",
+	"/api/v1/auth/login account 10/15m sliding_log",
+	"/api/v1/auth/refresh ip 60/1m token_bucket",
+	"/api/v1/auth/forgot-password ip 10/1h",
+	"/api/v1/auth/forgot-password account 10/15m sliding_log",
+	"/api/v1/auth/reset-password ip 10/1h",
+	"/api/v1/auth/mfa/verify account 10/15m sliding_log",
+	"/oauth/authorize account 10/15m sliding_log",
+	"/oauth/token ip 60/1m token_bucket",
+	"/oauth/device ip 20/15m sliding_log",
+	"/oauth/device account 10/15m sliding_log",
+}
+
+type AuthConfig struct {
+	PasswordResetURL          string
+	PasswordResetExpiry       time.Duration
+	EmailVerificationURL      string
+	EmailVerificationSecret   string
+	EmailVerificationExpiry   time.Duration
+	EmailVerificationCooldown time.Duration
+	RequireEmailVerification  bool
+	MFAIssuer                 string
+	MFAChallengeExpiry        time.Duration
+	// EncryptionKey encrypts TOTP secrets and signing keys at rest (base64,
+	// 32 bytes). It has no default.
+	EncryptionKey   string
+	MagicLinkURL    string
+	MagicLinkExpiry time.Duration
+	EmailOTPExpiry  time.Duration
+	// EmailOTPMaxAttempts wrong codes within EmailOTPLockout lock the
+	// address or action for EmailOTPLockout
+	EmailOTPMaxAttempts int
+	EmailOTPLockout     time.Duration
+	// LockoutThreshold failed logins lock the account, starting at
+	// LockoutBaseDuration and doubling up to LockoutMaxDuration
+	LockoutThreshold    int
+	LockoutBaseDuration time.Duration
+	LockoutMaxDuration  time.Duration
+	AccountUnlockURL    string
+	AccountUnlockExpiry time.Duration
+	// DefaultRole is assigned to every new user; "none" assigns none
+	DefaultRole string
+	// Scopes are the scopes of a full user token
+	Scopes          []string
+	OAuthCodeExpiry time.Duration
+	// DeviceCodeExpiry and DevicePollInterval govern the device flow
+	DeviceCodeExpiry   time.Duration
+	DevicePollInterval time.Duration
+	// Issuer is the public base URL of the server, the iss of ID tokens
+	Issuer string
 }
 
 func LoadConfig() *Config {
@@ -41,23 +164,100 @@ func LoadConfig() *Config {
 		log.Println("No .env file found, using environment variables")
 	}
 
+	jwtSecret := getEnv("JWT_SECRET", "feh5tpb9aYtPxbCAxRKHZU967WyH3yjE")
+	defaultRole := getEnv("DEFAULT_ROLE", "user")
+	if defaultRole == "none" {
+		defaultRole = ""
+	}
+
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			TrustedProxies: getListEnv("TRUSTED_PROXIES", nil),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "Pa$sw0rd123"),
-			DBName:   getEnv("DB_NAME", "jwt_auth"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        getEnv("DB_PORT", "5432"),
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", "Pa$sw0rd123"),
+			DBName:      getEnv("DB_NAME", "jwt_auth"),
+			SSLMode:     getEnv("DB_SSLMODE", "disable"),
+			AutoMigrate: getBoolEnv("DB_AUTO_MIGRATE", true),
 		},
 		JWT: JWTConfig{
-			SecretKey:          getEnv("JWT_SECRET", "feh5tpb9aYtPxbCAxRKHZU967WyH3yjE"),
-			AccessTokenExpiry:  getDurationEnv("JWT_ACCESS_EXPIRY", time.Hour),
-			RefreshTokenExpiry: getDurationEnv("JWT_REFRESH_EXPIRY", 24*time.Hour),
+			Algorithm:              getEnv("JWT_ALGORITHM", "HS256"),
+			SecretKey:              getEnv("JWT_SECRET", "feh5tpb9aYtPxbCAxRKHZU967WyH3yjE"),
+			PrivateKeyPath:         getEnv("JWT_PRIVATE_KEY_PATH", ""),
+			KeyID:                  getEnv("JWT_KEY_ID", ""),
+			AccessTokenExpiry:      getDurationEnv("JWT_ACCESS_EXPIRY", time.Hour),
+			RefreshTokenExpiry:     getDurationEnv("JWT_REFRESH_EXPIRY", 24*time.Hour),
+			KeyringRefreshInterval: getDurationEnv("JWT_KEYRING_REFRESH_INTERVAL", time.Minute),
+		},
+		Auth: AuthConfig{
+			PasswordResetURL:          getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+			PasswordResetExpiry:       getDurationEnv("PASSWORD_RESET_EXPIRY", 30*time.Minute),
+			EmailVerificationURL:      getEnv("EMAIL_VERIFICATION_URL", "http://localhost:8080/api/v1/auth/verify-email"),
+			EmailVerificationSecret:   getEnv("EMAIL_VERIFICATION_SECRET", jwtSecret),
+			EmailVerificationExpiry:   getDurationEnv("EMAIL_VERIFICATION_EXPIRY", 24*time.Hour),
+			EmailVerificationCooldown: getDurationEnv("EMAIL_VERIFICATION_COOLDOWN", time.Minute),
+			RequireEmailVerification:  getBoolEnv("REQUIRE_EMAIL_VERIFICATION", false),
+			MFAIssuer:                 getEnv("MFA_ISSUER", "JWT Auth"),
+			MFAChallengeExpiry:        getDurationEnv("MFA_CHALLENGE_EXPIRY", 5*time.Minute),
+			EncryptionKey:             getEnv("ENCRYPTION_KEY", getEnv("MFA_ENCRYPTION_KEY", "")),
+			MagicLinkURL:              getEnv("MAGIC_LINK_URL", "http://localhost:3000/magic-link"),
+			MagicLinkExpiry:           getDurationEnv("MAGIC_LINK_EXPIRY", 15*time.Minute),
+			EmailOTPExpiry:            getDurationEnv("EMAIL_OTP_EXPIRY", 10*time.Minute),
+			EmailOTPMaxAttempts:       getIntEnv("EMAIL_OTP_MAX_ATTEMPTS", 5),
+			EmailOTPLockout:           getDurationEnv("EMAIL_OTP_LOCKOUT", 15*time.Minute),
+			LockoutThreshold:          getIntEnv("LOCKOUT_THRESHOLD", 5),
+			LockoutBaseDuration:       getDurationEnv("LOCKOUT_BASE_DURATION", time.Minute),
+			LockoutMaxDuration:        getDurationEnv("LOCKOUT_MAX_DURATION", time.Hour),
+			AccountUnlockURL:          getEnv("ACCOUNT_UNLOCK_URL", "http://localhost:8080/api/v1/auth/unlock"),
+			AccountUnlockExpiry:       getDurationEnv("ACCOUNT_UNLOCK_EXPIRY", 24*time.Hour),
+			DefaultRole:               defaultRole,
+			Scopes:                    getListEnv("TOKEN_SCOPES", []string{"profile", "account", "admin"}),
+			OAuthCodeExpiry:           getDurationEnv("OAUTH_CODE_EXPIRY", time.Minute),
+			DeviceCodeExpiry:          getDurationEnv("OAUTH_DEVICE_CODE_EXPIRY", 10*time.Minute),
+			DevicePollInterval:        getDurationEnv("OAUTH_DEVICE_POLL_INTERVAL", 5*time.Second),
+			Issuer:                    strings.TrimSuffix(getEnv("OIDC_ISSUER", "http://localhost:8080"), "/"),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnv("SMTP_PORT", "587"),
+			User:     getEnv("SMTP_USER", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "no-reply@localhost"),
+			TLSMode:  getEnv("SMTP_TLS", "starttls"),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", getEnv("MFA_ISSUER", "JWT Auth")),
+			Origins: getListEnv("WEBAUTHN_ORIGINS", []string{"http://localhost:3000"}),
+			Timeout: getDurationEnv("WEBAUTHN_TIMEOUT", 5*time.Minute),
+		},
+		Redis: RedisConfig{
+			Host:             getEnv("REDIS_HOST", "localhost"),
+			Port:             getEnv("REDIS_PORT", "6379"),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			BreakerThreshold: getIntEnv("REDIS_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getDurationEnv("REDIS_BREAKER_COOLDOWN", 10*time.Second),
+			RateLimitMode:    getEnv("REDIS_RATE_LIMIT_MODE", "fallback"),
+			BlacklistMode:    getEnv("REDIS_BLACKLIST_MODE", "fallback"),
+			FallbackSize:     getIntEnv("REDIS_FALLBACK_SIZE", 100000),
+		},
+		RateLimit: RateLimitConfig{
+			Limit:     getIntEnv("RATE_LIMIT", 100),
+			Period:    getSecondsEnv("RATE_LIMIT_PERIOD", time.Minute),
+			Algorithm: getEnv("RATE_LIMIT_ALGORITHM", "sliding_window"),
+			Policies:  append(defaultRateLimitPolicies, getListEnv("RATE_LIMIT_POLICIES", nil)...),
+			Allowlist: getListEnv("RATE_LIMIT_ALLOWLIST", nil),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: getDurationEnv("EMAIL_OUTBOX_POLL_INTERVAL", 5*time.Second),
+			MaxAttempts:  getIntEnv("EMAIL_OUTBOX_MAX_ATTEMPTS", 8),
+			BaseBackoff:  getDurationEnv("EMAIL_OUTBOX_BASE_BACKOFF", 30*time.Second),
+			MaxBackoff:   getDurationEnv("EMAIL_OUTBOX_MAX_BACKOFF", time.Hour),
 		},
 	}
 }
@@ -80,3 +280,49 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getSecondsEnv reads a duration, or a number of seconds
+func getSecondsEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultValue
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv reads a comma-separated list
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/interfaces/http/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler serves the signed-in user's sensitive account changes,
+// each guarded by a step-up code emailed to the user
+type AccountHandler struct {
+	authService services.AuthService
+}
+
+func NewAccountHandler(authService services.AuthService) *AccountHandler {
+	return &AccountHandler{
+		authService: authService,
+	}
+}
+
+// StepUp emails a code for the requested action
+func (h *AccountHandler) StepUp(c *gin.Context) {
+	var req dto.StepUpRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	err := h.authService.RequestStepUpCode(c.Request.Context(), c.GetInt("user_id"), req.Action)
+	if errors.Is(err, services.ErrTooManyAttempts) {
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "step_up_failed",
+			Message: "Failed to send verification code",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "A verification code has been sent to your email",
+	})
+}
+
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	err := h.authService.ChangePassword(c.Request.Context(), c.GetInt("user_id"), req.Code, req.NewPassword)
+	if respondStepUpError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "password_change_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Password changed; please sign in again",
+	})
+}
+
+func (h *AccountHandler) ChangeEmail(c *gin.Context) {
+	var req dto.ChangeEmailRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	err := h.authService.ChangeEmail(c.Request.Context(), c.GetInt("user_id"), req.Code, req.Email)
+	if respondStepUpError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "email_change_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Email changed; check your inbox to verify the new address",
+	})
+}
+
+// respondStepUpError answers for a rejected step-up code
+func respondStepUpError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "invalid_code",
+			Message: err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
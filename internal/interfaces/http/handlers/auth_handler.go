@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"jwt-auth/internal/application/dto"
 	"jwt-auth/internal/domain/services"
 	"jwt-auth/internal/interfaces/http/middleware"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -47,6 +50,37 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	response, err := h.authService.Login(c.Request.Context(), &req)
+	// The password was right; the client must now call /auth/mfa/verify
+	if respondMFAChallenge(c, err) {
+		return
+	}
+	if respondInvalidScope(c, err) {
+		return
+	}
+	var locked *services.AccountLockedError
+	if errors.As(err, &locked) {
+		// 423 tells clients apart from a wrong password; retrying won't help until then
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+		c.JSON(http.StatusLocked, dto.ErrorResponse{
+			Error:   "account_locked",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "account_disabled",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "email_not_verified",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error:   "login_failed",
@@ -58,9 +92,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// respondInvalidScope answers for a scope request that can't be granted,
+// with the OAuth 2.0 error code
+func respondInvalidScope(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrInvalidScope) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		Error:   "invalid_scope",
+		Message: err.Error(),
+	})
+	return true
+}
+
+// respondMFAChallenge answers with the MFA challenge if err is one
+func respondMFAChallenge(c *gin.Context, err error) bool {
+	var challenge *services.MFAChallengeError
+	if !errors.As(err, &challenge) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, dto.MFAChallengeResponse{
+		Error:     "mfa_required",
+		Message:   err.Error(),
+		MFAToken:  challenge.Token,
+		Methods:   challenge.Methods,
+		ExpiresIn: challenge.ExpiresIn,
+	})
+	return true
+}
+
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
+		Scope        string `json:"scope"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -71,7 +135,10 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, req.Scope)
+	if respondInvalidScope(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error:   "refresh_failed",
@@ -144,8 +211,9 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
+	// Same answer whether or not the account exists
 	c.JSON(http.StatusOK, dto.SuccessResponse{
-		Message: "Password reset email sent",
+		Message: "If an account exists for this email, a password reset link has been sent",
 	})
 }
 
@@ -194,3 +262,177 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 		Message: "Email verified successfully",
 	})
 }
+
+// UnlockAccount lifts a lockout with the link emailed when it started
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	if err := h.authService.UnlockAccount(c.Request.Context(), c.Param("token")); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "unlock_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Account unlocked",
+	})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	err := h.authService.ResendVerificationEmail(c.Request.Context(), req.Email)
+	if errors.Is(err, services.ErrCooldownActive) {
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "cooldown_active",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "verification_failed",
+			Message: "Failed to send verification email",
+		})
+		return
+	}
+
+	// Same answer whether or not the account exists
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "If an unverified account exists for this email, a verification link has been sent",
+	})
+}
+
+// magicLinkCookie holds the magic link nonce in the browser that asked for the link
+const magicLinkCookie = "magic_link_nonce"
+
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req dto.MagicLinkRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	nonce, err := h.authService.RequestMagicLink(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "magic_link_failed",
+			Message: "Failed to send magic link",
+		})
+		return
+	}
+
+	setMagicLinkCookie(c, nonce, 0)
+	// Same answer whether or not the account exists
+	c.JSON(http.StatusOK, dto.MagicLinkResponse{
+		Message: "If an account exists for this email, a sign-in link has been sent",
+		Nonce:   nonce,
+	})
+}
+
+func (h *AuthHandler) RedeemMagicLink(c *gin.Context) {
+	var req dto.RedeemMagicLinkRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+	if req.Nonce == "" {
+		req.Nonce, _ = c.Cookie(magicLinkCookie)
+	}
+
+	response, err := h.authService.RedeemMagicLink(c.Request.Context(), req.Token, req.Nonce)
+	if err == nil || errors.Is(err, services.ErrMFARequired) {
+		// The nonce has served its purpose
+		setMagicLinkCookie(c, "", -1)
+	}
+	if respondMFAChallenge(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "account_disabled",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error:   "magic_link_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// setMagicLinkCookie stores the nonce for the lifetime of the browser
+// session; a negative maxAge removes it
+func setMagicLinkCookie(c *gin.Context, nonce string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicLinkCookie, nonce, maxAge, "/api/v1/auth/magic-link", "", secure, true)
+}
+
+func (h *AuthHandler) RequestLoginCode(c *gin.Context) {
+	var req dto.EmailCodeRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	if err := h.authService.RequestLoginCode(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "login_code_failed",
+			Message: "Failed to send sign-in code",
+		})
+		return
+	}
+
+	// Same answer whether or not the account exists
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "If an account exists for this email, a sign-in code has been sent",
+	})
+}
+
+func (h *AuthHandler) LoginWithCode(c *gin.Context) {
+	var req dto.EmailCodeLoginRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	response, err := h.authService.LoginWithCode(c.Request.Context(), req.Email, req.Code)
+	if respondMFAChallenge(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrTooManyAttempts) {
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "account_disabled",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error:   "login_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"jwt-auth/internal/domain/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailQueueHandler struct {
+	emailQueue services.EmailQueue
+}
+
+func NewEmailQueueHandler(emailQueue services.EmailQueue) *EmailQueueHandler {
+	return &EmailQueueHandler{
+		emailQueue: emailQueue,
+	}
+}
+
+// Stats reports how many emails are waiting, delivered and dead so that
+// operators can alert on a growing backlog. Only counts are exposed.
+func (h *EmailQueueHandler) Stats(c *gin.Context) {
+	stats, err := h.emailQueue.Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "email_queue_unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
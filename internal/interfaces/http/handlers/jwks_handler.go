@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"jwt-auth/internal/domain/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwtManager services.JWTManager
+}
+
+func NewJWKSHandler(jwtManager services.JWTManager) *JWKSHandler {
+	return &JWKSHandler{
+		jwtManager: jwtManager,
+	}
+}
+
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	// Allow verifiers to cache the key set, but not for so long that they
+	// miss a newly published key
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/interfaces/http/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	authService services.AuthService
+}
+
+func NewMFAHandler(authService services.AuthService) *MFAHandler {
+	return &MFAHandler{
+		authService: authService,
+	}
+}
+
+// Verify completes a login that was answered with mfa_required
+func (h *MFAHandler) Verify(c *gin.Context) {
+	var req dto.VerifyMFARequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	response, err := h.authService.VerifyMFA(c.Request.Context(), req.MFAToken, req.Code)
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "account_disabled",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error:   "mfa_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollTOTP returns a new secret and the otpauth:// URI to show as a QR code
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	response, err := h.authService.EnrollTOTP(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "mfa_enrollment_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmTOTP turns on two-factor authentication and returns the recovery codes
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req dto.MFACodeRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	response, err := h.authService.ConfirmTOTP(c.Request.Context(), c.GetInt("user_id"), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "mfa_enrollment_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req dto.MFACodeRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	err := h.authService.DisableTOTP(c.Request.Context(), c.GetInt("user_id"), req.Code)
+	if errors.Is(err, services.ErrTooManyAttempts) {
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "mfa_disable_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.MFACodeRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	response, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), c.GetInt("user_id"), req.Code)
+	if errors.Is(err, services.ErrTooManyAttempts) {
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+			Error:   "too_many_attempts",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "recovery_codes_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
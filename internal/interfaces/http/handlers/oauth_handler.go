@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/services"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// scopeDescriptions are shown on the consent page; other scopes are shown
+// by name
+var scopeDescriptions = map[string]string{
+	"openid":  "Sign you in with your account",
+	"email":   "See your email address",
+	"profile": "See your profile",
+	"account": "Change your account settings and sign-in methods",
+	"admin":   "Manage roles and permissions",
+}
+
+// OAuthHandler serves the OAuth 2.1 authorization, token and device
+// endpoints and the OpenID Connect endpoints
+type OAuthHandler struct {
+	authService services.AuthService
+}
+
+func NewOAuthHandler(authService services.AuthService) *OAuthHandler {
+	return &OAuthHandler{
+		authService: authService,
+	}
+}
+
+// authorizePage is the data of the login and consent page, which is shown
+// for authorization requests and device user codes alike
+type authorizePage struct {
+	Action   string
+	Prompt   *dto.AuthorizationPrompt
+	Scopes   []string
+	Hidden   map[string]string
+	Email    string
+	MFAToken string
+	Error    string
+}
+
+func newConsentPage(action string, prompt *dto.AuthorizationPrompt) *authorizePage {
+	page := &authorizePage{
+		Action: action,
+		Prompt: prompt,
+		Hidden: map[string]string{},
+	}
+	for _, scope := range prompt.Scope {
+		if description, ok := scopeDescriptions[scope]; ok {
+			scope = description
+		}
+		page.Scopes = append(page.Scopes, scope)
+	}
+	return page
+}
+
+func newAuthorizePage(req *dto.AuthorizationRequest, prompt *dto.AuthorizationPrompt) *authorizePage {
+	page := newConsentPage("/oauth/authorize", prompt)
+	// The request travels with the form so it can be checked again
+	for name, value := range map[string]string{
+		"response_type":         req.ResponseType,
+		"client_id":             req.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 req.Scope,
+		"state":                 req.State,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+		"nonce":                 req.Nonce,
+	} {
+		if value != "" {
+			page.Hidden[name] = value
+		}
+	}
+	return page
+}
+
+// Authorize shows the login and consent page for an authorization request
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req dto.AuthorizationRequest
+	c.ShouldBindQuery(&req)
+
+	prompt, ok := h.checkAuthorizationRequest(c, &req)
+	if !ok {
+		return
+	}
+	renderPage(c, http.StatusOK, "authorize.html.tmpl", newAuthorizePage(&req, prompt))
+}
+
+// Approve signs the user in with the posted form and, if they allowed it,
+// sends the client an authorization code
+func (h *OAuthHandler) Approve(c *gin.Context) {
+	var form dto.AuthorizationLoginForm
+	c.ShouldBindWith(&form, binding.Form)
+	req := &form.AuthorizationRequest
+
+	prompt, ok := h.checkAuthorizationRequest(c, req)
+	if !ok {
+		return
+	}
+	if form.Decision != "allow" {
+		redirectToClient(c, req, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"The user denied the request"},
+		})
+		return
+	}
+
+	auth, ok := h.signIn(c, &form.ConsentForm, newAuthorizePage(req, prompt))
+	if !ok {
+		return
+	}
+	code, err := h.authService.IssueAuthorizationCode(c.Request.Context(), req, auth)
+	if err != nil {
+		h.respondAuthorizationError(c, req, err)
+		return
+	}
+	redirectToClient(c, req, url.Values{"code": {code}})
+}
+
+// Token serves the token endpoint (RFC 6749 section 3.2)
+func (h *OAuthHandler) Token(c *gin.Context) {
+	req, basic, ok := bindClientRequest(c)
+	if !ok {
+		return
+	}
+	response, err := h.authService.OAuthToken(c.Request.Context(), req)
+	if err != nil {
+		respondClientError(c, err, basic, "Failed to issue tokens")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeviceAuthorization starts the device flow (RFC 8628 section 3.1). The
+// client authenticates as it does at the token endpoint.
+func (h *OAuthHandler) DeviceAuthorization(c *gin.Context) {
+	req, basic, ok := bindClientRequest(c)
+	if !ok {
+		return
+	}
+	response, err := h.authService.DeviceAuthorization(c.Request.Context(), req)
+	if err != nil {
+		respondClientError(c, err, basic, "Failed to start device authorization")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// devicePage is the data of the device verification page: the user code
+// form, or the outcome once the user decided
+type devicePage struct {
+	UserCode string
+	Error    string
+	Outcome  string
+}
+
+// Device shows the verification page where the user enters the code their
+// device displays, or, given the code, the login and consent page
+func (h *OAuthHandler) Device(c *gin.Context) {
+	userCode := c.Query("user_code")
+	if userCode == "" {
+		renderPage(c, http.StatusOK, "device.html.tmpl", &devicePage{})
+		return
+	}
+	prompt, ok := h.checkUserCode(c, userCode)
+	if !ok {
+		return
+	}
+	renderPage(c, http.StatusOK, "authorize.html.tmpl", newDevicePage(userCode, prompt))
+}
+
+// ApproveDevice signs the user in with the posted form and records their
+// decision for the device to collect
+func (h *OAuthHandler) ApproveDevice(c *gin.Context) {
+	var form dto.DeviceVerificationForm
+	c.ShouldBindWith(&form, binding.Form)
+
+	prompt, ok := h.checkUserCode(c, form.UserCode)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if form.Decision != "allow" {
+		if err := h.authService.DenyDeviceCode(ctx, form.UserCode); err != nil {
+			h.respondUserCodeError(c, form.UserCode, err)
+			return
+		}
+		renderPage(c, http.StatusOK, "device.html.tmpl", &devicePage{
+			Outcome: "You denied the request. The device will not get access to your account.",
+		})
+		return
+	}
+
+	auth, ok := h.signIn(c, &form.ConsentForm, newDevicePage(form.UserCode, prompt))
+	if !ok {
+		return
+	}
+	if err := h.authService.ApproveDeviceCode(ctx, form.UserCode, auth); err != nil {
+		h.respondUserCodeError(c, form.UserCode, err)
+		return
+	}
+	renderPage(c, http.StatusOK, "device.html.tmpl", &devicePage{
+		Outcome: "The device is now signed in to " + prompt.ClientName + ". You can close this window.",
+	})
+}
+
+func newDevicePage(userCode string, prompt *dto.AuthorizationPrompt) *authorizePage {
+	page := newConsentPage("/oauth/device", prompt)
+	page.Hidden["user_code"] = userCode
+	return page
+}
+
+// Discovery serves the OpenID Connect discovery document, or 404 when the
+// server is not an OpenID Connect provider
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	config := h.authService.OpenIDConfiguration()
+	if config == nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "oidc_disabled",
+			Message: "OpenID Connect needs an asymmetric JWT_ALGORITHM",
+		})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, config)
+}
+
+// UserInfo returns the claims about the user that the access token's scope
+// allows. It runs after RequireAuth and RequireScope("openid").
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	claims, _ := c.Get("user_claims")
+	userClaims, _ := claims.(*dto.UserClaims)
+	if userClaims == nil {
+		c.JSON(http.StatusUnauthorized, dto.OAuthErrorResponse{Error: "invalid_token"})
+		return
+	}
+
+	info, err := h.authService.UserInfo(c.Request.Context(), userClaims.UserID, userClaims.Scope)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, dto.OAuthErrorResponse{
+			Error:            "invalid_token",
+			ErrorDescription: "The user is no longer available",
+		})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
+
+// EndSession serves RP-initiated logout. The user is sent back to the
+// client when it asked for a registered URI, and shown a page otherwise.
+func (h *OAuthHandler) EndSession(c *gin.Context) {
+	var req dto.EndSessionRequest
+	c.ShouldBindWith(&req, binding.Form)
+
+	if err := h.authService.EndSession(c.Request.Context(), &req); err != nil {
+		message := "Something went wrong on our side."
+		var oauthErr *services.OAuthError
+		switch {
+		case errors.As(err, &oauthErr):
+			message = "The logout request is not valid."
+		case errors.Is(err, services.ErrInvalidClient):
+			message = "The application asked to return somewhere it is not allowed to."
+		}
+		renderPage(c, http.StatusBadRequest, "oauth_error.html.tmpl", gin.H{
+			"Title":   "Logout failed",
+			"Message": message,
+		})
+		return
+	}
+
+	if req.PostLogoutRedirectURI == "" {
+		renderPage(c, http.StatusOK, "logged_out.html.tmpl", nil)
+		return
+	}
+	// The URI was checked against the client's registered ones
+	target, _ := url.Parse(req.PostLogoutRedirectURI)
+	if req.State != "" {
+		query := target.Query()
+		query.Set("state", req.State)
+		target.RawQuery = query.Encode()
+	}
+	c.Redirect(http.StatusSeeOther, target.String())
+}
+
+// signIn authenticates the user with the consent form, showing the page
+// again to ask for their second factor or when sign-in fails
+func (h *OAuthHandler) signIn(c *gin.Context, form *dto.ConsentForm, page *authorizePage) (*dto.Authentication, bool) {
+	ctx := c.Request.Context()
+	auth, err := h.authenticate(ctx, form)
+
+	page.Email = form.Email
+	var challenge *services.MFAChallengeError
+	switch {
+	case errors.As(err, &challenge):
+		// The password was right; ask for the second factor
+		page.MFAToken = challenge.Token
+		renderPage(c, http.StatusOK, "authorize.html.tmpl", page)
+		return nil, false
+	case errors.Is(err, services.ErrInvalidMFACode):
+		page.MFAToken = form.MFAToken
+		page.Error = "That code is not right. Please try again."
+		renderPage(c, http.StatusUnauthorized, "authorize.html.tmpl", page)
+		return nil, false
+	case err != nil:
+		page.Error = loginFailureMessage(err)
+		renderPage(c, http.StatusUnauthorized, "authorize.html.tmpl", page)
+		return nil, false
+	}
+	return auth, true
+}
+
+func (h *OAuthHandler) authenticate(ctx context.Context, form *dto.ConsentForm) (*dto.Authentication, error) {
+	if form.MFAToken != "" {
+		user, method, err := h.authService.AuthenticateMFA(ctx, form.MFAToken, form.Code)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.Authentication{
+			UserID:  user.ID,
+			Time:    time.Now(),
+			Methods: []string{dto.AMRPassword, method, dto.AMRMultiFactor},
+		}, nil
+	}
+	user, err := h.authService.Authenticate(ctx, &dto.LoginRequest{Email: form.Email, Password: form.Password})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.Authentication{UserID: user.ID, Time: time.Now(), Methods: []string{dto.AMRPassword}}, nil
+}
+
+// bindClientRequest reads the form a client posts to the token and device
+// authorization endpoints. Client credentials may come as HTTP Basic
+// authentication, with the id and secret form-encoded (RFC 6749 section
+// 2.3.1); basic reports whether they did.
+func bindClientRequest(c *gin.Context) (req *dto.OAuthTokenRequest, basic bool, ok bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	req = &dto.OAuthTokenRequest{}
+	if err := c.ShouldBindWith(req, binding.Form); err != nil {
+		c.JSON(http.StatusBadRequest, dto.OAuthErrorResponse{
+			Error:            "invalid_request",
+			ErrorDescription: "The request must be a form",
+		})
+		return nil, false, false
+	}
+
+	clientID, clientSecret, basic := c.Request.BasicAuth()
+	if basic {
+		id, idErr := url.QueryUnescape(clientID)
+		secret, secretErr := url.QueryUnescape(clientSecret)
+		if idErr != nil || secretErr != nil || req.ClientSecret != "" || (req.ClientID != "" && req.ClientID != id) {
+			c.JSON(http.StatusBadRequest, dto.OAuthErrorResponse{
+				Error:            "invalid_request",
+				ErrorDescription: "The client must authenticate in one way only",
+			})
+			return nil, false, false
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+	return req, basic, true
+}
+
+// respondClientError answers a client with an OAuth error body (RFC 6749
+// section 5.2)
+func respondClientError(c *gin.Context, err error, basic bool, failure string) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, dto.OAuthErrorResponse{
+			Error:            "server_error",
+			ErrorDescription: failure,
+		})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+	c.JSON(status, dto.OAuthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+// checkUserCode answers for a user code that can't be approved
+func (h *OAuthHandler) checkUserCode(c *gin.Context, userCode string) (*dto.AuthorizationPrompt, bool) {
+	prompt, err := h.authService.CheckUserCode(c.Request.Context(), userCode)
+	if err != nil {
+		h.respondUserCodeError(c, userCode, err)
+		return nil, false
+	}
+	return prompt, true
+}
+
+// respondUserCodeError shows the code form again for an unknown code, so
+// the user can correct a typo
+func (h *OAuthHandler) respondUserCodeError(c *gin.Context, userCode string, err error) {
+	if errors.Is(err, services.ErrInvalidUserCode) {
+		renderPage(c, http.StatusBadRequest, "device.html.tmpl", &devicePage{
+			UserCode: userCode,
+			Error:    "That code is not valid or has expired. Check the code on your device and try again.",
+		})
+		return
+	}
+	renderPage(c, http.StatusInternalServerError, "oauth_error.html.tmpl", gin.H{
+		"Message": "Something went wrong on our side.",
+	})
+}
+
+// checkAuthorizationRequest answers for an authorization request that
+// can't go ahead
+func (h *OAuthHandler) checkAuthorizationRequest(c *gin.Context, req *dto.AuthorizationRequest) (*dto.AuthorizationPrompt, bool) {
+	prompt, err := h.authService.CheckAuthorizationRequest(c.Request.Context(), req)
+	if err != nil {
+		h.respondAuthorizationError(c, req, err)
+		return nil, false
+	}
+	return prompt, true
+}
+
+// respondAuthorizationError reports errors to the client's redirect URI,
+// except when the client or redirect URI can't be trusted, which is shown
+// to the user instead (RFC 6749 section 4.1.2.1)
+func (h *OAuthHandler) respondAuthorizationError(c *gin.Context, req *dto.AuthorizationRequest, err error) {
+	var oauthErr *services.OAuthError
+	switch {
+	case errors.As(err, &oauthErr):
+		redirectToClient(c, req, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+		})
+	case errors.Is(err, services.ErrInvalidClient):
+		renderPage(c, http.StatusBadRequest, "oauth_error.html.tmpl", gin.H{
+			"Message": "The application is not registered, or asked to return somewhere it is not allowed to.",
+		})
+	default:
+		renderPage(c, http.StatusInternalServerError, "oauth_error.html.tmpl", gin.H{
+			"Message": "Something went wrong on our side.",
+		})
+	}
+}
+
+// redirectToClient sends the browser back to the client's redirect URI
+// with the response parameters and the client's state
+func redirectToClient(c *gin.Context, req *dto.AuthorizationRequest, params url.Values) {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	// The redirect URI was checked against the registered ones
+	target, _ := url.Parse(req.RedirectURI)
+	query := target.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusSeeOther, target.String())
+}
+
+// loginFailureMessage tells the user why the page didn't sign them in
+func loginFailureMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrAccountLocked):
+		return "This account is temporarily locked after too many failed sign-ins. Check your email for an unlock link."
+	case errors.Is(err, services.ErrAccountDisabled):
+		return "This account has been disabled."
+	case errors.Is(err, services.ErrEmailNotVerified):
+		return "Verify your email address before signing in."
+	case errors.Is(err, services.ErrTooManyAttempts):
+		return "Too many failed attempts. Please try again later."
+	}
+	return "Sign-in failed. Check your email address and password."
+}
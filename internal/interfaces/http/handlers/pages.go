@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"embed"
+	"html/template"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates/*.html.tmpl
+var pageFS embed.FS
+
+// pages are the few HTML pages served to browsers, named by file
+var pages = template.Must(template.ParseFS(pageFS, "templates/*.html.tmpl"))
+
+// renderPage writes an HTML page. The pages take credentials, so they are
+// never cached or framed.
+func renderPage(c *gin.Context, status int, name string, data interface{}) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Status(status)
+	if err := pages.ExecuteTemplate(c.Writer, name, data); err != nil {
+		log.Printf("Failed to render page %s: %v", name, err)
+	}
+}
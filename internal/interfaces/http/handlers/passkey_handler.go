@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/interfaces/http/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler struct {
+	authService services.AuthService
+}
+
+func NewPasskeyHandler(authService services.AuthService) *PasskeyHandler {
+	return &PasskeyHandler{
+		authService: authService,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create()
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	options, err := h.authService.BeginPasskeyRegistration(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "passkey_registration_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	var req dto.PasskeyRegistrationRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	credential, err := h.authService.FinishPasskeyRegistration(c.Request.Context(), c.GetInt("user_id"), &req)
+	if respondStepUpError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "passkey_registration_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+func (h *PasskeyHandler) List(c *gin.Context) {
+	credentials, err := h.authService.ListPasskeys(c.Request.Context(), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "passkeys_unavailable",
+			Message: "Failed to list passkeys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Passkeys retrieved successfully",
+		Data:    credentials,
+	})
+}
+
+func (h *PasskeyHandler) Delete(c *gin.Context) {
+	if err := h.authService.DeletePasskey(c.Request.Context(), c.GetInt("user_id"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "passkey_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Passkey removed",
+	})
+}
+
+// BeginLogin returns the options for navigator.credentials.get(). The body
+// may carry the mfa_token of a password login to use the passkey as the
+// second factor.
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	var req dto.PasskeyLoginRequest
+	if c.Request.ContentLength != 0 {
+		middleware.ValidateRequest(&req)(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	options, err := h.authService.BeginPasskeyLogin(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "passkey_login_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req dto.PasskeyAssertionRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	response, err := h.authService.FinishPasskeyLogin(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "account_disabled",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "email_not_verified",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error:   "passkey_login_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"jwt-auth/internal/application/dto"
+	"jwt-auth/internal/domain/services"
+	"jwt-auth/internal/interfaces/http/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler serves the administration of roles, permissions and role
+// assignments
+type RoleHandler struct {
+	authService services.AuthService
+}
+
+func NewRoleHandler(authService services.AuthService) *RoleHandler {
+	return &RoleHandler{
+		authService: authService,
+	}
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.authService.ListRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "roles_unavailable",
+			Message: "Failed to list roles",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Roles retrieved successfully",
+		Data:    roles,
+	})
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req dto.CreateRoleRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	role, err := h.authService.CreateRole(c.Request.Context(), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "role_creation_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.SuccessResponse{
+		Message: "Role created",
+		Data:    role,
+	})
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.authService.DeleteRole(c.Request.Context(), c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "role_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Role deleted",
+	})
+}
+
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.authService.ListPermissions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "permissions_unavailable",
+			Message: "Failed to list permissions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Permissions retrieved successfully",
+		Data:    permissions,
+	})
+}
+
+func (h *RoleHandler) GrantPermission(c *gin.Context) {
+	var req dto.GrantPermissionRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	if err := h.authService.GrantPermission(c.Request.Context(), c.Param("name"), req.Permission); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "permission_grant_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Permission granted",
+	})
+}
+
+func (h *RoleHandler) RevokePermission(c *gin.Context) {
+	if err := h.authService.RevokePermission(c.Request.Context(), c.Param("name"), c.Param("permission")); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "permission_revoke_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Permission revoked",
+	})
+}
+
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.authService.ListUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "user_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Roles retrieved successfully",
+		Data:    roles,
+	})
+}
+
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req dto.AssignRoleRequest
+	middleware.ValidateRequest(&req)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	if err := h.authService.AssignRole(c.Request.Context(), userID, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "role_assignment_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Role assigned",
+	})
+}
+
+func (h *RoleHandler) RemoveRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.RemoveRole(c.Request.Context(), userID, c.Param("role")); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "role_removal_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message: "Role removed; the user's sessions have been signed out",
+	})
+}
+
+// userIDParam reads the :id path parameter
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "invalid_user_id",
+			Message: "User ID must be a number",
+		})
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"jwt-auth/internal/application/dto"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole lets through users who have any of the roles. It must run
+// after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := userClaims(c)
+		if claims == nil || !slices.ContainsFunc(roles, func(role string) bool {
+			return slices.Contains(claims.Roles, role)
+		}) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission lets through users who have all of the permissions. It
+// must run after RequireAuth.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := userClaims(c)
+		if claims == nil {
+			forbid(c)
+			return
+		}
+		for _, permission := range permissions {
+			if !slices.Contains(claims.Permissions, permission) {
+				forbid(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func userClaims(c *gin.Context) *dto.UserClaims {
+	claims, _ := c.Get("user_claims")
+	userClaims, _ := claims.(*dto.UserClaims)
+	return userClaims
+}
+
+func forbid(c *gin.Context) {
+	c.JSON(http.StatusForbidden, dto.ErrorResponse{
+		Error:   "forbidden",
+		Message: "You do not have access to this resource",
+	})
+	c.Abort()
+}
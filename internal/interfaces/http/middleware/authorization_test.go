@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jwt-auth/internal/application/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	editor := &dto.UserClaims{UserID: 1, Roles: []string{"editor"}, Permissions: []string{"posts:read", "posts:write"}}
+
+	tests := []struct {
+		name   string
+		claims *dto.UserClaims
+		gate   gin.HandlerFunc
+		want   int
+	}{
+		{"any of the roles", editor, RequireRole("admin", "editor"), http.StatusOK},
+		{"none of the roles", editor, RequireRole("admin"), http.StatusForbidden},
+		{"all of the permissions", editor, RequirePermission("posts:read", "posts:write"), http.StatusOK},
+		{"some of the permissions", editor, RequirePermission("posts:write", "posts:delete"), http.StatusForbidden},
+		{"no claims", nil, RequireRole("editor"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set("user_claims", tt.claims)
+				}
+			}, tt.gate, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set("user_claims", &dto.UserClaims{UserID: 1, Scope: []string{"profile"}})
+	}, RequireScope("profile"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/account", func(c *gin.Context) {
+		c.Set("user_claims", &dto.UserClaims{UserID: 1, Scope: []string{"profile"}})
+	}, RequireScope("profile", "account"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a token with the scope to pass, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/account", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected a token missing a scope to get 403, got %d", w.Code)
+	}
+	challenge := w.Header().Get("WWW-Authenticate")
+	if !strings.HasPrefix(challenge, `Bearer error="insufficient_scope"`) || !strings.Contains(challenge, `scope="profile account"`) {
+		t.Errorf("expected an insufficient_scope challenge naming the scopes, got %q", challenge)
+	}
+}
+
+func TestRequirePrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &dto.UserClaims{UserID: 1, Principal: dto.PrincipalUser}
+	service := &dto.UserClaims{ClientID: "reports", Principal: dto.PrincipalService}
+
+	tests := []struct {
+		name   string
+		claims *dto.UserClaims
+		gate   gin.HandlerFunc
+		want   int
+	}{
+		{"user on a user route", user, RequireUser(), http.StatusOK},
+		{"service on a user route", service, RequireUser(), http.StatusForbidden},
+		{"service on a service route", service, RequireService(), http.StatusOK},
+		{"user on a service route", user, RequireService(), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				setPrincipal(c, tt.claims)
+			}, tt.gate, func(c *gin.Context) {
+				if IsService(c) != (tt.claims.Principal == dto.PrincipalService) {
+					t.Errorf("expected IsService to be %v", !IsService(c))
+				}
+				if IsService(c) && c.GetString("client_id") != "reports" {
+					t.Errorf("expected the client id in the context, got %q", c.GetString("client_id"))
+				}
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"fmt"
 	"jwt-auth/internal/application/dto"
 	"jwt-auth/internal/domain/services"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +25,8 @@ func (m *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			// RFC 6750: no error code when the request carried no credentials
+			c.Header("WWW-Authenticate", "Bearer")
 			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 				Error:   "unauthorized",
 				Message: "Authorization header is required",
@@ -33,6 +37,7 @@ func (m *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 
 		// Check Bearer token format
 		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.Header("WWW-Authenticate", bearerChallenge("invalid_request", "Authorization header must be in Bearer format", nil))
 			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 				Error:   "unauthorized",
 				Message: "Authorization header must be in Bearer format",
@@ -46,6 +51,7 @@ func (m *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 		// Validate token
 		userClaims, err := m.authService.ValidateToken(c.Request.Context(), token)
 		if err != nil {
+			c.Header("WWW-Authenticate", bearerChallenge("invalid_token", "Invalid token", nil))
 			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 				Error:   "unauthorized",
 				Message: "Invalid token",
@@ -54,12 +60,7 @@ func (m *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Set user claims in context
-		c.Set("user_id", userClaims.UserID)
-		c.Set("username", userClaims.Username)
-		c.Set("email", userClaims.Email)
-		c.Set("user_claims", userClaims)
-
+		setPrincipal(c, userClaims)
 		c.Next()
 	}
 }
@@ -72,13 +73,90 @@ func (m *JWTMiddleware) OptionalAuth() gin.HandlerFunc {
 			token := strings.TrimPrefix(authHeader, "Bearer ")
 
 			if userClaims, err := m.authService.ValidateToken(c.Request.Context(), token); err == nil {
-				c.Set("user_id", userClaims.UserID)
-				c.Set("username", userClaims.Username)
-				c.Set("email", userClaims.Email)
-				c.Set("user_claims", userClaims)
+				setPrincipal(c, userClaims)
 			}
 		}
 
 		c.Next()
 	}
 }
+
+// setPrincipal puts the token's claims in the context: "principal" is
+// dto.PrincipalUser or dto.PrincipalService, and user tokens set "user_id",
+// "username" and "email" while service tokens set "client_id"
+func setPrincipal(c *gin.Context, userClaims *dto.UserClaims) {
+	c.Set("principal", userClaims.Principal)
+	c.Set("user_claims", userClaims)
+	if userClaims.Principal == dto.PrincipalService {
+		c.Set("client_id", userClaims.ClientID)
+		return
+	}
+	c.Set("user_id", userClaims.UserID)
+	c.Set("username", userClaims.Username)
+	c.Set("email", userClaims.Email)
+}
+
+// IsService tells whether the caller authenticated as a service rather
+// than as a user
+func IsService(c *gin.Context) bool {
+	return c.GetString("principal") == dto.PrincipalService
+}
+
+// RequireUser lets through tokens that speak for a user, keeping service
+// tokens out of routes that act on the signed-in user. It must run after
+// RequireAuth.
+func RequireUser() gin.HandlerFunc {
+	return requirePrincipal(dto.PrincipalUser, "This resource is only available to users")
+}
+
+// RequireService lets through service tokens only. It must run after
+// RequireAuth.
+func RequireService() gin.HandlerFunc {
+	return requirePrincipal(dto.PrincipalService, "This resource is only available to services")
+}
+
+func requirePrincipal(principal, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("principal") != principal {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error:   "forbidden",
+				Message: message,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope lets through tokens that carry all of the scopes, and
+// otherwise answers with the RFC 6750 insufficient_scope error. It must run
+// after RequireAuth.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := userClaims(c)
+		for _, scope := range scopes {
+			if claims == nil || !slices.Contains(claims.Scope, scope) {
+				message := "The token does not carry the scope " + strings.Join(scopes, " ")
+				c.Header("WWW-Authenticate", bearerChallenge("insufficient_scope", message, scopes))
+				c.JSON(http.StatusForbidden, dto.ErrorResponse{
+					Error:   "insufficient_scope",
+					Message: message,
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// bearerChallenge formats a WWW-Authenticate challenge for an RFC 6750
+// error, naming the scopes the resource needs if any
+func bearerChallenge(code, description string, scopes []string) string {
+	challenge := fmt.Sprintf("Bearer error=%q, error_description=%q", code, description)
+	if len(scopes) > 0 {
+		challenge += fmt.Sprintf(", scope=%q", strings.Join(scopes, " "))
+	}
+	return challenge
+}
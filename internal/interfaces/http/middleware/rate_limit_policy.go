@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+)
+
+// Who a rate limit policy counts
+const (
+	// RateLimitByIP gives every client IP its own budget
+	RateLimitByIP = "ip"
+	// RateLimitByUser gives every authenticated user their own budget
+	RateLimitByUser = "user"
+	// RateLimitByAPIKey gives every X-API-Key its own budget
+	RateLimitByAPIKey = "api_key"
+	// RateLimitByAccount gives every account and client IP their own budget
+	// on the routes guarded by LoginLimit
+	RateLimitByAccount = "account"
+)
+
+// RateLimitPolicy is a budget for one kind of principal on one or more routes
+type RateLimitPolicy struct {
+	// Route is a full route path (e.g. /api/v1/auth/login), a prefix
+	// ending in * (e.g. /api/v1/auth/*), or * for every route
+	Route string
+	// Key is one of the RateLimitBy kinds, or a network in CIDR notation
+	// whose clients share one budget
+	Key string
+	services.RateLimit
+
+	network *net.IPNet
+}
+
+// ParseRateLimitPolicy parses "ROUTE KEY LIMIT/PERIOD [ALGORITHM]", e.g.
+// "/api/v1/auth/refresh user 60/1h token_bucket". PERIOD is a duration or a
+// number of seconds.
+func ParseRateLimitPolicy(s, defaultAlgorithm string) (RateLimitPolicy, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 && len(fields) != 4 {
+		return RateLimitPolicy{}, fmt.Errorf("rate limit policy %q must be \"ROUTE KEY LIMIT/PERIOD [ALGORITHM]\"", s)
+	}
+
+	budget := strings.SplitN(fields[2], "/", 2)
+	if len(budget) != 2 {
+		return RateLimitPolicy{}, fmt.Errorf("rate limit policy %q has no period", s)
+	}
+	limit, err := strconv.Atoi(budget[0])
+	if err != nil {
+		return RateLimitPolicy{}, fmt.Errorf("rate limit policy %q has an invalid limit", s)
+	}
+	period, err := time.ParseDuration(budget[1])
+	if err != nil {
+		seconds, err := strconv.Atoi(budget[1])
+		if err != nil {
+			return RateLimitPolicy{}, fmt.Errorf("rate limit policy %q has an invalid period", s)
+		}
+		period = time.Duration(seconds) * time.Second
+	}
+
+	algorithm := defaultAlgorithm
+	if len(fields) == 4 {
+		algorithm = fields[3]
+	}
+
+	return RateLimitPolicy{
+		Route:     fields[0],
+		Key:       fields[1],
+		RateLimit: services.RateLimit{Algorithm: algorithm, Limit: limit, Period: period},
+	}, nil
+}
+
+// validate checks the policy and resolves a CIDR key
+func (p *RateLimitPolicy) validate() error {
+	switch p.Algorithm {
+	case services.RateLimitFixedWindow, services.RateLimitSlidingLog,
+		services.RateLimitSlidingWindow, services.RateLimitTokenBucket:
+	default:
+		return fmt.Errorf("rate limit policy for %s has unknown algorithm %q", p.Route, p.Algorithm)
+	}
+	if p.Limit <= 0 || p.Period < time.Second {
+		return fmt.Errorf("rate limit policy for %s needs a positive limit and a period of at least 1s", p.Route)
+	}
+	if p.Route != "*" && !strings.HasPrefix(p.Route, "/") {
+		return fmt.Errorf("rate limit policy route %q must be a path or *", p.Route)
+	}
+
+	switch p.Key {
+	case RateLimitByIP, RateLimitByUser, RateLimitByAPIKey, RateLimitByAccount:
+		return nil
+	}
+	network, err := parseNetwork(p.Key)
+	if err != nil {
+		return fmt.Errorf("rate limit policy for %s has invalid key %q", p.Route, p.Key)
+	}
+	p.network = network
+	return nil
+}
+
+// kind is the principal the policy counts; networks are a kind of IP policy
+func (p *RateLimitPolicy) kind() string {
+	if p.network != nil {
+		return RateLimitByIP
+	}
+	return p.Key
+}
+
+// routeRank tells whether the policy covers path and how specifically:
+// exact paths beat longer prefixes, which beat shorter ones and *
+func (p *RateLimitPolicy) routeRank(path string) (int, bool) {
+	switch {
+	case p.Route == path:
+		return len(path) + 1, true
+	case strings.HasSuffix(p.Route, "*") && strings.HasPrefix(path, strings.TrimSuffix(p.Route, "*")):
+		return len(p.Route) - 1, true
+	}
+	return 0, false
+}
+
+// parseNetwork accepts a CIDR or a single address
+func parseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
@@ -1,112 +1,246 @@
 package middleware
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"jwt-auth/internal/domain/services"
+
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// RateLimiter throttles requests against a table of policies. For every
+// kind of principal the request has (its IP, its user, its API key), the
+// most specific policy for the route applies. The counting happens in a
+// services.RateLimitStore, which applies the policy's algorithm atomically.
 type RateLimiter struct {
-	redisClient *redis.Client
-	limit       int
-	period      int // in seconds
+	store     services.RateLimitStore
+	policies  []RateLimitPolicy
+	allowlist []*net.IPNet
 }
 
-func NewRateLimiter(redisClient *redis.Client, limit, period int) *RateLimiter {
-	return &RateLimiter{
-		redisClient: redisClient,
-		limit:       limit,
-		period:      period,
+// NewRateLimiter checks the policies. Clients in the allowlisted networks
+// are never throttled.
+func NewRateLimiter(store services.RateLimitStore, policies []RateLimitPolicy, allowlist []string) (*RateLimiter, error) {
+	rl := &RateLimiter{
+		store:    store,
+		policies: make([]RateLimitPolicy, len(policies)),
+	}
+	copy(rl.policies, policies)
+	for i := range rl.policies {
+		if err := rl.policies[i].validate(); err != nil {
+			return nil, err
+		}
 	}
+	for _, entry := range allowlist {
+		network, err := parseNetwork(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit allowlist entry %q", entry)
+		}
+		rl.allowlist = append(rl.allowlist, network)
+	}
+	return rl, nil
 }
 
+// rateLimitCheck is one policy applied to one principal
+type rateLimitCheck struct {
+	policy    *RateLimitPolicy
+	principal string
+}
+
+// Limit throttles requests by client IP or network, by authenticated user
+// or service (when it runs after RequireAuth or OptionalAuth) and by API key
 func (rl *RateLimiter) Limit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		key := fmt.Sprintf("rate_limit:%s", c.ClientIP())
-		ctx := context.Background()
-
-		// Get the current count
-		count, err := rl.redisClient.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "rate_limit_error", "message": "Error checking rate limit"})
-			c.Abort()
+		ip := net.ParseIP(c.ClientIP())
+		if rl.allowlisted(ip) {
+			c.Next()
 			return
 		}
 
-		if count >= rl.limit {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(rl.limit))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+int64(rl.period), 10))
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "rate_limit_exceeded",
-				"message": fmt.Sprintf("Rate limit of %d requests per %d seconds exceeded", rl.limit, rl.period),
-			})
-			c.Abort()
+		var checks []rateLimitCheck
+		if policy := rl.policyFor(c.FullPath(), RateLimitByIP, ip); policy != nil {
+			principal := c.ClientIP()
+			if policy.network != nil {
+				principal = policy.network.String()
+			}
+			checks = append(checks, rateLimitCheck{policy, principal})
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			if policy := rl.policyFor(c.FullPath(), RateLimitByUser, nil); policy != nil {
+				checks = append(checks, rateLimitCheck{policy, fmt.Sprint(userID)})
+			}
+		} else if clientID := c.GetString("client_id"); clientID != "" {
+			// Services are limited like users, each on its own
+			if policy := rl.policyFor(c.FullPath(), RateLimitByUser, nil); policy != nil {
+				checks = append(checks, rateLimitCheck{policy, "client:" + clientID})
+			}
+		}
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			if policy := rl.policyFor(c.FullPath(), RateLimitByAPIKey, nil); policy != nil {
+				checks = append(checks, rateLimitCheck{policy, hashIdentifier(apiKey)})
+			}
+		}
+
+		if !rl.enforce(c, checks, "") {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Increment the counter
-		if count == 0 {
-			err = rl.redisClient.SetNX(ctx, key, 1, time.Duration(rl.period)*time.Second).Err()
-		} else {
-			err = rl.redisClient.Incr(ctx, key).Err()
+// allowlisted tells whether ip is in an allowlisted network
+func (rl *RateLimiter) allowlisted(ip net.IP) bool {
+	for _, network := range rl.allowlist {
+		if ip != nil && network.Contains(ip) {
+			return true
 		}
+	}
+	return false
+}
 
+// policyFor picks the most specific policy of a kind for the route. Among
+// IP policies for the same route, the narrowest network containing ip
+// beats the per-IP policy. On a tie the later policy wins, so configured
+// policies can override defaults listed before them.
+func (rl *RateLimiter) policyFor(path, kind string, ip net.IP) *RateLimitPolicy {
+	var best *RateLimitPolicy
+	bestRank, bestBits := -1, -1
+	for i := range rl.policies {
+		policy := &rl.policies[i]
+		if policy.kind() != kind {
+			continue
+		}
+		rank, ok := policy.routeRank(path)
+		if !ok {
+			continue
+		}
+		bits := -1
+		if policy.network != nil {
+			if ip == nil || !policy.network.Contains(ip) {
+				continue
+			}
+			bits, _ = policy.network.Mask.Size()
+		}
+		if rank > bestRank || (rank == bestRank && bits >= bestBits) {
+			best, bestRank, bestBits = policy, rank, bits
+		}
+	}
+	return best
+}
+
+// enforce counts the request against every check and sets the RateLimit
+// headers for the tightest one. A request over any limit is answered with
+// 429 and aborted; message overrides the default 429 message.
+func (rl *RateLimiter) enforce(c *gin.Context, checks []rateLimitCheck, message string) bool {
+	var tightest *services.RateLimitResult
+	var tightestPolicy *RateLimitPolicy
+	policies := make([]string, 0, len(checks))
+	for _, check := range checks {
+		policy := check.policy
+		key := fmt.Sprintf("%s:%s:%s", policy.Route, policy.Key, check.principal)
+		result, err := rl.store.Allow(c.Request.Context(), key, policy.RateLimit)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "rate_limit_error", "message": "Error updating rate limit"})
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "rate_limit_error", "message": "Error checking rate limit"})
 			c.Abort()
-			return
+			return false
+		}
+		policies = append(policies, fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+		if tightest == nil || !result.Allowed || result.Remaining < tightest.Remaining {
+			tightest, tightestPolicy = result, policy
+		}
+		if !result.Allowed {
+			break
 		}
+	}
+	if tightest == nil {
+		return true
+	}
 
-		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.limit))
-		c.Header("X-RateLimit-Remaining", strconv.Itoa(rl.limit-count-1))
-		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+int64(rl.period), 10))
+	c.Header("RateLimit-Policy", strings.Join(policies, ", "))
+	c.Header("RateLimit-Limit", strconv.Itoa(tightestPolicy.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(tightest.ResetAfter), 10))
 
-		c.Next()
+	if !tightest.Allowed {
+		if message == "" {
+			message = fmt.Sprintf("Rate limit of %d requests per %s exceeded", tightestPolicy.Limit, tightestPolicy.Period)
+		}
+		c.Header("Retry-After", strconv.FormatInt(ceilSeconds(tightest.RetryAfter), 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":   "rate_limit_exceeded",
+			"message": message,
+		})
+		c.Abort()
+		return false
 	}
+	return true
 }
 
-func (rl *RateLimiter) LoginLimit() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var loginReq struct {
-			Email string `json:"email"`
-		}
-		c.ShouldBindJSON(&loginReq)
-		key := fmt.Sprintf("rate_limit:login:%s", loginReq.Email)
-		ctx := context.Background()
+// hashIdentifier keeps emails, tokens and API keys out of Redis keys
+func hashIdentifier(identifier string) string {
+	sum := sha256.Sum256([]byte(identifier))
+	return hex.EncodeToString(sum[:16])
+}
 
-		count, err := rl.redisClient.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "rate_limit_error", "message": "Error checking rate limit"})
-			c.Abort()
+// ceilSeconds rounds up, so clients never retry a moment too early
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// maxThrottledBody bounds how much of a request LoginLimit buffers
+const maxThrottledBody = 1 << 20
+
+// LoginLimit throttles attempts per identifier and client IP with the
+// route's account policy, so one client can't keep guessing for an account
+// while others can still sign in to it. The identifier is the named field
+// of the JSON or form body (e.g. "email"), which is buffered and put back
+// for the handler to bind.
+func (rl *RateLimiter) LoginLimit(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := rl.policyFor(c.FullPath(), RateLimitByAccount, nil)
+		if policy == nil || rl.allowlisted(net.ParseIP(c.ClientIP())) {
+			c.Next()
 			return
 		}
 
-		if count >= rl.limit {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "rate_limit_exceeded",
-				"message": "Too many login attempts. Please try again later.",
-			})
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxThrottledBody))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request_too_large", "message": "Request body is too large"})
 			c.Abort()
 			return
 		}
-
-		if count == 0 {
-			err = rl.redisClient.SetNX(ctx, key, 1, time.Duration(rl.period)*time.Second).Err()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// A body that doesn't parse is throttled on the IP alone; the
+		// handler rejects it anyway
+		var identifier string
+		if c.ContentType() == binding.MIMEPOSTForm {
+			form, _ := url.ParseQuery(string(body))
+			identifier = form.Get(field)
 		} else {
-			err = rl.redisClient.Incr(ctx, key).Err()
+			var fields map[string]interface{}
+			json.Unmarshal(body, &fields)
+			identifier, _ = fields[field].(string)
 		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "rate_limit_error", "message": "Error updating rate limit"})
-			c.Abort()
+		identifier = strings.ToLower(strings.TrimSpace(identifier))
+
+		check := rateLimitCheck{policy, hashIdentifier(identifier) + ":" + c.ClientIP()}
+		if !rl.enforce(c, []rateLimitCheck{check}, "Too many attempts. Please try again later.") {
 			return
 		}
-
 		c.Next()
 	}
 }
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"jwt-auth/internal/domain/services"
+	redisinfra "jwt-auth/internal/infrastructure/redis"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRateLimiter(t *testing.T, policies []string, allowlist []string) *RateLimiter {
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     ":6379",                   // Use a real or mock Redis instance
+		Password: "RedisSecurePassword123!", // Use the password from your .env/docker-compose
+	})
+	// A route prefix per run keeps earlier runs from counting
+	prefix := "/test" + time.Now().Format("150405.000000")
+
+	var parsed []RateLimitPolicy
+	for _, entry := range policies {
+		policy, err := ParseRateLimitPolicy(strings.ReplaceAll(entry, "/test", prefix), services.RateLimitFixedWindow)
+		if err != nil {
+			t.Fatalf("ParseRateLimitPolicy failed: %v", err)
+		}
+		parsed = append(parsed, policy)
+	}
+	limiter, err := NewRateLimiter(redisinfra.NewRateLimitStore(redisClient), parsed, allowlist)
+	if err != nil {
+		t.Fatalf("NewRateLimiter failed: %v", err)
+	}
+	return limiter
+}
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	policy, err := ParseRateLimitPolicy("/api/v1/auth/refresh user 60/1h token_bucket", services.RateLimitSlidingWindow)
+	if err != nil {
+		t.Fatalf("ParseRateLimitPolicy failed: %v", err)
+	}
+	if policy.Route != "/api/v1/auth/refresh" || policy.Key != RateLimitByUser || policy.Algorithm != services.RateLimitTokenBucket ||
+		policy.Limit != 60 || policy.Period != time.Hour {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+
+	policy, err = ParseRateLimitPolicy("* 10.0.0.0/8 500/60", services.RateLimitSlidingWindow)
+	if err != nil {
+		t.Fatalf("ParseRateLimitPolicy failed: %v", err)
+	}
+	if policy.Algorithm != services.RateLimitSlidingWindow || policy.Period != time.Minute {
+		t.Errorf("expected the default algorithm and a period in seconds, got %+v", policy)
+	}
+
+	for _, invalid := range []string{"", "* ip", "* ip 10", "* ip ten/1m", "* ip 10/soon", "* ip 10/1m leaky extra"} {
+		if _, err := ParseRateLimitPolicy(invalid, services.RateLimitSlidingWindow); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+
+	for _, invalid := range []RateLimitPolicy{
+		{Route: "*", Key: "ip", RateLimit: services.RateLimit{Algorithm: "leaky", Limit: 1, Period: time.Minute}},
+		{Route: "*", Key: "ip", RateLimit: services.RateLimit{Algorithm: services.RateLimitFixedWindow, Period: time.Minute}},
+		{Route: "login", Key: "ip", RateLimit: services.RateLimit{Algorithm: services.RateLimitFixedWindow, Limit: 1, Period: time.Minute}},
+		{Route: "*", Key: "10.0.0.0/33", RateLimit: services.RateLimit{Algorithm: services.RateLimitFixedWindow, Limit: 1, Period: time.Minute}},
+	} {
+		if _, err := NewRateLimiter(nil, []RateLimitPolicy{invalid}, nil); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+	if _, err := NewRateLimiter(nil, nil, []string{"internal"}); err == nil {
+		t.Error("expected an invalid allowlist entry to be rejected")
+	}
+}
+
+func TestRateLimiter_Limit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newTestRateLimiter(t, []string{
+		"/test/* ip 2/1m",
+		"/test/refresh ip 3/1m token_bucket",
+		"/test/* 10.8.0.0/16 4/1m",
+		"/test/* user 1/1m",
+		"/test/* api_key 5/1m",
+	}, []string{"192.168.0.0/16"})
+	prefix := limiter.policies[0].Route[:len(limiter.policies[0].Route)-2]
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("user_id", user)
+		}
+	})
+	router.Use(limiter.Limit())
+	for _, path := range []string{"/register", "/login", "/refresh", "/profile"} {
+		router.GET(prefix+path, func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	request := func(path, ip string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, prefix+path, nil)
+		req.RemoteAddr = ip + ":1234"
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Routes under a prefix share its budget", func(t *testing.T) {
+		w := request("/register", "10.0.0.1")
+		if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Remaining") != "1" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+		}
+		request("/login", "10.0.0.1")
+		w = request("/register", "10.0.0.1")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Errorf("expected the third request to be throttled, got %d", w.Code)
+		}
+	})
+
+	t.Run("A route policy gives the route its own budget", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if w := request("/refresh", "10.0.0.1"); w.Code != http.StatusNoContent {
+				t.Fatalf("request %d: expected the refresh budget to apply, got %d", i+1, w.Code)
+			}
+		}
+		if w := request("/refresh", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the fourth refresh to be throttled, got %d", w.Code)
+		}
+	})
+
+	t.Run("Clients in a network share its budget", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			if w := request("/register", fmt.Sprintf("10.8.0.%d", i+1)); w.Code != http.StatusNoContent {
+				t.Fatalf("request %d: expected the network budget to apply, got %d", i+1, w.Code)
+			}
+		}
+		if w := request("/register", "10.8.1.1"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the network's fifth request to be throttled, got %d", w.Code)
+		}
+	})
+
+	t.Run("Users are counted on their own", func(t *testing.T) {
+		if w := request("/profile", "10.0.0.2", "X-Test-User", "7"); w.Code != http.StatusNoContent {
+			t.Fatalf("expected the first request to pass, got %d", w.Code)
+		}
+		w := request("/profile", "10.0.0.3", "X-Test-User", "7")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected the user's second request to be throttled from another IP, got %d", w.Code)
+		}
+		if w.Header().Get("RateLimit-Policy") != "2;w=60, 1;w=60" {
+			t.Errorf("expected both policies to be listed, got %q", w.Header().Get("RateLimit-Policy"))
+		}
+	})
+
+	t.Run("API keys are counted on their own", func(t *testing.T) {
+		w := request("/profile", "10.0.0.4", "X-API-Key", "key-1")
+		if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Remaining") != "1" {
+			t.Fatalf("expected the tightest budget in the headers, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Allowlisted networks are never throttled", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			w := request("/register", "192.168.1.1")
+			if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+				t.Fatalf("request %d: expected no limit, got %d", i+1, w.Code)
+			}
+		}
+	})
+}
+
+func TestRateLimiter_LoginLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newTestRateLimiter(t, []string{"/test/login account 2/1m"}, nil)
+	path := limiter.policies[0].Route
+
+	router := gin.New()
+	router.POST(path, limiter.LoginLimit("email"), func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"email": req.Email})
+	})
+
+	login := func(email, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Handler can still read the body", func(t *testing.T) {
+		w := login("jane@example.com", "10.0.0.1")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "jane@example.com") {
+			t.Fatalf("expected the handler to bind the body, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Email is normalized", func(t *testing.T) {
+		if w := login(" Jane@Example.com", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("expected the second attempt to pass, got %d", w.Code)
+		}
+		w := login("JANE@example.com", "10.0.0.1")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Errorf("expected the third attempt to be throttled, got %d", w.Code)
+		}
+	})
+
+	t.Run("Other accounts and IPs are unaffected", func(t *testing.T) {
+		if w := login("john@example.com", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Errorf("expected another account to pass, got %d", w.Code)
+		}
+		if w := login("jane@example.com", "10.0.0.2"); w.Code != http.StatusOK {
+			t.Errorf("expected another IP to pass, got %d", w.Code)
+		}
+	})
+}
@@ -11,6 +11,13 @@ import (
 
 func SetupRoutes(
 	authHandler *handlers.AuthHandler,
+	mfaHandler *handlers.MFAHandler,
+	passkeyHandler *handlers.PasskeyHandler,
+	accountHandler *handlers.AccountHandler,
+	jwksHandler *handlers.JWKSHandler,
+	emailQueueHandler *handlers.EmailQueueHandler,
+	roleHandler *handlers.RoleHandler,
+	oauthHandler *handlers.OAuthHandler,
 	jwtMiddleware *middleware.JWTMiddleware,
 	rateLimiter *middleware.RateLimiter,
 ) *gin.Engine {
@@ -46,6 +53,28 @@ func SetupRoutes(
 		})
 	})
 
+	// Public verification keys for downstream services
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+	router.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+
+	// OAuth 2.1 authorization server and OpenID Connect provider for the
+	// applications signing users in
+	oauth := router.Group("/oauth")
+	oauth.Use(rateLimiter.Limit())
+	{
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/authorize", rateLimiter.LoginLimit("email"), oauthHandler.Approve)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/device_authorization", oauthHandler.DeviceAuthorization)
+		oauth.GET("/device", oauthHandler.Device)
+		oauth.POST("/device", rateLimiter.LoginLimit("email"), oauthHandler.ApproveDevice)
+		userInfo := []gin.HandlerFunc{jwtMiddleware.RequireAuth(), middleware.RequireUser(), middleware.RequireScope("openid"), oauthHandler.UserInfo}
+		oauth.GET("/userinfo", userInfo...)
+		oauth.POST("/userinfo", userInfo...)
+		oauth.GET("/logout", oauthHandler.EndSession)
+		oauth.POST("/logout", oauthHandler.EndSession)
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 
@@ -54,25 +83,77 @@ func SetupRoutes(
 
 	// Public routes (no authentication required)
 	auth := v1.Group("/auth")
-	auth.Use(rateLimiter.Limit()) // General rate limiting for all auth endpoints
+	auth.Use(rateLimiter.Limit()) // Rate limiting per route and client, see the rate limit policies
 	{
 		auth.POST("/register", authHandler.Register)
-		auth.POST("/login", authHandler.Login) // Stricter rate limit for login
+		// Per-account limits where credentials can be guessed
+		auth.POST("/login", rateLimiter.LoginLimit("email"), authHandler.Login)
 		auth.POST("/refresh", authHandler.RefreshToken)
-		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/forgot-password", rateLimiter.LoginLimit("email"), authHandler.ForgotPassword)
 		auth.POST("/reset-password", authHandler.ResetPassword)
 		auth.GET("/verify-email/:token", authHandler.VerifyEmail)
+		auth.POST("/resend-verification", authHandler.ResendVerification)
+		auth.GET("/unlock/:token", authHandler.UnlockAccount)
+		auth.POST("/magic-link", authHandler.RequestMagicLink)
+		auth.POST("/magic-link/verify", authHandler.RedeemMagicLink)
+		auth.POST("/email-code", authHandler.RequestLoginCode)
+		auth.POST("/email-code/verify", authHandler.LoginWithCode)
+		auth.POST("/mfa/verify", rateLimiter.LoginLimit("mfa_token"), mfaHandler.Verify)
+		auth.POST("/passkey/login/begin", passkeyHandler.BeginLogin)
+		auth.POST("/passkey/login/finish", passkeyHandler.FinishLogin)
 	}
 
-	// Protected routes (authentication required)
+	// Protected routes (authentication required). They act on the signed-in
+	// user, so service tokens are kept out.
 	protected := v1.Group("/")
-	protected.Use(jwtMiddleware.RequireAuth())
+	protected.Use(jwtMiddleware.RequireAuth(), middleware.RequireUser())
+	protected.Use(rateLimiter.Limit()) // Per-user policies apply once the user is known
 	{
-		protected.GET("/profile", authHandler.Profile)
+		protected.GET("/profile", middleware.RequireScope("profile"), authHandler.Profile)
 		protected.POST("/logout", authHandler.Logout)
 
-		// Add more protected routes here
-		protected.GET("/dashboard", func(c *gin.Context) {
+		// Account settings need the account scope
+		account := protected.Group("/")
+		account.Use(middleware.RequireScope("account"))
+		{
+			// Two-factor authentication settings
+			account.POST("/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+			account.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+			account.POST("/mfa/totp/disable", mfaHandler.DisableTOTP)
+			account.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+			// Account changes that need a step-up code
+			account.POST("/account/step-up", accountHandler.StepUp)
+			account.POST("/account/password", accountHandler.ChangePassword)
+			account.POST("/account/email", accountHandler.ChangeEmail)
+
+			// Passkeys
+			account.GET("/passkeys", passkeyHandler.List)
+			account.POST("/passkeys/register/begin", passkeyHandler.BeginRegistration)
+			account.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration)
+			account.DELETE("/passkeys/:id", passkeyHandler.Delete)
+		}
+
+		// Role administration
+		admin := protected.Group("/admin")
+		admin.Use(middleware.RequireScope("admin"), middleware.RequirePermission("roles:manage"))
+		{
+			admin.GET("/roles", roleHandler.ListRoles)
+			admin.POST("/roles", roleHandler.CreateRole)
+			admin.DELETE("/roles/:name", roleHandler.DeleteRole)
+			admin.POST("/roles/:name/permissions", roleHandler.GrantPermission)
+			admin.DELETE("/roles/:name/permissions/:permission", roleHandler.RevokePermission)
+			admin.GET("/permissions", roleHandler.ListPermissions)
+			admin.GET("/users/:id/roles", roleHandler.ListUserRoles)
+			admin.POST("/users/:id/roles", roleHandler.AssignRole)
+			admin.DELETE("/users/:id/roles/:role", roleHandler.RemoveRole)
+		}
+
+		// Email delivery backlog for operators
+		protected.GET("/admin/email-outbox", middleware.RequireScope("admin"), middleware.RequirePermission("email:monitor"), emailQueueHandler.Stats)
+
+		// Add more protected routes here, gated by scope and by role or permission
+		protected.GET("/dashboard", middleware.RequireScope("profile"), middleware.RequirePermission("dashboard:view"), func(c *gin.Context) {
 			userID := c.GetInt("user_id")
 			username := c.GetString("username")
 
@@ -0,0 +1,14 @@
+-- Create signing keys table used by the JWT keyring
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid VARCHAR(100) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    activated_at TIMESTAMP WITH TIME ZONE,
+    deactivated_at TIMESTAMP WITH TIME ZONE,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+-- At most one key may sign at any time
+CREATE UNIQUE INDEX IF NOT EXISTS signing_keys_active_idx ON signing_keys(status) WHERE status = 'active';
@@ -0,0 +1 @@
+-- Nothing to revert: the reconciled schema is the one 000001 creates
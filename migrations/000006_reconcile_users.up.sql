@@ -0,0 +1,15 @@
+-- Databases created before versioned migrations were bootstrapped by the
+-- service itself with a users table that differs from 000001. Bring them in
+-- line; on databases created by 000001 every statement is a no-op.
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(255);
+ALTER TABLE users ALTER COLUMN created_at TYPE TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ALTER COLUMN updated_at TYPE TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT FALSE;
+
+-- Usernames are display names; accounts are identified by email
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+DROP INDEX IF EXISTS idx_users_username;
+
+-- idx_users_email duplicated users_email_idx
+DROP INDEX IF EXISTS idx_users_email;
+CREATE INDEX IF NOT EXISTS users_email_idx ON users(email);
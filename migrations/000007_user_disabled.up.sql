@@ -0,0 +1,2 @@
+-- Let operators disable accounts
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+ALTER TABLE one_time_tokens DROP COLUMN IF EXISTS attempts;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_credentials;
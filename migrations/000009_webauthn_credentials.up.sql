@@ -0,0 +1,14 @@
+-- Passkeys registered by users
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id VARCHAR(1400) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid VARCHAR(32) NOT NULL DEFAULT '',
+    attestation_format VARCHAR(32) NOT NULL DEFAULT '',
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_idx ON webauthn_credentials(user_id);
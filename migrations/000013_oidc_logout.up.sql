@@ -0,0 +1,2 @@
+-- Where OpenID Connect clients may send users after RP-initiated logout
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
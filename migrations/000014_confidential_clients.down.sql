@@ -0,0 +1,4 @@
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS token_endpoint_auth_method,
+    DROP COLUMN IF EXISTS secret_hash,
+    DROP COLUMN IF EXISTS public_key;
@@ -0,0 +1,5 @@
+-- Confidential clients authenticate with a secret or a signed assertion
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS token_endpoint_auth_method VARCHAR(32) NOT NULL DEFAULT 'none',
+    ADD COLUMN IF NOT EXISTS secret_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS public_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- The scope a login asked for, kept with its MFA challenge until the second
+-- factor is verified
+ALTER TABLE one_time_tokens ADD COLUMN IF NOT EXISTS scope TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+-- The scrubbed content can't be restored
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Delivered and abandoned emails keep only their metadata; their data and
+-- body held live links and codes
+UPDATE email_outbox SET data = '{}', body = '' WHERE status IN ('sent', 'dead');
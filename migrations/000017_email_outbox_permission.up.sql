@@ -0,0 +1,9 @@
+-- The email queue stats are for operators only
+INSERT INTO permissions (name, description) VALUES
+    ('email:monitor', 'View the email delivery queue')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'email:monitor'
+ON CONFLICT DO NOTHING;
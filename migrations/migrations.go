@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL migrations so the binary can
+// apply them without the files being deployed alongside it.
+package migrations
+
+import "embed"
+
+// FS holds the <version>_<name>.up.sql and <version>_<name>.down.sql files
+//
+//go:embed *.sql
+var FS embed.FS